@@ -1,6 +1,7 @@
 package config
 
 import "strconv"
+import "strings"
 
 // Builder is used for generating hierarchical configuration data in memory.
 // After build is returned object which implement interface ```Config```.
@@ -14,11 +15,37 @@ type Builder interface {
 	String(name string, val string) Builder
 }
 
+// LayoutBuilder is a Builder which also records comments, blank lines and
+// positions of nodes, e.g. for decoders of other formats.
+type LayoutBuilder interface {
+	Builder
+	// At sets the position of the last added key or section, e.g. the line
+	// of a key in a file of another format.
+	At(pos Position) LayoutBuilder
+	// Comment adds comment lines, which are attached to the next key or
+	// section or to the end of the current section.
+	Comment(comment string) LayoutBuilder
+	// Line adds a blank line before the next key or section.
+	Line() LayoutBuilder
+}
+
 type builder struct {
-	stack []*config
+	stack   []*config
+	last    *config
+	pending []string
 }
 
 func NewBuilder() Builder {
+	return newBuilder()
+}
+
+// NewLayoutBuilder returns a builder which also records comments, blank lines
+// and positions of nodes.
+func NewLayoutBuilder() LayoutBuilder {
+	return newBuilder()
+}
+
+func newBuilder() *builder {
 	b := new(builder)
 	b.stack = make([]*config, 1, 16)
 	b.stack[0] = b.create("", "", true)
@@ -28,9 +55,18 @@ func NewBuilder() Builder {
 func (this *builder) append(cfg *config) *config {
 	cur := this.current()
 	cur.children = append(cur.children, cfg)
+	cfg.lead = this.pending
+	this.pending = nil
+	this.last = cfg
 	return cfg
 }
 
+// comment stores a raw comment line (including the leading '#'). The comment
+// is attached to the next appended node or to the end of the current section.
+func (this *builder) comment(raw string) {
+	this.pending = append(this.pending, raw)
+}
+
 func (this *builder) create(name string, val string, isSection bool) *config {
 	cfg := new(config)
 	cfg.name = name
@@ -45,18 +81,43 @@ func (this *builder) current() *config {
 	return this.stack[len(this.stack)-1]
 }
 
+func (this *builder) At(pos Position) LayoutBuilder {
+	if this.last != nil {
+		this.last.pos = pos
+	}
+	return this
+}
+
 func (this *builder) Bool(name string, val bool) Builder {
 	this.append(this.create(name, strconv.FormatBool(val), false))
 	return this
 }
 
 func (this *builder) CloseSection() Builder {
+	cur := this.current()
+	cur.trailer = append(cur.trailer, this.pending...)
+	this.pending = nil
+	this.last = nil
 	this.stack = this.stack[0 : len(this.stack)-1]
 	return this
 }
 
+func (this *builder) Comment(comment string) LayoutBuilder {
+	for _, line := range strings.Split(comment, "\n") {
+		if line == "" {
+			this.comment("#")
+		} else {
+			this.comment("# " + line)
+		}
+	}
+	return this
+}
+
 func (this *builder) Config() Config {
-	return this.stack[0]
+	root := this.stack[0]
+	root.trailer = append(root.trailer, this.pending...)
+	this.pending = nil
+	return root
 }
 
 func (this *builder) Float(name string, val float64) Builder {
@@ -69,6 +130,11 @@ func (this *builder) Int(name string, val int64) Builder {
 	return this
 }
 
+func (this *builder) Line() LayoutBuilder {
+	this.pending = append(this.pending, "")
+	return this
+}
+
 func (this *builder) Section(name string, val string) Builder {
 	this.stack = append(this.stack, this.append(this.create(name, val, true)))
 	return this
@@ -78,3 +144,30 @@ func (this *builder) String(name string, val string) Builder {
 	this.append(this.create(name, val, false))
 	return this
 }
+
+// token adds the token read by the parser.
+func (this *builder) token(tok *token) {
+	switch tok.kind {
+	case tokenSectionStart:
+		this.Section(tok.name, tok.value)
+		this.last.pos = tok.pos
+		this.last.start = tok.start
+		this.last.extends = tok.extends
+	case tokenSectionEnd:
+		this.current().end = tok.end
+		this.CloseSection()
+	case tokenKeyValue:
+		this.String(tok.name, tok.value)
+		this.last.pos = tok.pos
+		this.last.start = tok.start
+		this.last.end = tok.end
+	case tokenComment:
+		if tok.inline && this.last != nil {
+			this.last.comment = tok.value
+		} else {
+			this.comment(tok.value)
+		}
+	case tokenBlankLine:
+		this.Line()
+	}
+}
@@ -164,3 +164,27 @@ func TestBuilder(t *testing.T) {
 	}
 
 }
+
+func TestBuilderComments(t *testing.T) {
+
+	b := config.NewLayoutBuilder()
+	b.Comment("First line\nSecond line")
+	b.String("StringValue", "Test")
+	b.Line()
+	b.Comment("Section")
+	b.Section("Section", "One")
+	b.CloseSection()
+
+	c := b.Config()
+
+	if val, ok := c.Query("StringValue"); !ok || len(config.Comments(val)) != 2 || config.Comments(val)[1] != "Second line" {
+		t.Error("Invalid comments for query 'StringValue'")
+		t.Fail()
+	}
+
+	if val, ok := c.Query("Section"); !ok || len(config.Comments(val)) != 1 || config.Comments(val)[0] != "Section" {
+		t.Error("Invalid comments for query 'Section'")
+		t.Fail()
+	}
+
+}
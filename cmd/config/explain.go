@@ -0,0 +1,71 @@
+package main
+
+import "context"
+import "flag"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "github.com/twoleds-golang/config/schema"
+import "io/ioutil"
+
+// explain prints how the value of the query is composed from defaults of a
+// schema, the file, environment variables, overrides, interpolation and
+// decryption.
+func explain(args []string) error {
+	fs := flag.NewFlagSet("config explain", flag.ExitOnError)
+	schemaFile := fs.String("schema", "", "schema with default values")
+	env := fs.String("env", "", "prefix of environment variables which override the file")
+	keyFile := fs.String("key-file", "", "symmetric key from the file for decryption")
+	keyEnv := fs.String("key-env", "", "symmetric key from the environment variable for decryption")
+	identityFile := fs.String("identity-file", "", "X25519 identity from the file for decryption")
+	overrides := config.BindFlags(fs, nil)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		usage()
+	}
+	file, query := fs.Arg(0), fs.Arg(1)
+
+	l := config.NewLoader()
+	if *schemaFile != "" {
+		s, err := schema.ParseFromFile(*schemaFile)
+		if err != nil {
+			return err
+		}
+		l.Add(config.ConfigSource("defaults", s.Defaults()))
+	}
+	l.Add(config.FileSource(file))
+	if *env != "" {
+		ref, err := config.FileSource(file).Load(context.Background())
+		if err != nil {
+			return err
+		}
+		l.Add(config.EnvSource(*env, &config.EnvOptions{Reference: ref, FoldCase: true}))
+	}
+	l.Add(config.FlagsSource(overrides))
+
+	var keys config.KeyProvider
+	var err error
+	switch {
+	case *keyFile != "":
+		keys, err = config.FileKey(*keyFile)
+	case *keyEnv != "":
+		keys, err = config.EnvKey(*keyEnv)
+	case *identityFile != "":
+		var data []byte
+		if data, err = ioutil.ReadFile(*identityFile); err == nil {
+			keys, err = config.X25519Identity(string(data))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if keys != nil {
+		l.Decrypt(keys)
+	}
+
+	e, err := l.Explain(context.Background(), query)
+	if err != nil {
+		return err
+	}
+	fmt.Print(e)
+	return nil
+}
@@ -0,0 +1,87 @@
+// Command config edits configuration files in place and explains effective
+// values.
+//
+// Usage:
+//
+//	config set FILE QUERY VALUE
+//	config delete FILE QUERY
+//	config add-section FILE QUERY
+//	config explain [flags] FILE QUERY
+//
+// Only the affected lines are modified, comments and formatting of the rest
+// of the file are preserved. The result is validated before it is written and
+// the file is replaced atomically.
+//
+// Explain prints the effective value of the key and every contribution to
+// it: defaults of a schema (-schema), the file and its includes with line
+// numbers, environment variables (-env PREFIX), overrides (-set
+// query=value), interpolation and decryption (-key-file, -key-env or
+// -identity-file). Values of sensitive keys are redacted.
+package main
+
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "github.com/twoleds-golang/config/internal/atomicfile"
+import "io/ioutil"
+import "os"
+
+type command struct {
+	args int
+	run  func(e config.Editor, args []string) error
+}
+
+var commands = map[string]command{
+	"set": {2, func(e config.Editor, args []string) error {
+		return e.Set(args[0], args[1])
+	}},
+	"delete": {1, func(e config.Editor, args []string) error {
+		return e.Delete(args[0])
+	}},
+	"add-section": {1, func(e config.Editor, args []string) error {
+		return e.AddSection(args[0])
+	}},
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		usage()
+	}
+	if os.Args[1] == "explain" {
+		if err := explain(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "config: %s\n", err.Error())
+			os.Exit(1)
+		}
+		return
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok || len(os.Args) != cmd.args+3 {
+		usage()
+	}
+	if err := edit(os.Args[2], cmd, os.Args[3:]); err != nil {
+		fmt.Fprintf(os.Stderr, "config: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: config set FILE QUERY VALUE")
+	fmt.Fprintln(os.Stderr, "       config delete FILE QUERY")
+	fmt.Fprintln(os.Stderr, "       config add-section FILE QUERY")
+	fmt.Fprintln(os.Stderr, "       config explain [flags] FILE QUERY")
+	os.Exit(2)
+}
+
+func edit(file string, cmd command, args []string) error {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	e, err := config.NewEditor(src)
+	if err != nil {
+		return fmt.Errorf("%s: %s", file, err.Error())
+	}
+	if err := cmd.run(e, args); err != nil {
+		return fmt.Errorf("%s: %s", file, err.Error())
+	}
+	return atomicfile.WriteFile(file, e.Bytes())
+}
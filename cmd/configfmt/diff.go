@@ -0,0 +1,178 @@
+package main
+
+import "bytes"
+import "fmt"
+import "strings"
+
+// diffContext is the number of unchanged lines around changes in hunks.
+const diffContext = 3
+
+// diffLine is a line of the edit script, kind is ' ' for an unchanged line,
+// '-' for a removed line and '+' for an added line.
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// unifiedDiff returns differences between the old and the new content in the
+// unified format, nothing if they are equal.
+func unifiedDiff(oldName string, newName string, a []byte, b []byte) []byte {
+	lines := diffLines(splitLines(a), splitLines(b))
+
+	// Numbers of old and new lines before every line of the edit script.
+	oldAt := make([]int, len(lines)+1)
+	newAt := make([]int, len(lines)+1)
+	for i, line := range lines {
+		oldAt[i+1] = oldAt[i]
+		newAt[i+1] = newAt[i]
+		if line.kind != '+' {
+			oldAt[i+1]++
+		}
+		if line.kind != '-' {
+			newAt[i+1]++
+		}
+	}
+
+	var out bytes.Buffer
+	for i := 0; i < len(lines); {
+		if lines[i].kind == ' ' {
+			i++
+			continue
+		}
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(lines) {
+			if lines[end].kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(lines) && lines[next].kind == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*diffContext {
+				end = end + diffContext
+				if end > next {
+					end = next
+				}
+				break
+			}
+			end = next
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldAt[start], oldAt[end]), hunkRange(newAt[start], newAt[end]))
+		for _, line := range lines[start:end] {
+			out.WriteByte(line.kind)
+			out.WriteString(line.text)
+			if !strings.HasSuffix(line.text, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.Bytes()
+}
+
+// hunkRange returns the range of lines of a hunk header for the hunk which
+// follows the specified number of lines and ends before line to.
+func hunkRange(from int, to int) string {
+	if to == from {
+		return fmt.Sprintf("%d,0", from)
+	} else if to-from == 1 {
+		return fmt.Sprintf("%d", from+1)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+// splitLines splits the content to lines which keep their line breaks.
+func splitLines(data []byte) []string {
+	lines := strings.SplitAfter(string(data), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script which turns the lines a to the
+// lines b, computed by the Myers algorithm.
+func diffLines(a []string, b []string) []diffLine {
+	n, m := len(a), len(b)
+
+	// trace[d] holds furthest x of diagonals -d+1..d-1 reached with d-1
+	// edits, v holds furthest x of all diagonals shifted by offset.
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	trace := make([][]int, 0, 16)
+	for d := 0; d <= offset; d++ {
+		if d > 0 {
+			trace = append(trace, append([]int(nil), v[offset-d+1:offset+d]...))
+		} else {
+			trace = append(trace, nil)
+		}
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, a, b)
+			}
+		}
+	}
+	return nil
+}
+
+// backtrack follows the trace of diffLines from the end of both sequences
+// back to the start and returns the edit script.
+func backtrack(trace [][]int, a []string, b []string) []diffLine {
+	lines := make([]diffLine, 0, len(a)+len(b))
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d > 0; d-- {
+		at := func(k int) int {
+			return trace[d][k+d-1]
+		}
+		k := x - y
+		var prev int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prev = k + 1
+		} else {
+			prev = k - 1
+		}
+		prevX := at(prev)
+		prevY := prevX - prev
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, diffLine{' ', a[x]})
+		}
+		if x == prevX {
+			y--
+			lines = append(lines, diffLine{'+', b[y]})
+		} else {
+			x--
+			lines = append(lines, diffLine{'-', a[x]})
+		}
+	}
+	for x > 0 {
+		x--
+		lines = append(lines, diffLine{' ', a[x]})
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+
+	var tests = []struct {
+		old      string
+		new      string
+		expected string
+	}{
+		{"A 1\n", "A 1\n", ""},
+		{"A 1\nB 2\n", "A 1\nB  2\n", "--- a\n+++ b\n@@ -1,2 +1,2 @@\n A 1\n-B 2\n+B  2\n"},
+		{"", "A 1\n", "--- a\n+++ b\n@@ -0,0 +1 @@\n+A 1\n"},
+		{"A 1", "A 1\n", "--- a\n+++ b\n@@ -1 +1 @@\n-A 1\n\\ No newline at end of file\n+A 1\n"},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			"--- a\n+++ b\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+	}
+
+	for _, test := range tests {
+		if diff := string(unifiedDiff("a", "b", []byte(test.old), []byte(test.new))); diff != test.expected {
+			t.Errorf("Invalid diff of %q and %q:\n%s", test.old, test.new, diff)
+			t.Fail()
+		}
+	}
+
+}
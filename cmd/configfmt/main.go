@@ -0,0 +1,134 @@
+// Command configfmt formats configuration files.
+//
+// Without an explicit path it processes the standard input. Given a file, it
+// operates on that file; given a directory, it operates on all .conf files in
+// that directory, recursively. By default, configfmt prints the reformatted
+// sources to standard output.
+//
+// Usage:
+//
+//	configfmt [flags] [path ...]
+//
+// The flags are:
+//
+//	-d    display diffs instead of rewriting files
+//	-l    list files whose formatting differs from configfmt's
+//	-tabs indent nested sections with tabs instead of four spaces
+//	-w    write result to (source) file instead of stdout
+package main
+
+import "bytes"
+import "flag"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "github.com/twoleds-golang/config/internal/atomicfile"
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "strings"
+
+var (
+	list  = flag.Bool("l", false, "list files whose formatting differs from configfmt's")
+	write = flag.Bool("w", false, "write result to (source) file instead of stdout")
+	diff  = flag.Bool("d", false, "display diffs instead of rewriting files")
+	tabs  = flag.Bool("tabs", false, "indent nested sections with tabs instead of four spaces")
+)
+
+var exitCode = 0
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if *write {
+			fmt.Fprintln(os.Stderr, "configfmt: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		if err := processFile("<standard input>", os.Stdin, os.Stdout); err != nil {
+			report(err)
+		}
+		os.Exit(exitCode)
+	}
+
+	for _, path := range flag.Args() {
+		info, err := os.Stat(path)
+		if err != nil {
+			report(err)
+		} else if info.IsDir() {
+			walkDir(path)
+		} else if err := processFile(path, nil, os.Stdout); err != nil {
+			report(err)
+		}
+	}
+	os.Exit(exitCode)
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: configfmt [flags] [path ...]")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func report(err error) {
+	fmt.Fprintln(os.Stderr, err.Error())
+	exitCode = 2
+}
+
+func walkDir(path string) {
+	filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() && strings.HasSuffix(info.Name(), ".conf") {
+			err = processFile(path, nil, os.Stdout)
+		}
+		if err != nil {
+			report(err)
+		}
+		return nil
+	})
+}
+
+func processFile(filename string, in *os.File, out *os.File) error {
+	if in == nil {
+		f, err := os.Open(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	src, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	indent := "    "
+	if *tabs {
+		indent = "\t"
+	}
+	res, err := config.FormatIndent(src, indent)
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err.Error())
+	}
+
+	if !bytes.Equal(src, res) {
+		if *list {
+			fmt.Fprintln(out, filename)
+		}
+		if *write {
+			if err := atomicfile.WriteFile(filename, res); err != nil {
+				return err
+			}
+		}
+		if *diff {
+			fmt.Fprintf(out, "diff %s configfmt/%s\n", filename, filename)
+			out.Write(unifiedDiff(filename+".orig", filename, src, res))
+		}
+	}
+
+	if !*list && !*write && !*diff {
+		out.Write(res)
+	}
+
+	return nil
+}
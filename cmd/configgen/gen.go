@@ -0,0 +1,347 @@
+package main
+
+import "bytes"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "github.com/twoleds-golang/config/schema"
+import "go/format"
+import "strconv"
+import "strings"
+import "time"
+
+// structDef describes a generated struct for a section.
+type structDef struct {
+	Name   string
+	Value  bool
+	Fields []*fieldDef
+}
+
+// fieldDef describes a field for a key or a section.
+type fieldDef struct {
+	Name     string
+	Key      string
+	Type     string
+	Struct   *structDef
+	Repeated bool
+	Options  []string
+	Default  string
+}
+
+// goName returns an exported Go identifier for the configuration name, bytes
+// which can't be used in identifiers are dropped.
+func goName(name string) string {
+	buf := make([]byte, 0, len(name))
+	for i := 0; i < len(name); i++ {
+		b := name[i]
+		if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (len(buf) > 0 && ((b >= '0' && b <= '9') || b == '_')) {
+			buf = append(buf, b)
+		}
+	}
+	if len(buf) == 0 {
+		return "Field"
+	}
+	return strings.ToUpper(string(buf[:1])) + string(buf[1:])
+}
+
+// unique returns the name, or the name with the lowest number suffix if the
+// name is already used, e.g. for keys "port" and "Port", and marks it used.
+func unique(used map[string]bool, name string) string {
+	result := name
+	for i := 2; used[result]; i++ {
+		result = name + strconv.Itoa(i)
+	}
+	used[result] = true
+	return result
+}
+
+// inferType returns the Go type for the values, the values are tested with
+// the same functions which are used by getters of Config.
+func inferType(values []string) string {
+	types := []string{"int64", "float64", "bool"}
+	for _, typ := range types {
+		ok := true
+		for _, val := range values {
+			var err error
+			switch typ {
+			case "int64":
+				_, err = strconv.ParseInt(val, 10, 64)
+			case "float64":
+				_, err = strconv.ParseFloat(val, 64)
+			case "bool":
+				_, err = strconv.ParseBool(val)
+			}
+			ok = ok && err == nil
+		}
+		if ok {
+			return typ
+		}
+	}
+	return "string"
+}
+
+// fromSample returns a struct for the sections. All occurrences of sections
+// with the same name are merged to a single struct. Types holds names of
+// generated structs, names of structs and fields are unique.
+func fromSample(name string, sections []config.Config, types map[string]bool) *structDef {
+	def := &structDef{Name: unique(types, name)}
+	names := make([]string, 0, 16)
+	children := make(map[string][]config.Config)
+	for _, section := range sections {
+		def.Value = def.Value || section.Value() != ""
+		counts := make(map[string]int)
+		for _, child := range config.Children(section) {
+			if _, ok := children[child.Name()]; !ok {
+				names = append(names, child.Name())
+			}
+			children[child.Name()] = append(children[child.Name()], child)
+			counts[child.Name()]++
+		}
+		for key, count := range counts {
+			if count > 1 {
+				children[key] = append(children[key], nil)
+			}
+		}
+	}
+	used := map[string]bool{"Value": def.Value}
+	for _, key := range names {
+		field := &fieldDef{Name: unique(used, goName(key)), Key: key}
+		nodes := make([]config.Config, 0, len(children[key]))
+		for _, node := range children[key] {
+			if node == nil {
+				field.Repeated = true
+			} else {
+				nodes = append(nodes, node)
+			}
+		}
+		if config.IsSection(nodes[0]) {
+			field.Struct = fromSample(def.Name+field.Name, nodes, types)
+			field.Repeated = field.Repeated || field.Struct.Value
+		} else {
+			values := make([]string, len(nodes))
+			for i, node := range nodes {
+				values[i] = node.Value()
+			}
+			field.Type = inferType(values)
+		}
+		def.Fields = append(def.Fields, field)
+	}
+	return def
+}
+
+// fromSchema returns a struct for the schema, see fromSample.
+func fromSchema(name string, s *schema.Schema, values []string, types map[string]bool) *structDef {
+	def := &structDef{Name: unique(types, name), Value: values != nil}
+	used := map[string]bool{"Value": def.Value}
+	for _, key := range s.Keys {
+		field := &fieldDef{Name: unique(used, goName(key.Name)), Key: key.Name, Repeated: key.Repeated, Default: key.Default}
+		switch key.Type {
+		case schema.Bool:
+			field.Type = "bool"
+		case schema.Duration:
+			field.Type = "time.Duration"
+		case schema.Float:
+			field.Type = "float64"
+		case schema.Int:
+			field.Type = "int64"
+		default:
+			field.Type = "string"
+		}
+		if key.Required {
+			field.Options = append(field.Options, "required")
+		}
+		if key.Min != "" {
+			field.Options = append(field.Options, "min="+key.Min)
+		}
+		if key.Max != "" {
+			field.Options = append(field.Options, "max="+key.Max)
+		}
+		if len(key.Enum) > 0 {
+			field.Options = append(field.Options, "enum="+strings.Join(key.Enum, "|"))
+		}
+		if key.Pattern != "" && !strings.ContainsAny(key.Pattern, ",`") {
+			field.Options = append(field.Options, "pattern="+key.Pattern)
+		}
+		if key.Default != "" {
+			field.Options = append(field.Options, "default="+key.Default)
+		}
+		def.Fields = append(def.Fields, field)
+	}
+	for _, section := range s.Sections {
+		field := &fieldDef{Name: unique(used, goName(section.Name)), Key: section.Name, Repeated: section.Max != 1}
+		vals := section.Values
+		if vals == nil {
+			vals = []string{}
+		}
+		field.Struct = fromSchema(def.Name+field.Name, &section.Schema, vals, types)
+		if section.Min > 0 {
+			field.Options = append(field.Options, "min="+strconv.Itoa(section.Min))
+		}
+		if section.Max > 1 {
+			field.Options = append(field.Options, "max="+strconv.Itoa(section.Max))
+		}
+		if len(section.Values) > 0 {
+			field.Options = append(field.Options, "values="+strings.Join(section.Values, "|"))
+		}
+		def.Fields = append(def.Fields, field)
+	}
+	return def
+}
+
+type generator struct {
+	buf     bytes.Buffer
+	done    map[string]bool
+	imports map[string]bool
+}
+
+// generate returns formatted Go source with the structs and Load function.
+// If the schema is not empty, Load checks configuration files against it.
+func generate(pkg string, root *structDef, s *schema.Schema) ([]byte, error) {
+	g := new(generator)
+	g.done = make(map[string]bool)
+	g.imports = make(map[string]bool)
+	if s != nil {
+		buf := new(bytes.Buffer)
+		w := config.NewWriter(buf)
+		s.Write(w)
+		w.Flush()
+		g.imports["github.com/twoleds-golang/config/schema"] = true
+		g.printf("// schemaSource is the schema which Load checks configuration files\n")
+		g.printf("// against.\n")
+		g.printf("const schemaSource = %s\n\n", strconv.Quote(buf.String()))
+		g.printf("// Load parses the configuration file, checks it against the schema and\n")
+		g.printf("// returns its content.\n")
+	} else {
+		g.printf("// Load parses the configuration file and returns its content.\n")
+	}
+	g.printf("func Load(path string) (*%s, error) {\n", root.Name)
+	g.printf("cfg, err := config.ParseFromFile(path)\n")
+	g.printf("if err != nil {\nreturn nil, err\n}\n")
+	if s != nil {
+		g.printf("s, err := schema.ParseFromString(schemaSource)\n")
+		g.printf("if err != nil {\nreturn nil, err\n}\n")
+		g.printf("if err := s.Check(cfg); err != nil {\nreturn nil, err\n}\n")
+	}
+	g.printf("v := new(%s)\n", root.Name)
+	g.printf("if err := v.decode(cfg); err != nil {\nreturn nil, err\n}\n")
+	g.printf("return v, nil\n}\n\n")
+	g.structDef(root)
+
+	body := g.buf.Bytes()
+	g.buf = bytes.Buffer{}
+	g.printf("// Code generated by configgen. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", pkg)
+	for _, name := range []string{"fmt", "github.com/twoleds-golang/config", "github.com/twoleds-golang/config/schema", "strconv", "time"} {
+		if g.imports[name] || name == "github.com/twoleds-golang/config" {
+			g.printf("import %q\n", name)
+		}
+	}
+	g.printf("\n")
+	g.buf.Write(body)
+
+	return format.Source(g.buf.Bytes())
+}
+
+func (this *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&this.buf, format, args...)
+}
+
+func (this *generator) structDef(def *structDef) {
+	if this.done[def.Name] {
+		return
+	}
+	this.done[def.Name] = true
+
+	this.printf("type %s struct {\n", def.Name)
+	if def.Value {
+		this.printf("Value string `config:\",value\"`\n")
+	}
+	for _, field := range def.Fields {
+		typ := field.Type
+		if field.Struct != nil {
+			typ = field.Struct.Name
+		}
+		if field.Repeated {
+			typ = "[]" + typ
+		}
+		tag := strings.Join(append([]string{field.Key}, field.Options...), ",")
+		this.printf("%s %s `config:%s`\n", field.Name, typ, strconv.Quote(tag))
+	}
+	this.printf("}\n\n")
+
+	this.printf("func (this *%s) decode(cfg config.Config) error {\n", def.Name)
+	if def.Value {
+		this.printf("this.Value = cfg.Value()\n")
+	}
+	for _, field := range def.Fields {
+		if field.Default != "" && !field.Repeated {
+			this.printf("this.%s = %s\n", field.Name, this.literal(field.Type, field.Default))
+		}
+	}
+	this.printf("for _, child := range config.Children(cfg) {\n")
+	this.printf("switch child.Name() {\n")
+	for _, field := range def.Fields {
+		this.printf("case %q:\n", field.Key)
+		if field.Struct != nil {
+			this.printf("var v %s\n", field.Struct.Name)
+			this.printf("if err := v.decode(child); err != nil {\nreturn err\n}\n")
+		} else if field.Type == "string" {
+			this.printf("v := child.Value()\n")
+		} else {
+			this.printf("v, err := %s\n", this.parser(field.Type))
+			this.printf("if err != nil {\n")
+			this.printf("return fmt.Errorf(\"%%s: invalid value of %s: %%s\", config.PositionOf(child), err.Error())\n", field.Key)
+			this.printf("}\n")
+		}
+		if field.Repeated {
+			this.printf("this.%s = append(this.%s, v)\n", field.Name, field.Name)
+		} else {
+			this.printf("this.%s = v\n", field.Name)
+		}
+	}
+	this.printf("}\n}\nreturn nil\n}\n\n")
+
+	for _, field := range def.Fields {
+		if field.Struct != nil {
+			this.structDef(field.Struct)
+		}
+	}
+}
+
+// parser returns an expression which parses value of child to the type.
+func (this *generator) parser(typ string) string {
+	this.imports["fmt"] = true
+	switch typ {
+	case "bool":
+		this.imports["strconv"] = true
+		return "strconv.ParseBool(child.Value())"
+	case "float64":
+		this.imports["strconv"] = true
+		return "strconv.ParseFloat(child.Value(), 64)"
+	case "int64":
+		this.imports["strconv"] = true
+		return "strconv.ParseInt(child.Value(), 10, 64)"
+	}
+	this.imports["time"] = true
+	return "time.ParseDuration(child.Value())"
+}
+
+// literal returns a Go literal of the default value, the value has been
+// already checked by the schema.
+func (this *generator) literal(typ string, val string) string {
+	switch typ {
+	case "bool":
+		b, _ := strconv.ParseBool(val)
+		return strconv.FormatBool(b)
+	case "float64":
+		f, _ := strconv.ParseFloat(val, 64)
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	case "int64":
+		i, _ := strconv.ParseInt(val, 10, 64)
+		return strconv.FormatInt(i, 10)
+	case "time.Duration":
+		this.imports["time"] = true
+		d, _ := time.ParseDuration(val)
+		return fmt.Sprintf("time.Duration(%d)", int64(d))
+	}
+	return strconv.Quote(val)
+}
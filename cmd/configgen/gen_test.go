@@ -0,0 +1,142 @@
+package main
+
+import "github.com/twoleds-golang/config"
+import "github.com/twoleds-golang/config/schema"
+import "go/ast"
+import "go/importer"
+import "go/parser"
+import "go/token"
+import "go/types"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+
+func TestGenerate(t *testing.T) {
+
+	var src = "Name test\n" +
+		"Debug T\n" +
+		"Host a\n" +
+		"Host b\n" +
+		"port 1\n" +
+		"Port 2\n" +
+		"Server Main {\n" +
+		"    Port 8080\n" +
+		"    Ratio 0.5\n" +
+		"    Value x\n" +
+		"}\n" +
+		"Server Backup {\n" +
+		"    Port 8081\n" +
+		"    Ratio 1\n" +
+		"}\n" +
+		"CacheValue {\n" +
+		"}\n" +
+		"Cache {\n" +
+		"    Value {\n" +
+		"    }\n" +
+		"}\n"
+
+	cfg, err := config.ParseFromString(src)
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	out, err := generate("test", fromSample("Config", []config.Config{cfg}, make(map[string]bool)), nil)
+	if err != nil {
+		t.Errorf("Cannot generate code: %s", err.Error())
+		t.FailNow()
+	}
+
+	expected := []string{
+		"Name       string           `config:\"Name\"`",
+		"Debug      bool             `config:\"Debug\"`",
+		"Host       []string         `config:\"Host\"`",
+		"Port       int64            `config:\"port\"`",
+		"Port2      int64            `config:\"Port\"`",
+		"Server     []ConfigServer   `config:\"Server\"`",
+		"CacheValue ConfigCacheValue `config:\"CacheValue\"`",
+		"Value  string  `config:\",value\"`",
+		"Value2 string  `config:\"Value\"`",
+		"Value ConfigCacheValue2 `config:\"Value\"`",
+		"func Load(path string) (*Config, error) {",
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(string(out), line) {
+			t.Errorf("Generated code does not contain %q:\n%s", line, out)
+			t.Fail()
+		}
+	}
+
+	typeCheck(t, out)
+
+}
+
+func TestGenerateSchema(t *testing.T) {
+
+	var src = "Key Name {\n" +
+		"    Type string\n" +
+		"    Required true\n" +
+		"}\n" +
+		"Section Server {\n" +
+		"    Value Main\n" +
+		"    Max 1\n" +
+		"    Key Port {\n" +
+		"        Type int\n" +
+		"        Min 1\n" +
+		"        Max 65535\n" +
+		"        Default 8080\n" +
+		"    }\n" +
+		"    Key Timeout {\n" +
+		"        Type duration\n" +
+		"        Default 5s\n" +
+		"    }\n" +
+		"}\n"
+
+	s, err := schema.ParseFromString(src)
+	if err != nil {
+		t.Errorf("Cannot parse schema: %s", err.Error())
+		t.FailNow()
+	}
+
+	out, err := generate("test", fromSchema("Config", s, nil, make(map[string]bool)), s)
+	if err != nil {
+		t.Errorf("Cannot generate code: %s", err.Error())
+		t.FailNow()
+	}
+
+	expected := []string{
+		"const schemaSource = \"Key Name {\\n    Type string\\n    Required true\\n}\\n",
+		"if err := s.Check(cfg); err != nil {",
+		"Port    int64         `config:\"Port,min=1,max=65535,default=8080\"`",
+		"this.Timeout = time.Duration(5000000000)",
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(string(out), line) {
+			t.Errorf("Generated code does not contain %q:\n%s", line, out)
+			t.Fail()
+		}
+	}
+
+	typeCheck(t, out)
+
+}
+
+// typeCheck type-checks the generated source, imported packages are read
+// from sources like by the go command.
+func typeCheck(t *testing.T, src []byte) {
+	dir, _ := os.Getwd()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filepath.Join(dir, "generated.go"), src, 0)
+	if err != nil {
+		t.Errorf("Cannot parse generated code: %s", err.Error())
+		t.FailNow()
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("test", fset, []*ast.File{file}, nil); err != nil {
+		t.Errorf("Generated code does not compile: %s\n%s", err.Error(), src)
+		t.Fail()
+	}
+}
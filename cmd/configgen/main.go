@@ -0,0 +1,80 @@
+// Command configgen generates Go structs for configuration files.
+//
+// The structs are derived either from a sample configuration, where types of
+// keys are inferred from their values, or from a schema (see the schema
+// package). The generated file contains a Load function which parses a
+// configuration file into the structs, generated from a schema it checks the
+// file against the schema first, so required keys and bounds are enforced.
+//
+// Usage:
+//
+//	configgen [flags] file
+//
+// The flags are:
+//
+//	-o file        write the generated code to the file instead of stdout
+//	-package name  name of the generated package (default "config")
+//	-schema        treat the input file as a schema
+//	-type name     name of the root struct (default "Config")
+package main
+
+import "flag"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "github.com/twoleds-golang/config/schema"
+import "io/ioutil"
+import "os"
+
+var (
+	output   = flag.String("o", "", "write the generated code to the `file` instead of stdout")
+	pkgName  = flag.String("package", "config", "`name` of the generated package")
+	isSchema = flag.Bool("schema", false, "treat the input file as a schema")
+	typeName = flag.String("type", "Config", "`name` of the root struct")
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		usage()
+	}
+
+	var root *structDef
+	var s *schema.Schema
+	if *isSchema {
+		var err error
+		if s, err = schema.ParseFromFile(flag.Arg(0)); err != nil {
+			fail(err)
+		}
+		root = fromSchema(*typeName, s, nil, make(map[string]bool))
+	} else {
+		cfg, err := config.ParseFromFile(flag.Arg(0))
+		if err != nil {
+			fail(err)
+		}
+		root = fromSample(*typeName, []config.Config{cfg}, make(map[string]bool))
+	}
+
+	src, err := generate(*pkgName, root, s)
+	if err != nil {
+		fail(err)
+	}
+
+	if *output == "" {
+		os.Stdout.Write(src)
+	} else if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		fail(err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: configgen [flags] file")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "configgen: %s\n", err.Error())
+	os.Exit(1)
+}
@@ -0,0 +1,108 @@
+// Command configlint reports suspicious constructs in configuration files.
+//
+// Usage:
+//
+//	configlint [flags] file ...
+//
+// The flags are:
+//
+//	-disable rules  comma separated list of rules to disable
+//	-json           print problems as JSON
+//	-max-depth n    maximal allowed nesting of sections (default 4)
+//	-schema file    report keys unknown to the schema and keys of the schema
+//	                which are not used
+//
+// Problems are printed as "file:line:col: rule: message". Rules can be also
+// disabled in a file with a "# configlint:disable=rule,rule" comment. The
+// exit code is 1 when a problem is found and 2 when a file cannot be read.
+package main
+
+import "encoding/json"
+import "flag"
+import "fmt"
+import "github.com/twoleds-golang/config/lint"
+import "github.com/twoleds-golang/config/schema"
+import "io/ioutil"
+import "os"
+import "strings"
+
+var (
+	disable    = flag.String("disable", "", "comma separated list of `rules` to disable")
+	asJSON     = flag.Bool("json", false, "print problems as JSON")
+	maxDepth   = flag.Int("max-depth", 4, "maximal allowed nesting of sections")
+	schemaFile = flag.String("schema", "", "report keys unknown to the schema `file` and keys of the schema which are not used")
+)
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		usage()
+	}
+
+	rules, err := selectRules()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "configlint: %s\n", err.Error())
+		os.Exit(2)
+	}
+
+	problems := make([]lint.Problem, 0, 16)
+	for _, file := range flag.Args() {
+		src, err := ioutil.ReadFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configlint: %s\n", err.Error())
+			os.Exit(2)
+		}
+		problems = append(problems, lint.Lint(file, src, rules)...)
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(problems, "", "    ")
+		fmt.Println(string(data))
+	} else {
+		for _, problem := range problems {
+			fmt.Println(problem.String())
+		}
+	}
+
+	if len(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: configlint [flags] file ...")
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func selectRules() ([]lint.Rule, error) {
+	disabled := make(map[string]bool)
+	for _, name := range strings.Split(*disable, ",") {
+		disabled[strings.TrimSpace(name)] = true
+	}
+
+	rules := make([]lint.Rule, 0, 8)
+	for _, rule := range lint.DefaultRules() {
+		if rule.Name() == lint.DeepNesting(0).Name() {
+			rule = lint.DeepNesting(*maxDepth)
+		}
+		rules = append(rules, rule)
+	}
+	if *schemaFile != "" {
+		s, err := schema.ParseFromFile(*schemaFile)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, lint.UnknownKey(s), lint.UnusedKey(s))
+	}
+
+	selected := rules[:0]
+	for _, rule := range rules {
+		if !disabled[rule.Name()] {
+			selected = append(selected, rule)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,29 @@
+// Command configq prints values from a configuration file.
+//
+// The query uses the same syntax as Config.Query, e.g. "Section:Two/IntValue".
+// Without a file, or when the file is "-", the configuration is read from the
+// standard input.
+//
+// Usage:
+//
+//	configq [flags] query [file]
+//
+// The flags are:
+//
+//	-all           print values of all nodes which match the query
+//	-bool          print the value as a boolean
+//	-default value print the value if the query does not match
+//	-float         print the value as a float number
+//	-int           print the value as an integer
+//	-json          print the value as JSON
+//
+// The exit code is 0 when the query matches, 1 when it does not match and 2
+// when the configuration cannot be read or parsed, or when a matched value
+// cannot be converted to the requested type.
+package main
+
+import "os"
+
+func main() {
+	os.Exit(query(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
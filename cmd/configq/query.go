@@ -0,0 +1,135 @@
+package main
+
+import "encoding/json"
+import "flag"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "io"
+import "strconv"
+
+const (
+	exitFound    = 0
+	exitNotFound = 1
+	exitError    = 2
+)
+
+// options are the parsed flags of the command.
+type options struct {
+	all      bool
+	asBool   bool
+	asFloat  bool
+	asInt    bool
+	asJSON   bool
+	defValue string
+	defSet   bool
+}
+
+// query runs the command with the arguments and returns the exit code.
+func query(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	opts := new(options)
+	fs := flag.NewFlagSet("configq", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&opts.all, "all", false, "print values of all nodes which match the query")
+	fs.BoolVar(&opts.asBool, "bool", false, "print the value as a boolean")
+	fs.BoolVar(&opts.asFloat, "float", false, "print the value as a float number")
+	fs.BoolVar(&opts.asInt, "int", false, "print the value as an integer")
+	fs.BoolVar(&opts.asJSON, "json", false, "print the value as JSON")
+	fs.StringVar(&opts.defValue, "default", "", "print the `value` if the query does not match")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: configq [flags] query [file]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return exitError
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "default" {
+			opts.defSet = true
+		}
+	})
+
+	q := fs.Arg(0)
+	file := fs.Arg(1)
+
+	var cfg config.Config
+	var err error
+	if file == "" || file == "-" {
+		cfg, err = config.ParseFromReader(stdin)
+	} else {
+		cfg, err = config.ParseFromFile(file)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "configq: %s\n", err.Error())
+		return exitError
+	}
+
+	var nodes []config.Config
+	if opts.all {
+		nodes = cfg.QueryAll(q)
+	} else if node, ok := cfg.Query(q); ok {
+		nodes = append(nodes, node)
+	}
+
+	values := make([]interface{}, 0, len(nodes))
+	for _, node := range nodes {
+		val, ok := opts.convert(node.Value())
+		if !ok {
+			fmt.Fprintf(stderr, "configq: invalid value %q at %s\n", node.Value(), config.PositionOf(node))
+			return exitError
+		}
+		values = append(values, val)
+	}
+
+	if len(values) == 0 {
+		if !opts.defSet {
+			return exitNotFound
+		}
+		val, ok := opts.convert(opts.defValue)
+		if !ok {
+			fmt.Fprintf(stderr, "configq: invalid default value %q\n", opts.defValue)
+			return exitError
+		}
+		values = append(values, val)
+	}
+
+	if opts.asJSON {
+		var data []byte
+		if opts.all {
+			data, err = json.Marshal(values)
+		} else {
+			data, err = json.Marshal(values[0])
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "configq: %s\n", err.Error())
+			return exitError
+		}
+		fmt.Fprintln(stdout, string(data))
+	} else {
+		for _, val := range values {
+			fmt.Fprintln(stdout, val)
+		}
+	}
+
+	return exitFound
+}
+
+// convert returns the value converted to the type requested by flags, it
+// reports false when the value cannot be converted.
+func (this *options) convert(str string) (val interface{}, ok bool) {
+	var err error
+	switch {
+	case this.asBool:
+		val, err = strconv.ParseBool(str)
+	case this.asFloat:
+		val, err = strconv.ParseFloat(str, 64)
+	case this.asInt:
+		val, err = strconv.ParseInt(str, 10, 64)
+	default:
+		val = str
+	}
+	return val, err == nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import "bytes"
+import "strings"
+import "testing"
+
+func TestQuery(t *testing.T) {
+
+	var src = "Name test\n" +
+		"Port 8080\n" +
+		"Host a\n" +
+		"Host b\n" +
+		"Ratio 0.5\n" +
+		"Enabled true\n"
+
+	var tests = []struct {
+		args   []string
+		code   int
+		stdout string
+		stderr string
+	}{
+		{[]string{"Name"}, exitFound, "test\n", ""},
+		{[]string{"-int", "Port"}, exitFound, "8080\n", ""},
+		{[]string{"-bool", "-json", "Enabled"}, exitFound, "true\n", ""},
+		{[]string{"-all", "-json", "Host"}, exitFound, "[\"a\",\"b\"]\n", ""},
+		{[]string{"Missing"}, exitNotFound, "", ""},
+		{[]string{"-default", "x", "Missing"}, exitFound, "x\n", ""},
+		{[]string{"-int", "-default", "x", "Missing"}, exitError, "", "configq: invalid default value \"x\"\n"},
+		{[]string{"-int", "Name"}, exitError, "", "configq: invalid value \"test\" at 1:1\n"},
+		{[]string{"-float", "Ratio"}, exitFound, "0.5\n", ""},
+		{[]string{"-all", "-int", "Host"}, exitError, "", "configq: invalid value \"a\" at 3:1\n"},
+		{[]string{}, exitError, "", "usage: configq"},
+		{[]string{"-unknown", "Name"}, exitError, "", "flag provided but not defined"},
+		{[]string{"Name", "missing.conf"}, exitError, "", "configq: "},
+	}
+
+	for _, test := range tests {
+		stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+		code := query(test.args, strings.NewReader(src), stdout, stderr)
+		if code != test.code || stdout.String() != test.stdout || !strings.HasPrefix(stderr.String(), test.stderr) || (test.stderr == "" && stderr.Len() > 0) {
+			t.Errorf("Invalid result for %v: %d %q %q", test.args, code, stdout.String(), stderr.String())
+			t.Fail()
+		}
+	}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	if code := query([]string{"Name"}, strings.NewReader("Broken {\n"), stdout, stderr); code != exitError {
+		t.Errorf("Expected exit code %d for invalid config, got %d", exitError, code)
+		t.Fail()
+	}
+
+}
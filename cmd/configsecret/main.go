@@ -0,0 +1,251 @@
+// Command configsecret encrypts, decrypts and rotates secret values in
+// configuration files.
+//
+// Usage:
+//
+//	configsecret keygen [-x25519]
+//	configsecret encrypt [key flags] FILE QUERY [VALUE]
+//	configsecret decrypt [key flags] FILE QUERY
+//	configsecret rotate [key flags] [new key flags] FILE
+//
+// The key flags are:
+//
+//	-key-file file       symmetric key from the file
+//	-key-env name        symmetric key from the environment variable
+//	-identity-file file  X25519 identity from the file
+//	-recipient key       X25519 recipient, only for encryption
+//
+// The new key flags are the same flags prefixed with "new-", rotate
+// re-encrypts all encrypted values with the new key. Encrypt reads the value
+// from the standard input if it is not given. Files are modified in place
+// like by the config command, only the affected values change.
+package main
+
+import "bytes"
+import "errors"
+import "flag"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "github.com/twoleds-golang/config/internal/atomicfile"
+import "io/ioutil"
+import "os"
+import "strings"
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	var err error
+	switch os.Args[1] {
+	case "keygen":
+		err = keygen(os.Args[2:])
+	case "encrypt":
+		err = encrypt(os.Args[2:])
+	case "decrypt":
+		err = decrypt(os.Args[2:])
+	case "rotate":
+		err = rotate(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "configsecret: %s\n", err.Error())
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: configsecret keygen [-x25519]")
+	fmt.Fprintln(os.Stderr, "       configsecret encrypt [key flags] FILE QUERY [VALUE]")
+	fmt.Fprintln(os.Stderr, "       configsecret decrypt [key flags] FILE QUERY")
+	fmt.Fprintln(os.Stderr, "       configsecret rotate [key flags] [new key flags] FILE")
+	os.Exit(2)
+}
+
+// keyFlags registers flags of a key provider with the prefix.
+type keyFlags struct {
+	keyFile      *string
+	keyEnv       *string
+	identityFile *string
+	recipient    *string
+}
+
+func newKeyFlags(fs *flag.FlagSet, prefix string) *keyFlags {
+	f := new(keyFlags)
+	f.keyFile = fs.String(prefix+"key-file", "", "symmetric key from the file")
+	f.keyEnv = fs.String(prefix+"key-env", "", "symmetric key from the environment variable")
+	f.identityFile = fs.String(prefix+"identity-file", "", "X25519 identity from the file")
+	f.recipient = fs.String(prefix+"recipient", "", "X25519 recipient, only for encryption")
+	return f
+}
+
+func (this *keyFlags) provider() (config.KeyProvider, error) {
+	switch {
+	case *this.keyFile != "":
+		return config.FileKey(*this.keyFile)
+	case *this.keyEnv != "":
+		return config.EnvKey(*this.keyEnv)
+	case *this.identityFile != "":
+		data, err := ioutil.ReadFile(*this.identityFile)
+		if err != nil {
+			return nil, err
+		}
+		return config.X25519Identity(string(data))
+	case *this.recipient != "":
+		return config.X25519Recipient(*this.recipient)
+	}
+	return nil, errors.New("missing key flags")
+}
+
+func parse(args []string, name string, minArgs int, maxArgs int, prefixes ...string) (*flag.FlagSet, []*keyFlags) {
+	fs := flag.NewFlagSet("configsecret "+name, flag.ExitOnError)
+	keys := make([]*keyFlags, len(prefixes))
+	for index, prefix := range prefixes {
+		keys[index] = newKeyFlags(fs, prefix)
+	}
+	fs.Parse(args)
+	if fs.NArg() < minArgs || fs.NArg() > maxArgs {
+		usage()
+	}
+	return fs, keys
+}
+
+func keygen(args []string) error {
+	fs := flag.NewFlagSet("configsecret keygen", flag.ExitOnError)
+	x25519 := fs.Bool("x25519", false, "generate an X25519 identity and its recipient")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		usage()
+	}
+	if *x25519 {
+		identity, recipient, err := config.GenerateX25519()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("# recipient: %s\n%s\n", recipient, identity)
+		return nil
+	}
+	key, err := config.GenerateKey()
+	if err != nil {
+		return err
+	}
+	fmt.Println(key)
+	return nil
+}
+
+func encrypt(args []string) error {
+	fs, keys := parse(args, "encrypt", 2, 3, "")
+	p, err := keys[0].provider()
+	if err != nil {
+		return err
+	}
+	val := fs.Arg(2)
+	if fs.NArg() == 2 {
+		data, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		val = strings.TrimRight(string(data), "\r\n")
+	}
+	enc, err := config.EncryptValue(p, val)
+	if err != nil {
+		return err
+	}
+	return edit(fs.Arg(0), func(e config.Editor) error {
+		return e.Set(fs.Arg(1), enc)
+	})
+}
+
+func decrypt(args []string) error {
+	fs, keys := parse(args, "decrypt", 2, 2, "")
+	p, err := keys[0].provider()
+	if err != nil {
+		return err
+	}
+	cfg, err := config.ParseFromFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	val, ok := cfg.String(fs.Arg(1))
+	if !ok {
+		return fmt.Errorf("query '%s' does not match", fs.Arg(1))
+	}
+	plain, err := config.DecryptValue(p, val)
+	if err != nil {
+		return err
+	}
+	fmt.Println(plain)
+	return nil
+}
+
+func rotate(args []string) error {
+	fs, keys := parse(args, "rotate", 1, 1, "", "new-")
+	oldKey, err := keys[0].provider()
+	if err != nil {
+		return err
+	}
+	newKey, err := keys[1].provider()
+	if err != nil {
+		return err
+	}
+	file := fs.Arg(0)
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.ParseFromBytes(src)
+	if err != nil {
+		return fmt.Errorf("%s: %s", file, err.Error())
+	}
+	// Encrypted values are unique thanks to random nonces, so they are
+	// replaced directly in the source.
+	count := 0
+	var walk func(cfg config.Config) error
+	walk = func(cfg config.Config) error {
+		for _, child := range config.Children(cfg) {
+			if config.IsSection(child) {
+				if err := walk(child); err != nil {
+					return err
+				}
+				continue
+			}
+			if !config.IsSecret(child.Value()) {
+				continue
+			}
+			plain, err := config.DecryptValue(oldKey, child.Value())
+			if err != nil {
+				return fmt.Errorf("%s:%s: %s", file, config.PositionOf(child), err.Error())
+			}
+			enc, err := config.EncryptValue(newKey, plain)
+			if err != nil {
+				return err
+			}
+			src = bytes.Replace(src, []byte(child.Value()), []byte(enc), 1)
+			count++
+		}
+		return nil
+	}
+	if err := walk(cfg); err != nil {
+		return err
+	}
+	if _, err := config.ParseFromBytes(src); err != nil {
+		return fmt.Errorf("%s: %s", file, err.Error())
+	}
+	fmt.Fprintf(os.Stderr, "configsecret: %d values rotated\n", count)
+	return atomicfile.WriteFile(file, src)
+}
+
+func edit(file string, fn func(e config.Editor) error) error {
+	src, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	e, err := config.NewEditor(src)
+	if err != nil {
+		return fmt.Errorf("%s: %s", file, err.Error())
+	}
+	if err := fn(e); err != nil {
+		return fmt.Errorf("%s: %s", file, err.Error())
+	}
+	return atomicfile.WriteFile(file, e.Bytes())
+}
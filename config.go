@@ -1,5 +1,7 @@
 package config
 
+import "fmt"
+import "path/filepath"
 import "strconv"
 import "strings"
 
@@ -36,13 +38,119 @@ type Config interface {
 	Value() string
 }
 
+// Node is a configuration node which gives access to the tree, see Children,
+// Comments, IsSection and PositionOf.
+type Node interface {
+	Config
+	// Children returns all child nodes of this configuration node.
+	Children() []Config
+	// Comments returns text of comments attached to this configuration node,
+	// i.e. comment lines which precede the node and a comment on its line.
+	Comments() []string
+	// IsSection reports whether this configuration node is a section.
+	IsSection() bool
+	// Position returns location of this configuration node in the source.
+	Position() Position
+}
+
+// Children returns child nodes of the node, nil if it doesn't implement Node.
+func Children(cfg Config) []Config {
+	if node, ok := cfg.(Node); ok {
+		return node.Children()
+	}
+	return nil
+}
+
+// Comments returns comments of the node, nil if it doesn't implement Node.
+func Comments(cfg Config) []string {
+	if node, ok := cfg.(Node); ok {
+		return node.Comments()
+	}
+	return nil
+}
+
+// IsSection reports whether the node is a section, nodes which don't
+// implement Node are keys.
+func IsSection(cfg Config) bool {
+	if node, ok := cfg.(Node); ok {
+		return node.IsSection()
+	}
+	return false
+}
+
+// PositionOf returns the position of the node, it is unknown if the node
+// doesn't implement Node.
+func PositionOf(cfg Config) Position {
+	if node, ok := cfg.(Node); ok {
+		return node.Position()
+	}
+	return Position{}
+}
+
+// Position describes location of a configuration node in the source, lines
+// and columns are numbered from 1. Zero position means unknown location.
+type Position struct {
+	// Source describes the origin of the node, e.g. the name of a file, an
+	// environment variable ("env:APP_PORT") or a flag ("flag:-Port").
+	Source string
+	// Layer is the name of the file or loader source which provided the
+	// node, if it differs from Source, e.g. the file which included the
+	// source file.
+	Layer  string
+	Line   int
+	Column int
+}
+
+// String returns the position as "source:line:column", parts which are
+// unknown are omitted and the layer is appended in parentheses.
+func (this Position) String() string {
+	str := fmt.Sprintf("%d:%d", this.Line, this.Column)
+	if this.Line != 0 && this.Column == 0 {
+		str = fmt.Sprintf("%d", this.Line)
+	}
+	if this.Source != "" && this.Line == 0 {
+		str = this.Source
+	} else if this.Source != "" {
+		str = this.Source + ":" + str
+	}
+	if this.Layer != "" && this.Layer != this.Source {
+		str = str + " (" + this.Layer + ")"
+	}
+	return str
+}
+
+// IsZero reports whether the position is unknown.
+func (this Position) IsZero() bool {
+	return this == Position{}
+}
+
 type config struct {
 	name     string
 	value    string
 	children []*config
+	pos      Position
+	// lead holds raw comment lines ("#...") and blank lines ("") which
+	// precede this node, comment holds a comment on the same line and
+	// trailer holds comment and blank lines before the end of a section.
+	lead    []string
+	comment string
+	trailer []string
+	// start and end are byte offsets of the node in the parsed source, the
+	// end of a section is the offset just after its closing brace.
+	start int
+	end   int
+	// fold marks nodes whose names and values are matched case-insensitively
+	// by queries, e.g. nodes created from environment variables.
+	fold bool
+	// secret holds the encrypted value of a decrypted secret, it is written
+	// instead of the value, see StoredValue.
+	secret string
+	// extends holds the query of the section which this section inherits
+	// from, see ResolveExtends.
+	extends string
 }
 
-var _ Config = new(config)
+var _ Node = new(config)
 
 func (this *config) Bool(query string) (val bool, found bool) {
 	if str, ok := this.String(query); ok {
@@ -60,6 +168,33 @@ func (this *config) BoolOrDefault(query string, defVal bool) (val bool) {
 	return defVal
 }
 
+func (this *config) Children() []Config {
+	cfgs := make([]Config, len(this.children))
+	for key, child := range this.children {
+		cfgs[key] = child
+	}
+	return cfgs
+}
+
+func (this *config) Comments() []string {
+	comments := make([]string, 0, len(this.lead)+1)
+	for _, line := range this.lead {
+		if line != "" {
+			comments = append(comments, this.commentText(line))
+		}
+	}
+	if this.comment != "" {
+		comments = append(comments, this.commentText(this.comment))
+	}
+	return comments
+}
+
+// commentText returns text of a raw comment without the leading '#' and
+// the space which follows it.
+func (this *config) commentText(raw string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(raw, "#"), " ")
+}
+
 func (this *config) Float(query string) (val float64, found bool) {
 	if str, ok := this.String(query); ok {
 		if val, err := strconv.ParseFloat(str, 64); err == nil {
@@ -92,10 +227,71 @@ func (this *config) IntOrDefault(query string, defVal int64) (val int64) {
 	return defVal
 }
 
+func (this *config) IsSection() bool {
+	return this.children != nil
+}
+
+// matchName reports whether the node has the specified name.
+func (this *config) matchName(name string) bool {
+	return this.name == name || (this.fold && strings.EqualFold(this.name, name))
+}
+
+// matchValue reports whether the node has the specified value.
+func (this *config) matchValue(val string) bool {
+	return this.value == val || (this.fold && strings.EqualFold(this.value, val))
+}
+
 func (this *config) Name() string {
 	return this.name
 }
 
+func (this *config) Position() Position {
+	return this.pos
+}
+
+// locate prefixes the description of the node, e.g. its path, with its
+// position if it is known.
+func (this *config) locate(desc string) string {
+	if this.pos.IsZero() {
+		return desc
+	}
+	return this.pos.String() + ": " + desc
+}
+
+// setSource sets the source of the nodes whose source is unknown, nodes
+// which already have a source get the layer.
+func (this *config) setSource(source string, layer string) {
+	for _, child := range this.children {
+		if child.pos.Source == "" {
+			child.pos.Source = source
+		} else if child.pos.Layer == "" && child.pos.Source != source {
+			child.pos.Layer = layer
+		}
+		child.setSource(source, layer)
+	}
+}
+
+// rebase joins the directory with sources and layers of the nodes.
+func (this *config) rebase(dir string) {
+	for _, child := range this.children {
+		if child.pos.Source != "" {
+			child.pos.Source = filepath.Join(dir, child.pos.Source)
+		}
+		if child.pos.Layer != "" {
+			child.pos.Layer = filepath.Join(dir, child.pos.Layer)
+		}
+		child.rebase(dir)
+	}
+}
+
+// setLayer sets the layer of the node and all its descendants.
+func (this *config) setLayer(layer string) {
+	for _, child := range this.children {
+		child.pos.Layer = layer
+		child.setLayer(layer)
+	}
+}
+
 func (this *config) Query(path string) (cfg Config, found bool) {
 	query, conds := this.parse(path)
 	return this.queryLoop(query, conds, 0)
@@ -103,8 +299,8 @@ func (this *config) Query(path string) (cfg Config, found bool) {
 
 func (this *config) queryLoop(query []string, conds []string, level int) (cfg *config, found bool) {
 	for _, child := range this.children {
-		if child.name == query[level] {
-			if conds[level] == "*" || conds[level] == child.value {
+		if child.matchName(query[level]) {
+			if conds[level] == "*" || child.matchValue(conds[level]) {
 				if level == (len(query) - 1) {
 					return child, true
 				} else {
@@ -123,8 +319,8 @@ func (this *config) QueryAll(path string) (cfgs []Config) {
 
 func (this *config) queryAllLoop(query []string, conds []string, level int, cfgs []Config) []Config {
 	for _, child := range this.children {
-		if child.name == query[level] {
-			if conds[level] == "*" || conds[level] == child.value {
+		if child.matchName(query[level]) {
+			if conds[level] == "*" || child.matchValue(conds[level]) {
 				if level == (len(query) - 1) {
 					cfgs = append(cfgs, child)
 				} else {
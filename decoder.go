@@ -0,0 +1,193 @@
+package config
+
+import "io"
+
+// Token is a part of configuration data read by a Decoder, one of
+// SectionStart, SectionEnd, KeyValue, Comment and BlankLine.
+type Token interface {
+	// Position returns the line and column where the token starts.
+	Position() Position
+}
+
+// SectionStart starts a section, e.g. "Server Main {". Start is the byte
+// offset of the name in the input.
+type SectionStart struct {
+	Name    string
+	Value   string
+	Extends string
+	Pos     Position
+	Start   int
+}
+
+// SectionEnd ends the last started section, End is the byte offset just
+// after the closing brace.
+type SectionEnd struct {
+	Pos Position
+	End int
+}
+
+// KeyValue is a key with its value, Start and End are byte offsets of the
+// key in the input.
+type KeyValue struct {
+	Name  string
+	Value string
+	Pos   Position
+	Start int
+	End   int
+}
+
+// Comment is a comment line including the leading '#', it is inline if it
+// follows a key or a section start on the same line.
+type Comment struct {
+	Text   string
+	Inline bool
+	Pos    Position
+}
+
+// BlankLine is an empty line which separates keys or sections.
+type BlankLine struct {
+	Pos Position
+}
+
+func (this SectionStart) Position() Position {
+	return this.Pos
+}
+
+func (this SectionEnd) Position() Position {
+	return this.Pos
+}
+
+func (this KeyValue) Position() Position {
+	return this.Pos
+}
+
+func (this Comment) Position() Position {
+	return this.Pos
+}
+
+func (this BlankLine) Position() Position {
+	return this.Pos
+}
+
+// Decoder reads configuration data token by token without building the
+// whole tree in memory, e.g. for very large generated files.
+type Decoder interface {
+	// Next returns the next token, io.EOF at the end of the input or a
+	// *ParseError. Errors are permanent, once an error is returned it is
+	// returned by all following calls.
+	Next() (Token, error)
+	// Skip skips the rest of the current section including its SectionEnd,
+	// e.g. right after a SectionStart which is not interesting. Nodes of
+	// skipped sections are not built, so skipping is cheap. Skip at the top
+	// level skips the rest of the input.
+	Skip() error
+	// Depth returns the number of sections started and not ended by tokens
+	// returned so far.
+	Depth() int
+}
+
+// tokenKind is the kind of a token, see token.
+type tokenKind uint8
+
+const (
+	tokenSectionStart tokenKind = iota
+	tokenSectionEnd
+	tokenKeyValue
+	tokenComment
+	tokenBlankLine
+)
+
+// token is a token of the parser, it is converted to a Token only by the
+// Decoder, so building a tree doesn't allocate tokens. Comments keep their
+// text in the value.
+type token struct {
+	kind    tokenKind
+	name    string
+	value   string
+	extends string
+	pos     Position
+	start   int
+	end     int
+	inline  bool
+}
+
+// public returns the token as a Token.
+func (this *token) public() Token {
+	switch this.kind {
+	case tokenSectionStart:
+		return SectionStart{Name: this.name, Value: this.value, Extends: this.extends, Pos: this.pos, Start: this.start}
+	case tokenSectionEnd:
+		return SectionEnd{Pos: this.pos, End: this.end}
+	case tokenKeyValue:
+		return KeyValue{Name: this.name, Value: this.value, Pos: this.pos, Start: this.start, End: this.end}
+	case tokenComment:
+		return Comment{Text: this.value, Inline: this.inline, Pos: this.pos}
+	}
+	return BlankLine{Pos: this.pos}
+}
+
+type decoder struct {
+	p     *parser
+	depth int
+}
+
+// NewDecoder returns a decoder which reads configuration data from the
+// reader.
+func NewDecoder(reader io.Reader) Decoder {
+	return &decoder{p: newParser(reader)}
+}
+
+func (this *decoder) Next() (Token, error) {
+	tok, err := this.next()
+	if err != nil {
+		return nil, err
+	}
+	return tok.public(), nil
+}
+
+// next returns the next token of the parser and tracks the depth.
+func (this *decoder) next() (token, error) {
+	tok, err := this.p.next()
+	if err != nil {
+		return tok, err
+	}
+	switch tok.kind {
+	case tokenSectionStart:
+		this.depth = this.depth + 1
+	case tokenSectionEnd:
+		this.depth = this.depth - 1
+	}
+	return tok, nil
+}
+
+func (this *decoder) Skip() error {
+	this.p.discard = true
+	defer func() { this.p.discard = false }()
+	target := this.depth - 1
+	for this.depth > target {
+		if _, err := this.next(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *decoder) Depth() int {
+	return this.depth
+}
+
+// decode builds configuration data from all tokens of the decoder.
+func decode(d *decoder) (Config, error) {
+	b := newBuilder()
+	for {
+		tok, err := d.next()
+		if err == io.EOF {
+			return b.Config(), nil
+		} else if err != nil {
+			return nil, err
+		}
+		b.token(&tok)
+	}
+}
@@ -0,0 +1,87 @@
+package config_test
+
+import "github.com/twoleds-golang/config"
+import "io"
+import "strings"
+import "testing"
+
+func TestDecoder(t *testing.T) {
+
+	var src = "# Routes\n" +
+		"Name main\n" +
+		"\n" +
+		"Route One {\n" +
+		"    Path \"/one\" # first\n" +
+		"    Nested {\n" +
+		"        Key 1\n" +
+		"    }\n" +
+		"}\n" +
+		"Route Two extends Route:One {\n" +
+		"    Path \"/two\"\n" +
+		"}\n" +
+		"Last true\n"
+
+	d := config.NewDecoder(strings.NewReader(src))
+	var tokens []string
+	for {
+		tok, err := d.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Errorf("Cannot decode: %s", err.Error())
+			t.FailNow()
+		}
+		switch tok := tok.(type) {
+		case config.SectionStart:
+			tokens = append(tokens, "start "+tok.Name+" "+tok.Value+" "+tok.Extends)
+			if tok.Name == "Nested" {
+				if err := d.Skip(); err != nil || d.Depth() != 1 {
+					t.Errorf("Cannot skip section: %v", err)
+					t.Fail()
+				}
+			}
+		case config.SectionEnd:
+			tokens = append(tokens, "end "+tok.Pos.String())
+		case config.KeyValue:
+			tokens = append(tokens, "key "+tok.Name+" "+tok.Value+" "+tok.Pos.String())
+		case config.Comment:
+			if tok.Inline {
+				tokens = append(tokens, "inline "+tok.Text)
+			} else {
+				tokens = append(tokens, "comment "+tok.Text)
+			}
+		case config.BlankLine:
+			tokens = append(tokens, "blank")
+		}
+	}
+
+	expected := []string{
+		"comment # Routes",
+		"key Name main 2:1",
+		"blank",
+		"start Route One ",
+		"key Path /one 5:5",
+		"inline # first",
+		"start Nested  ",
+		"end 9:1",
+		"start Route Two Route:One",
+		"key Path /two 11:5",
+		"end 12:1",
+		"key Last true 13:1",
+	}
+	if strings.Join(tokens, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Invalid tokens:\n%s", strings.Join(tokens, "\n"))
+		t.Fail()
+	}
+
+	d = config.NewDecoder(strings.NewReader("Skipped 1\nSection {\n    Key 1\n"))
+	if err := d.Skip(); err == nil || err.Error() != "Unexpected end of file on line 4 at column 1" {
+		t.Errorf("Expected error for unterminated section, got %v", err)
+		t.Fail()
+	}
+	if _, err := d.Next(); err == nil {
+		t.Error("Decoder errors must be permanent")
+		t.Fail()
+	}
+
+}
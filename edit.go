@@ -0,0 +1,250 @@
+package config
+
+import "bytes"
+import "errors"
+import "fmt"
+import "strings"
+
+// Editor performs minimal in-place modifications of configuration data. All
+// comments and the formatting of untouched parts are preserved. Every
+// modification is validated by parsing the result, an invalid result is
+// rejected and the data are left unchanged.
+type Editor interface {
+	// AddSection appends a new empty section for the specified query, e.g.
+	// "Server:Backup" adds "Server Backup {}" to the root. All parent
+	// sections of the query have to exist.
+	AddSection(query string) error
+	// Bytes returns the modified configuration data.
+	Bytes() []byte
+	// Delete removes the first node which matches the specified query.
+	Delete(query string) error
+	// Set changes the value of the first key which matches the specified
+	// query. The key is appended to its parent section if it doesn't exist.
+	Set(query string, val string) error
+}
+
+type editor struct {
+	src  []byte
+	root *config
+}
+
+var _ Editor = new(editor)
+
+// NewEditor returns an editor for the specified configuration data.
+func NewEditor(src []byte) (Editor, error) {
+	e := new(editor)
+	if err := e.update(src); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func (this *editor) AddSection(query string) error {
+	path, name, val, err := this.lookupParent(query)
+	if err != nil {
+		return err
+	}
+	parent := this.root
+	if len(path) > 0 {
+		parent = path[len(path)-1]
+	}
+	for _, child := range parent.children {
+		if child.children != nil && child.name == name && child.value == val {
+			return errors.New(fmt.Sprintf("Section '%s' already exists", query))
+		}
+	}
+	buf := new(bytes.Buffer)
+	w := newWriter(buf, "")
+	w.wNameValue(name, val).wSpace().wSectionStart().wSectionEnd()
+	w.Flush()
+	return this.insert(path, buf.String())
+}
+
+func (this *editor) Bytes() []byte {
+	return this.src
+}
+
+func (this *editor) Delete(query string) error {
+	path := this.lookup(query)
+	if path == nil {
+		return errors.New(fmt.Sprintf("Query '%s' does not match", query))
+	}
+	node := path[len(path)-1]
+	start, end := node.start, node.end
+	if lineStart := this.lineStart(start); this.isBlank(this.src[lineStart:start]) {
+		lineEnd := this.lineEnd(end)
+		if rest := bytes.TrimLeft(this.src[end:lineEnd], " \t\r"); len(rest) == 0 || rest[0] == '#' {
+			start = lineStart
+			end = lineEnd
+			if end < len(this.src) {
+				end = end + 1
+			}
+		}
+	}
+	return this.replace(start, end, "")
+}
+
+func (this *editor) Set(query string, val string) error {
+	if path := this.lookup(query); path != nil {
+		node := path[len(path)-1]
+		if node.children != nil {
+			return errors.New(fmt.Sprintf("Query '%s' matches a section", query))
+		}
+		return this.replace(node.start, node.end, this.format(node.name, val))
+	}
+	path, name, cond, err := this.lookupParent(query)
+	if err != nil {
+		return err
+	}
+	if cond != "" {
+		return errors.New(fmt.Sprintf("Query '%s' does not match", query))
+	}
+	return this.insert(path, this.format(name, val))
+}
+
+func (this *editor) format(name string, val string) string {
+	buf := new(bytes.Buffer)
+	w := newWriter(buf, "")
+	w.wNameValue(name, val)
+	w.Flush()
+	return buf.String()
+}
+
+// insert adds a line at the end of the last section of the specified path
+// or at the end of the data if the path is empty.
+func (this *editor) insert(path []*config, line string) error {
+	if len(path) == 0 {
+		indent := ""
+		if len(this.root.children) > 0 {
+			indent = this.indentation(this.root.children[len(this.root.children)-1].start)
+		}
+		pos := len(this.src)
+		if pos > 0 && this.src[pos-1] != '\n' {
+			line = "\n" + indent + line + "\n"
+		} else {
+			line = indent + line + "\n"
+		}
+		return this.replace(pos, pos, line)
+	}
+	parent := path[len(path)-1]
+	brace := parent.end - 1
+	indent := this.indentation(brace)
+	childIndent := indent + this.indentUnit(this.root)
+	if len(parent.children) > 0 {
+		childIndent = this.indentation(parent.children[len(parent.children)-1].start)
+	}
+	if lineStart := this.lineStart(brace); this.isBlank(this.src[lineStart:brace]) {
+		return this.replace(lineStart, lineStart, childIndent+line+"\n")
+	}
+	return this.replace(brace, brace, "\n"+childIndent+line+"\n"+indent)
+}
+
+func (this *editor) replace(start int, end int, text string) error {
+	src := make([]byte, 0, len(this.src)-(end-start)+len(text))
+	src = append(src, this.src[:start]...)
+	src = append(src, text...)
+	src = append(src, this.src[end:]...)
+	return this.update(src)
+}
+
+func (this *editor) update(src []byte) error {
+	p := newBytesParser(src)
+	if err := p.parse(); err != nil {
+		return err
+	}
+	this.src = src
+	this.root = p.config().(*config)
+	return nil
+}
+
+// lookup returns nodes on the path to the first node which matches the
+// query, the semantic is the same as in Config.Query.
+func (this *editor) lookup(query string) []*config {
+	names, conds := this.root.parse(query)
+	path := make([]*config, 0, len(names))
+	node := this.root
+	for level := range names {
+		var next *config
+		for _, child := range node.children {
+			if child.name == names[level] && (conds[level] == "*" || conds[level] == child.value) {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		path = append(path, next)
+		node = next
+	}
+	return path
+}
+
+// lookupParent returns the path to the parent section of the query and the
+// name and value of the last part of the query.
+func (this *editor) lookupParent(query string) (path []*config, name string, val string, err error) {
+	parent := ""
+	if index := strings.LastIndexByte(query, '/'); index >= 0 {
+		parent = query[:index]
+		query = query[index+1:]
+	}
+	name = query
+	if index := strings.IndexByte(query, ':'); index >= 0 {
+		name = query[:index]
+		val = query[index+1:]
+	}
+	if parent != "" {
+		if path = this.lookup(parent); path == nil || path[len(path)-1].children == nil {
+			return nil, "", "", errors.New(fmt.Sprintf("Section '%s' does not exist", parent))
+		}
+	}
+	return path, name, val, nil
+}
+
+func (this *editor) indentation(pos int) string {
+	start := this.lineStart(pos)
+	end := start
+	for end < pos && (this.src[end] == ' ' || this.src[end] == '\t') {
+		end++
+	}
+	return string(this.src[start:end])
+}
+
+// indentUnit returns the indentation which the data add for every level of
+// sections, found at the first child indented deeper than its section, or
+// four spaces like the writer if there is no such child.
+func (this *editor) indentUnit(section *config) string {
+	for _, child := range section.children {
+		if child.children == nil {
+			continue
+		}
+		indent := this.indentation(child.start)
+		for _, nested := range child.children {
+			if nestedIndent := this.indentation(nested.start); len(nestedIndent) > len(indent) && strings.HasPrefix(nestedIndent, indent) {
+				return nestedIndent[len(indent):]
+			}
+		}
+		if unit := this.indentUnit(child); unit != "" {
+			return unit
+		}
+	}
+	if section == this.root {
+		return "    "
+	}
+	return ""
+}
+
+func (this *editor) isBlank(data []byte) bool {
+	return len(bytes.Trim(data, " \t\r")) == 0
+}
+
+func (this *editor) lineEnd(pos int) int {
+	if index := bytes.IndexByte(this.src[pos:], '\n'); index >= 0 {
+		return pos + index
+	}
+	return len(this.src)
+}
+
+func (this *editor) lineStart(pos int) int {
+	return bytes.LastIndexByte(this.src[:pos], '\n') + 1
+}
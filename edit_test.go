@@ -0,0 +1,109 @@
+package config_test
+
+import "github.com/twoleds-golang/config"
+import "testing"
+
+func TestEditor(t *testing.T) {
+
+	var src = "# Server configuration\n" +
+		"Name main\n" +
+		"\n" +
+		"Server Main {\n" +
+		"\tPort 8080 # default port\n" +
+		"\tHost localhost\n" +
+		"}\n"
+
+	e, err := config.NewEditor([]byte(src))
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	if err := e.Set("Server:Main/Port", "9090"); err != nil {
+		t.Errorf("Cannot set value: %s", err.Error())
+		t.Fail()
+	}
+
+	if err := e.Set("Server:Main/Path", "/var/www"); err != nil {
+		t.Errorf("Cannot add value: %s", err.Error())
+		t.Fail()
+	}
+
+	if err := e.Delete("Server/Host"); err != nil {
+		t.Errorf("Cannot delete value: %s", err.Error())
+		t.Fail()
+	}
+
+	if err := e.AddSection("Server:Backup"); err != nil {
+		t.Errorf("Cannot add section: %s", err.Error())
+		t.Fail()
+	}
+
+	if err := e.Set("Server:Backup/Port", "8081"); err != nil {
+		t.Errorf("Cannot add value to new section: %s", err.Error())
+		t.Fail()
+	}
+
+	var expected = "# Server configuration\n" +
+		"Name main\n" +
+		"\n" +
+		"Server Main {\n" +
+		"\tPort 9090 # default port\n" +
+		"\tPath \"/var/www\"\n" +
+		"}\n" +
+		"Server Backup {\n" +
+		"\tPort 8081\n" +
+		"}\n"
+
+	if string(e.Bytes()) != expected {
+		t.Errorf("Invalid edited config:\n%s", e.Bytes())
+		t.Fail()
+	}
+
+	if err := e.Delete("Missing"); err == nil {
+		t.Error("Expected error for missing key")
+		t.Fail()
+	}
+
+	if err := e.Set("Missing/Key", "1"); err == nil {
+		t.Error("Expected error for missing section")
+		t.Fail()
+	}
+
+	if err := e.AddSection("Server:Main"); err == nil {
+		t.Error("Expected error for existing section")
+		t.Fail()
+	}
+
+	src = "Name main\n" +
+		"Server Main {\n" +
+		"  Port 8080\n" +
+		"}\n" +
+		"Cache {\n" +
+		"}\n"
+
+	e, err = config.NewEditor([]byte(src))
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	if err := e.Set("Cache/Size", "10"); err != nil {
+		t.Errorf("Cannot add value: %s", err.Error())
+		t.Fail()
+	}
+
+	expected = "Name main\n" +
+		"Server Main {\n" +
+		"  Port 8080\n" +
+		"}\n" +
+		"Cache {\n" +
+		"  Size 10\n" +
+		"}\n"
+
+	if string(e.Bytes()) != expected {
+		t.Errorf("Indentation is not inferred:\n%s", e.Bytes())
+		t.Fail()
+	}
+
+}
@@ -0,0 +1,166 @@
+package config
+
+import "os"
+import "sort"
+import "strings"
+
+// EnvOptions controls the conversion of environment variables to
+// configuration data.
+type EnvOptions struct {
+	// Environ is the list of variables in the form "NAME=value", the
+	// environment of the process (os.Environ) is used if nil.
+	Environ []string
+	// FoldCase makes names and values of sections created from variables
+	// match queries and other configuration data case-insensitively.
+	FoldCase bool
+	// Reference is optional configuration data, e.g. the parsed file which
+	// the variables override. Parts of names which match values of sections
+	// in the reference are used as values instead of names of sections.
+	Reference Config
+	// Separator separates levels of the tree in names of variables, "__" by
+	// default.
+	Separator string
+	// ValueSeparator separates the name of a section from its value in names
+	// of variables, "___" by default.
+	ValueSeparator string
+}
+
+func (this *EnvOptions) separator() string {
+	if this.Separator == "" {
+		return "__"
+	}
+	return this.Separator
+}
+
+func (this *EnvOptions) valueSeparator() string {
+	if this.ValueSeparator == "" {
+		return "___"
+	}
+	return this.ValueSeparator
+}
+
+// FromEnv returns configuration data created from environment variables with
+// the specified prefix, other variables are ignored. The rest of the name of
+// a variable is split by the separators to names and values of sections and
+// the name of the key, e.g. with the prefix "APP_" the variable
+// "APP_SECTION___TWO__FLOATVALUE=1.5" becomes the key FLOATVALUE in the
+// section "SECTION TWO". Names and values are kept as they are written, so
+// they match "Section:Two/FloatValue" only with FoldCase.
+//
+// Without the value separator, parts of names are names of sections, the
+// variable "APP_SECTION__TWO__FLOATVALUE" becomes the key in the section TWO
+// nested in the section SECTION. Only a reference which contains the section
+// "Section Two" makes it the value of the section.
+//
+// Nil options mean default options. The result is usually merged over parsed
+// configuration data with Merge, so the environment wins. The source of
+// positions of keys is the variable, e.g. "env:APP_PORT".
+func FromEnv(prefix string, opts *EnvOptions) Config {
+	if opts == nil {
+		opts = new(EnvOptions)
+	}
+	environ := opts.Environ
+	if environ == nil {
+		environ = os.Environ()
+	}
+	vars := make([]string, 0, len(environ))
+	for _, env := range environ {
+		if strings.HasPrefix(env, prefix) && strings.IndexByte(env, '=') > len(prefix) {
+			vars = append(vars, env)
+		}
+	}
+	sort.Strings(vars)
+	var ref *config
+	if opts.Reference != nil {
+		ref = cloneConfig(opts.Reference)
+	}
+	root := &config{children: make([]*config, 0, len(vars))}
+	for _, env := range vars {
+		index := strings.IndexByte(env, '=')
+		if path := opts.envPath(env[len(prefix):index], ref); path != nil {
+			root.setEnv(path, env[index+1:], opts.FoldCase).pos = Position{Source: "env:" + env[:index]}
+		}
+	}
+	return root
+}
+
+// envPart is a part of the path of a variable, the name of a section (or of
+// the key if last) and the value of a section.
+type envPart struct {
+	name  string
+	value string
+}
+
+// envPath returns the path of the variable or nil if a name is empty. Names
+// and values are taken from the reference if they match case-insensitively
+// and FoldCase is set.
+func (this *EnvOptions) envPath(name string, ref *config) []envPart {
+	// Value separators are marked first, they may contain the separator.
+	name = strings.Replace(name, this.valueSeparator(), "\x00", -1)
+	parts := strings.Split(name, this.separator())
+	path := make([]envPart, 0, len(parts))
+	for index := 0; index < len(parts); index++ {
+		part := envPart{name: parts[index]}
+		if split := strings.IndexByte(part.name, '\x00'); split >= 0 {
+			part.value = part.name[split+1:]
+			part.name = part.name[:split]
+		} else if index+2 < len(parts) && ref != nil {
+			if section := ref.findEnvSection(part.name, parts[index+1], this.FoldCase); section != nil {
+				part.value = parts[index+1]
+				index++
+			}
+		}
+		if part.name == "" {
+			return nil
+		}
+		if ref != nil {
+			if this.FoldCase {
+				for _, child := range ref.children {
+					if strings.EqualFold(child.name, part.name) {
+						part.name = child.name
+						break
+					}
+				}
+			}
+			if section := ref.findEnvSection(part.name, part.value, this.FoldCase); section != nil && this.FoldCase {
+				part.value = section.value
+			}
+			ref = ref.findEnvSection(part.name, part.value, this.FoldCase)
+		}
+		path = append(path, part)
+	}
+	return path
+}
+
+// findEnvSection returns a child section with the specified name and value.
+func (this *config) findEnvSection(name string, val string, fold bool) *config {
+	for _, child := range this.children {
+		if child.children == nil {
+			continue
+		}
+		if child.name == name && child.value == val {
+			return child
+		}
+		if fold && strings.EqualFold(child.name, name) && strings.EqualFold(child.value, val) {
+			return child
+		}
+	}
+	return nil
+}
+
+// setEnv adds the key on the path and returns it, missing sections are
+// created.
+func (this *config) setEnv(path []envPart, val string, fold bool) *config {
+	node := this
+	for _, part := range path[:len(path)-1] {
+		next := node.findEnvSection(part.name, part.value, false)
+		if next == nil {
+			next = &config{name: part.name, value: part.value, children: make([]*config, 0, 4), fold: fold}
+			node.children = append(node.children, next)
+		}
+		node = next
+	}
+	key := &config{name: path[len(path)-1].name, value: val, fold: fold}
+	node.children = append(node.children, key)
+	return key
+}
@@ -0,0 +1,102 @@
+package config_test
+
+import "github.com/twoleds-golang/config"
+import "testing"
+
+func TestFromEnv(t *testing.T) {
+
+	var src = "Name \"Test String\"\n" +
+		"Host a\n" +
+		"Host b\n" +
+		"Section One {\n" +
+		"    FloatValue 1.0\n" +
+		"}\n" +
+		"Section Two {\n" +
+		"    FloatValue 2.0\n" +
+		"    IntValue 2\n" +
+		"}\n"
+
+	var environ = []string{
+		"HOME=/root",
+		"APP_SECTION__TWO__FLOATVALUE=1.5",
+		"APP_SECTION___THREE__INTVALUE=3",
+		"APP_HOST=c",
+		"APP_NEW__KEY=value",
+		"APP___EMPTY=ignored",
+	}
+
+	base, err := config.ParseFromString(src)
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	env := config.FromEnv("APP_", &config.EnvOptions{Environ: environ, FoldCase: true, Reference: base})
+	if val, ok := env.String("Section:Two/FloatValue"); !ok || val != "1.5" {
+		t.Errorf("Invalid value from environment: %q", val)
+		t.Fail()
+	}
+	if len(config.Children(env)) != 4 {
+		t.Errorf("Invalid number of nodes from environment: %d", len(config.Children(env)))
+		t.Fail()
+	}
+
+	cfg := config.Merge(base, env)
+
+	var tests = map[string]string{
+		"Name":                   "Test String",
+		"Section:One/FloatValue": "1.0",
+		"Section:Two/FloatValue": "1.5",
+		"Section:Two/IntValue":   "2",
+		"Section:Three/IntValue": "3",
+		"New/Key":                "value",
+	}
+
+	for query, expected := range tests {
+		if val, ok := cfg.String(query); !ok || val != expected {
+			t.Errorf("Invalid value for query '%s': %q", query, val)
+			t.Fail()
+		}
+	}
+
+	if hosts := cfg.QueryAll("Host"); len(hosts) != 1 || hosts[0].Value() != "c" || hosts[0].Name() != "Host" {
+		t.Error("Invalid values for query 'Host'")
+		t.Fail()
+	}
+
+	if sections := cfg.QueryAll("Section"); len(sections) != 3 || sections[1].Name() != "Section" {
+		t.Error("Invalid sections after merge")
+		t.Fail()
+	}
+
+	if val, _ := base.String("Section:Two/FloatValue"); val != "2.0" {
+		t.Error("Merge modified the base")
+		t.Fail()
+	}
+
+	environ = []string{"APP_SECTION-TWO.FLOATVALUE=1.5"}
+	plain := config.FromEnv("APP_", &config.EnvOptions{Environ: environ, Separator: ".", ValueSeparator: "-"})
+	if val, ok := plain.String("SECTION:TWO/FLOATVALUE"); !ok || val != "1.5" {
+		t.Errorf("Invalid value with custom separators: %q", val)
+		t.Fail()
+	}
+	if _, ok := plain.String("Section:Two/FloatValue"); ok {
+		t.Error("Names without FoldCase are case-sensitive")
+		t.Fail()
+	}
+
+	// Without a reference two underscores separate nested sections.
+	environ = []string{"APP_SECTION__TWO__FLOATVALUE=1.5", "APP_SECTION___THREE__FLOATVALUE=3.5"}
+	folded := config.FromEnv("APP_", &config.EnvOptions{Environ: environ, FoldCase: true})
+	for query, expected := range map[string]string{"Section/Two/FloatValue": "1.5", "Section:Three/FloatValue": "3.5"} {
+		if val, ok := folded.String(query); !ok || val != expected {
+			t.Errorf("Invalid value without reference for query '%s': %q", query, val)
+			t.Fail()
+		}
+	}
+	if _, ok := folded.String("Section:Two/FloatValue"); ok {
+		t.Error("Value of section without reference")
+		t.Fail()
+	}
+
+}
@@ -0,0 +1,195 @@
+package config
+
+import "bytes"
+import "fmt"
+import "strings"
+
+// Kinds of explanation steps.
+const (
+	// ExplainSource is a value provided by a source, later sources win.
+	ExplainSource = "source"
+	// ExplainInherit is a value inherited from an extended section or from a
+	// section of a @use directive.
+	ExplainInherit = "inherit"
+	// ExplainReference is a value of a reference resolved by interpolation.
+	ExplainReference = "reference"
+	// ExplainInterpolate is the value with resolved references.
+	ExplainInterpolate = "interpolate"
+	// ExplainDecrypt is the decrypted value of a secret.
+	ExplainDecrypt = "decrypt"
+)
+
+// Explanation describes how the value of a key was composed by a loader, see
+// Loader.Explain.
+type Explanation struct {
+	// Query is the explained query.
+	Query string
+	// Value is the final value of the key, Found reports whether the query
+	// matches a key of the loaded configuration data.
+	Value string
+	Found bool
+	// Steps are contributions to the value in order of their application.
+	Steps     []ExplainStep
+	sensitive bool
+}
+
+// ExplainStep is a single contribution to the value of a key.
+type ExplainStep struct {
+	// Kind is one of ExplainSource, ExplainInherit, ExplainReference,
+	// ExplainInterpolate and ExplainDecrypt.
+	Kind string
+	// Source is the name of the loader source of a value, the query of the
+	// inherited section or the query of a reference.
+	Source string
+	// Position is the position of the value or of the referenced key.
+	Position Position
+	// Value is the value after the step.
+	Value string
+}
+
+// source records the value of the query in the configuration data of a
+// source.
+func (this *Explanation) source(name string, cfg *config) {
+	if this == nil {
+		return
+	}
+	if node, ok := cfg.Query(this.Query); ok && !IsSection(node) {
+		this.Steps = append(this.Steps, ExplainStep{Kind: ExplainSource, Source: name, Position: PositionOf(node), Value: node.Value()})
+	}
+}
+
+// inherited records the value inherited by the section of the key, merged are
+// the configuration data before inheritance was resolved.
+func (this *Explanation) inherited(merged Config, cfg Config) {
+	if this == nil {
+		return
+	}
+	after, ok := cfg.Query(this.Query)
+	if !ok || IsSection(after) {
+		return
+	}
+	if before, ok := merged.Query(this.Query); ok && PositionOf(before) == PositionOf(after) {
+		return
+	}
+	// The nearest section on the path which exists before inheritance is the
+	// one which inherits, inherited nodes keep their positions.
+	parts := strings.Split(this.Query, "/")
+	for level := len(parts) - 1; level > 0; level-- {
+		found, ok := merged.Query(strings.Join(parts[:level], "/"))
+		if !ok || !IsSection(found) {
+			continue
+		}
+		section := found.(*config)
+		queries := make([]string, 0, 2)
+		if section.extends != "" {
+			queries = append(queries, section.extends)
+		}
+		for _, child := range section.children {
+			if child.children == nil && child.name == UseDirective {
+				queries = append(queries, child.value)
+			}
+		}
+		// Later sections override earlier ones.
+		rest := strings.Join(parts[level:], "/")
+		for index := len(queries) - 1; index >= 0; index-- {
+			if node, ok := cfg.Query(queries[index] + "/" + rest); ok && PositionOf(node) == PositionOf(after) {
+				this.Steps = append(this.Steps, ExplainStep{Kind: ExplainInherit, Source: queries[index], Position: PositionOf(after), Value: after.Value()})
+				return
+			}
+		}
+		return
+	}
+}
+
+// interpolated records references of the merged value and the interpolated
+// value.
+func (this *Explanation) interpolated(merged Config, cfg Config) {
+	if this == nil {
+		return
+	}
+	before, ok := merged.Query(this.Query)
+	if !ok || IsSection(before) {
+		return
+	}
+	after, _ := cfg.Query(this.Query)
+	if before.Value() == after.Value() {
+		return
+	}
+	val := before.Value()
+	for start := strings.Index(val, "${"); start >= 0; start = strings.Index(val, "${") {
+		end := strings.IndexByte(val[start:], '}')
+		if end < 0 {
+			break
+		}
+		// "$${" is an escaped "${".
+		if start == 0 || val[start-1] != '$' {
+			query := val[start+2 : start+end]
+			if ref, ok := cfg.Query(query); ok {
+				this.Steps = append(this.Steps, ExplainStep{Kind: ExplainReference, Source: query, Position: PositionOf(ref), Value: ref.Value()})
+				this.sensitive = this.sensitive || ref.(*config).secret != "" || DefaultRedactor.IsSensitiveName(ref.Name())
+			}
+		}
+		val = val[start+end+1:]
+	}
+	this.Steps = append(this.Steps, ExplainStep{Kind: ExplainInterpolate, Position: PositionOf(after), Value: after.Value()})
+	this.sensitive = this.sensitive || after.(*config).secret != ""
+}
+
+// decrypted records the decrypted value of a secret.
+func (this *Explanation) decrypted(cfg Config) {
+	if this == nil {
+		return
+	}
+	if node, ok := cfg.Query(this.Query); ok && node.(*config).secret != "" {
+		this.Steps = append(this.Steps, ExplainStep{Kind: ExplainDecrypt, Position: PositionOf(node), Value: node.Value()})
+		this.sensitive = true
+	}
+}
+
+// String returns the explanation for humans, one step per line. Values of
+// sensitive keys are redacted, see Redactor.
+func (this *Explanation) String() string {
+	buf := new(bytes.Buffer)
+	if !this.Found {
+		fmt.Fprintf(buf, "%s is not set\n", this.Query)
+	} else {
+		fmt.Fprintf(buf, "%s = %s\n", this.Query, this.show(this.Value))
+	}
+	last := -1
+	for index, step := range this.Steps {
+		if step.Kind == ExplainSource {
+			last = index
+		}
+	}
+	for index, step := range this.Steps {
+		switch step.Kind {
+		case ExplainSource:
+			where := step.Position.String()
+			if step.Position.Source == "" && step.Position.Line == 0 {
+				where = step.Source
+			}
+			fmt.Fprintf(buf, "  %-11s %s: %s", step.Kind, where, this.show(step.Value))
+			if index != last {
+				buf.WriteString(" (overridden)")
+			}
+		case ExplainInherit:
+			fmt.Fprintf(buf, "  %-11s %s: %s from %s", step.Kind, step.Position, this.show(step.Value), step.Source)
+		case ExplainReference:
+			fmt.Fprintf(buf, "  %-11s ${%s} = %s", step.Kind, step.Source, this.show(step.Value))
+			if !step.Position.IsZero() {
+				fmt.Fprintf(buf, " from %s", step.Position)
+			}
+		default:
+			fmt.Fprintf(buf, "  %-11s %s", step.Kind, this.show(step.Value))
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.String()
+}
+
+func (this *Explanation) show(val string) string {
+	if this.sensitive {
+		return RedactedValue
+	}
+	return fmt.Sprintf("%q", val)
+}
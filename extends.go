@@ -0,0 +1,105 @@
+package config
+
+import "errors"
+import "fmt"
+import "strings"
+
+// UseDirective is the name of keys which copy children of another section
+// into the section, e.g. `@use Server:Defaults`. The query may be quoted,
+// unquoted it may contain any characters of queries.
+const UseDirective = "@use"
+
+// ResolveExtends returns configuration data where sections inherit children
+// of other sections. A section header may name the section it extends by a
+// query, e.g. "Server Two extends Server:Defaults { ... }", and a section
+// may contain @use directives with queries of sections whose children are
+// copied into it. Queries are evaluated from the root.
+//
+// The inherited children come first, the extended section before sections
+// of @use directives in their order, and the own children of the section are
+// merged over them like by Merge, so its keys override inherited keys with
+// the same name. Inherited sections may inherit themselves, cycles are
+// errors. The resolved data are queried by the usual Query and QueryAll,
+// inherited nodes keep their positions.
+func ResolveExtends(cfg Config) (Config, error) {
+	root := cloneConfig(cfg)
+	r := &extender{root: root, done: make(map[*config]bool)}
+	if err := r.resolve(root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+type extender struct {
+	root  *config
+	done  map[*config]bool
+	stack []*config
+}
+
+// resolve resolves inheritance of the section and of its subsections.
+func (this *extender) resolve(section *config) error {
+	if this.done[section] {
+		return nil
+	}
+	for index, parent := range this.stack {
+		if parent == section {
+			names := make([]string, 0, len(this.stack)-index+1)
+			for _, node := range append(this.stack[index:], section) {
+				names = append(names, node.describe())
+			}
+			return errors.New(fmt.Sprintf("Inheritance cycle: %s", strings.Join(names, " -> ")))
+		}
+	}
+	this.stack = append(this.stack, section)
+	defer func() { this.stack = this.stack[:len(this.stack)-1] }()
+
+	queries := make([]string, 0, 2)
+	nodes := make([]*config, 0, 2)
+	if section.extends != "" {
+		queries = append(queries, section.extends)
+		nodes = append(nodes, section)
+	}
+	own := &config{children: make([]*config, 0, len(section.children))}
+	for _, child := range section.children {
+		if child.children == nil && child.name == UseDirective {
+			queries = append(queries, child.value)
+			nodes = append(nodes, child)
+		} else {
+			own.children = append(own.children, child)
+		}
+	}
+	if len(queries) > 0 {
+		inherited := &config{children: make([]*config, 0, 16)}
+		for index, query := range queries {
+			found, ok := this.root.Query(query)
+			if !ok || !IsSection(found) {
+				return errors.New(nodes[index].locate(fmt.Sprintf("Section '%s' does not exist", query)))
+			}
+			base := found.(*config)
+			if err := this.resolve(base); err != nil {
+				return err
+			}
+			inherited.merge(cloneConfig(base))
+		}
+		inherited.merge(own)
+		section.children = inherited.children
+		section.extends = ""
+	}
+	for _, child := range section.children {
+		if child.children != nil {
+			if err := this.resolve(child); err != nil {
+				return err
+			}
+		}
+	}
+	this.done[section] = true
+	return nil
+}
+
+// describe returns the name and the value of the node in the query syntax.
+func (this *config) describe() string {
+	if this.value == "" {
+		return this.name
+	}
+	return this.name + ":" + this.value
+}
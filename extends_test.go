@@ -0,0 +1,107 @@
+package config_test
+
+import "context"
+import "github.com/twoleds-golang/config"
+import "strings"
+import "testing"
+
+var extendsSrc = "Server Defaults {\n" +
+	"    Port 8080\n" +
+	"    Host localhost\n" +
+	"    Limits {\n" +
+	"        Connections 100\n" +
+	"    }\n" +
+	"}\n" +
+	"Logging {\n" +
+	"    Level info\n" +
+	"}\n" +
+	"Server One extends Server:Defaults {\n" +
+	"    Port 8081\n" +
+	"}\n" +
+	"Server Two extends \"Server:One\" {\n" +
+	"    @use Logging\n" +
+	"    Host two.example.org\n" +
+	"}\n" +
+	"Backup extends Server:Defaults {\n" +
+	"}\n"
+
+func TestResolveExtends(t *testing.T) {
+
+	cfg, err := config.ParseFromString(extendsSrc)
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	if text, _ := config.Format([]byte(extendsSrc)); !strings.Contains(string(text), "Server Two extends Server:One {") || !strings.Contains(string(text), "Backup extends Server:Defaults {") {
+		t.Errorf("Invalid formatted extends:\n%s", text)
+		t.Fail()
+	}
+
+	resolved, err := config.ResolveExtends(cfg)
+	if err != nil {
+		t.Errorf("Cannot resolve extends: %s", err.Error())
+		t.FailNow()
+	}
+
+	var tests = map[string]string{
+		"Server:One/Port":                    "8081",
+		"Server:One/Host":                    "localhost",
+		"Server:One/Limits/Connections":      "100",
+		"Server:Two/Port":                    "8081",
+		"Server:Two/Host":                    "two.example.org",
+		"Server:Two/Level":                   "info",
+		"Server:Two/" + config.UseDirective:  "",
+		"Backup/Port":                        "8080",
+		"Server:Defaults/Limits/Connections": "100",
+	}
+
+	for query, expected := range tests {
+		if val := resolved.StringOrDefault(query, ""); val != expected {
+			t.Errorf("Invalid value for query '%s': %q", query, val)
+			t.Fail()
+		}
+	}
+
+	if ports := resolved.QueryAll("Server:*/Port"); len(ports) != 3 {
+		t.Errorf("Invalid number of ports: %d", len(ports))
+		t.Fail()
+	}
+
+	l := config.NewLoader(config.BytesSource("app.conf", []byte(extendsSrc)))
+	if loaded, _, err := l.Load(context.Background()); err != nil || loaded.StringOrDefault("Server:Two/Level", "") != "info" {
+		t.Errorf("Invalid loaded extends: %v", err)
+		t.Fail()
+	}
+
+	cycle, _ := config.ParseFromString("A extends B {\n}\nB extends A {\n}\n")
+	if _, err := config.ResolveExtends(cycle); err == nil || err.Error() != "Inheritance cycle: A -> B -> A" {
+		t.Errorf("Expected error for cycle, got %v", err)
+		t.Fail()
+	}
+
+	for _, use := range []string{"@use Server:Main", "@use \"Server:Main\""} {
+		cfg, err := config.ParseFromString("Server Main {\n    Port 80\n}\nApp {\n    " + use + "\n}\n")
+		if err != nil {
+			t.Errorf("Cannot parse '%s': %s", use, err.Error())
+			t.Fail()
+			continue
+		}
+		if cfg, err = config.ResolveExtends(cfg); err != nil || cfg.IntOrDefault("App/Port", 0) != 80 {
+			t.Errorf("Cannot resolve '%s': %v", use, err)
+			t.Fail()
+		}
+	}
+
+	if _, err := config.ParseFromString("Name Server:Main\n"); err == nil {
+		t.Error("Expected error for query characters in value")
+		t.Fail()
+	}
+
+	missing, _ := config.ParseFromString("A {\n    @use B\n}\n")
+	if _, err := config.ResolveExtends(missing); err == nil || err.Error() != "2:5: Section 'B' does not exist" {
+		t.Errorf("Expected error for missing section, got %v", err)
+		t.Fail()
+	}
+
+}
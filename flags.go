@@ -0,0 +1,297 @@
+package config
+
+import "errors"
+import "flag"
+import "fmt"
+import "strconv"
+import "strings"
+import "time"
+
+// Flags collects configuration data from command-line flags registered by
+// BindFlags.
+type Flags interface {
+	// Config returns configuration data with values of flags which were set
+	// on the command line, it is meant to be merged over configuration data
+	// read from files with Merge. Later flags win over earlier ones. The
+	// source of positions of keys is the flag, e.g. "flag:-set". Parts of
+	// queries without a section value, e.g. Server in "Server/Port", refer
+	// to the first section of the reference with the name like in Query.
+	Config() Config
+	// Apply returns the configuration data with values of flags which were
+	// set on the command line. Queries are resolved like by Query, so
+	// "-set Server/Port=80" changes the first section named Server; missing
+	// keys and sections are added.
+	Apply(cfg Config) Config
+}
+
+type flags struct {
+	queries []string
+	values  []string
+	// sources hold names of flags which set the values, for positions.
+	sources []string
+	ref     *config
+}
+
+var _ Flags = new(flags)
+
+// BindFlags registers the flag "set" in the flag set which accepts overrides
+// in the form "query=value", e.g. -set Section:Two/IntValue=5, and can be
+// repeated. If the reference is not nil, a typed flag named by the query is
+// registered for every key of the reference, e.g. -Section:Two/IntValue=5.
+// The type (bool, int, float or string) follows the value in the reference,
+// comments of the key are used as usage.
+func BindFlags(fs *flag.FlagSet, ref Config) Flags {
+	return BindTypedFlags(fs, ref, nil)
+}
+
+// BindTypedFlags registers flags like BindFlags, types of typed flags are
+// given by paths of keys, i.e. names of sections and of the key without
+// values like "Section/IntValue", e.g. from Types of a schema. The type is
+// bool, int, float, duration or string, keys without a type get the type of
+// their value in the reference.
+func BindTypedFlags(fs *flag.FlagSet, ref Config, types map[string]string) Flags {
+	f := new(flags)
+	fs.Var(&setFlag{f}, "set", "Override a configuration value, e.g. Section:Two/IntValue=5")
+	if ref != nil {
+		f.ref = cloneConfig(ref)
+		f.bind(fs, ref, "", "", types, make(map[string]bool))
+	}
+	return f
+}
+
+// FromArgs returns configuration data with overrides in the form
+// "-set query=value" or "--set=query=value" from the arguments, other
+// arguments are ignored. Arguments after "--" are not scanned.
+func FromArgs(args []string) (Config, error) {
+	f := new(flags)
+	for index := 0; index < len(args); index++ {
+		arg := args[index]
+		if arg == "--" {
+			break
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if name == arg || (name != "set" && !strings.HasPrefix(name, "set=")) {
+			continue
+		}
+		setting := strings.TrimPrefix(name, "set=")
+		if name == "set" {
+			if index+1 >= len(args) {
+				return nil, errors.New(fmt.Sprintf("Missing value of flag '%s'", arg))
+			}
+			index++
+			setting = args[index]
+		}
+		if err := f.setting(setting); err != nil {
+			return nil, err
+		}
+	}
+	return f.Config(), nil
+}
+
+func (this *flags) Config() Config {
+	root := &config{children: make([]*config, 0, len(this.queries))}
+	for index, query := range this.queries {
+		root.set(this.resolve(query), this.values[index]).pos = Position{Source: this.sources[index]}
+	}
+	return root
+}
+
+func (this *flags) Apply(cfg Config) Config {
+	root := cloneConfig(cfg)
+	for index, query := range this.queries {
+		root.set(query, this.values[index]).pos = Position{Source: this.sources[index]}
+	}
+	return root
+}
+
+// resolve adds values of sections of the reference to parts of the query
+// without a value, so the query addresses the same section in Config as in
+// the reference.
+func (this *flags) resolve(query string) string {
+	if this.ref == nil {
+		return query
+	}
+	names, conds := this.ref.parse(query)
+	parts := make([]string, len(names))
+	node := this.ref
+	for level, name := range names {
+		parts[level] = name
+		if conds[level] != "*" {
+			parts[level] = name + ":" + conds[level]
+		}
+		if level == len(names)-1 || node == nil {
+			continue
+		}
+		var next *config
+		for _, child := range node.children {
+			if child.children != nil && child.matchName(name) && (conds[level] == "*" || child.matchValue(conds[level])) {
+				next = child
+				break
+			}
+		}
+		if next != nil && conds[level] == "*" && next.value != "" && !strings.ContainsAny(next.value, "/=") {
+			parts[level] = name + ":" + next.value
+		}
+		node = next
+	}
+	return strings.Join(parts, "/")
+}
+
+// setting adds an override in the form "query=value".
+func (this *flags) setting(str string) error {
+	index := strings.IndexByte(str, '=')
+	if index <= 0 {
+		return errors.New(fmt.Sprintf("Invalid override '%s', expected query=value", str))
+	}
+	this.queries = append(this.queries, str[:index])
+	this.values = append(this.values, str[index+1:])
+	this.sources = append(this.sources, "flag:-set")
+	return nil
+}
+
+// bind registers flags for keys of the section, keys which cannot be
+// addressed by a query or are repeated are registered once. The path is the
+// prefix without values of sections.
+func (this *flags) bind(fs *flag.FlagSet, section Config, prefix string, path string, types map[string]string, done map[string]bool) {
+	for _, child := range Children(section) {
+		if strings.ContainsAny(child.Name(), ":/=") || (IsSection(child) && strings.ContainsAny(child.Value(), "/=")) {
+			continue
+		}
+		query := prefix + child.Name()
+		if IsSection(child) {
+			if child.Value() != "" {
+				query = query + ":" + child.Value()
+			}
+			this.bind(fs, child, query+"/", path+child.Name()+"/", types, done)
+			continue
+		}
+		if done[query] || fs.Lookup(query) != nil {
+			continue
+		}
+		done[query] = true
+		usage := strings.Join(Comments(child), " ")
+		if usage == "" {
+			usage = fmt.Sprintf("Override %s", query)
+		}
+		sensitive := DefaultRedactor.IsSensitiveName(child.Name()) || StoredValue(child) != child.Value()
+		kind := types[path+child.Name()]
+		if kind == "" {
+			kind = this.kind(child.Value())
+		}
+		fs.Var(&keyFlag{flags: this, query: query, value: child.Value(), kind: kind, sensitive: sensitive}, query, usage)
+	}
+}
+
+// kind returns the type of flags for the value: "bool", "int", "float" or
+// "string".
+func (this *flags) kind(val string) string {
+	if val == "true" || val == "false" {
+		return "bool"
+	}
+	if _, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return "int"
+	}
+	if _, err := strconv.ParseFloat(val, 64); err == nil {
+		return "float"
+	}
+	return "string"
+}
+
+// setFlag implements the flag "set".
+type setFlag struct {
+	flags *flags
+}
+
+func (this *setFlag) Set(str string) error {
+	return this.flags.setting(str)
+}
+
+func (this *setFlag) String() string {
+	return ""
+}
+
+// keyFlag implements a typed flag of a key. Values of sensitive keys are
+// not shown in usage and errors.
+type keyFlag struct {
+	flags     *flags
+	query     string
+	value     string
+	kind      string
+	sensitive bool
+}
+
+func (this *keyFlag) IsBoolFlag() bool {
+	return this.kind == "bool"
+}
+
+func (this *keyFlag) Set(str string) error {
+	var err error
+	switch this.kind {
+	case "bool":
+		var val bool
+		if val, err = strconv.ParseBool(str); err == nil {
+			str = strconv.FormatBool(val)
+		}
+	case "int":
+		_, err = strconv.ParseInt(str, 10, 64)
+	case "float":
+		_, err = strconv.ParseFloat(str, 64)
+	case "duration":
+		_, err = time.ParseDuration(str)
+	}
+	if err != nil && this.sensitive {
+		return errors.New(fmt.Sprintf("Invalid %s value %s", this.kind, RedactedValue))
+	} else if err != nil {
+		return errors.New(fmt.Sprintf("Invalid %s value '%s'", this.kind, str))
+	}
+	this.value = str
+	this.flags.queries = append(this.flags.queries, this.query)
+	this.flags.values = append(this.flags.values, str)
+	this.flags.sources = append(this.flags.sources, "flag:-"+this.query)
+	return nil
+}
+
+func (this *keyFlag) String() string {
+	if this == nil || this.sensitive {
+		return ""
+	}
+	return this.value
+}
+
+// set sets the value of the first key which matches the query and returns
+// the key, the key and missing sections on its path are created. Parts of the
+// query without a value create sections with an empty value.
+func (this *config) set(query string, val string) *config {
+	names, conds := this.parse(query)
+	node := this
+	for level, name := range names {
+		if level == len(names)-1 {
+			for _, child := range node.children {
+				if child.children == nil && child.matchName(name) {
+					child.value = val
+					return child
+				}
+			}
+			key := &config{name: name, value: val}
+			node.children = append(node.children, key)
+			return key
+		}
+		cond := conds[level]
+		if cond == "*" {
+			cond = ""
+		}
+		var next *config
+		for _, child := range node.children {
+			if child.children != nil && child.matchName(name) && (conds[level] == "*" || child.matchValue(cond)) {
+				next = child
+				break
+			}
+		}
+		if next == nil {
+			next = &config{name: name, value: cond, children: make([]*config, 0, 4)}
+			node.children = append(node.children, next)
+		}
+		node = next
+	}
+	return node
+}
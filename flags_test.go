@@ -0,0 +1,138 @@
+package config_test
+
+import "flag"
+import "github.com/twoleds-golang/config"
+import "io/ioutil"
+import "testing"
+
+func TestBindFlags(t *testing.T) {
+
+	var src = "# Enables debugging\n" +
+		"Debug false\n" +
+		"Name \"Test String\"\n" +
+		"Section One {\n" +
+		"    IntValue 1\n" +
+		"    FloatValue 1.5\n" +
+		"}\n"
+
+	ref, err := config.ParseFromString(src)
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	flags := config.BindFlags(fs, ref)
+
+	if f := fs.Lookup("Debug"); f == nil || f.Usage != "Enables debugging" || f.DefValue != "false" {
+		t.Error("Invalid flag 'Debug'")
+		t.Fail()
+	}
+
+	var args = []string{
+		"-Debug",
+		"-Section:One/IntValue", "5",
+		"-set", "Section:Two/IntValue=2",
+		"--set=Name=a=b",
+		"rest",
+	}
+
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		t.Errorf("Cannot parse flags: %v", err)
+		t.FailNow()
+	}
+
+	cfg := config.Merge(ref, flags.Config())
+
+	var tests = map[string]string{
+		"Debug":                  "true",
+		"Name":                   "a=b",
+		"Section:One/IntValue":   "5",
+		"Section:One/FloatValue": "1.5",
+		"Section:Two/IntValue":   "2",
+	}
+
+	for query, expected := range tests {
+		if val, ok := cfg.String(query); !ok || val != expected {
+			t.Errorf("Invalid value for query '%s': %q", query, val)
+			t.Fail()
+		}
+	}
+
+	if err := fs.Parse([]string{"-Section:One/FloatValue", "abc"}); err == nil {
+		t.Error("Expected error for invalid float value")
+		t.Fail()
+	}
+
+}
+
+func TestFromArgs(t *testing.T) {
+
+	cfg, err := config.FromArgs([]string{"-v", "-set", "Section:Two/IntValue=5", "--set=Name=x", "--", "-set", "A=b"})
+	if err != nil {
+		t.Errorf("Cannot parse arguments: %s", err.Error())
+		t.FailNow()
+	}
+
+	if val, _ := cfg.String("Section:Two/IntValue"); val != "5" {
+		t.Errorf("Invalid value for query 'Section:Two/IntValue': %q", val)
+		t.Fail()
+	}
+
+	if name, _ := cfg.Query("Name"); config.PositionOf(name).Source != "flag:-set" {
+		t.Errorf("Invalid position for query 'Name': %v", config.PositionOf(name))
+		t.Fail()
+	}
+
+	if len(config.Children(cfg)) != 2 {
+		t.Error("Arguments after '--' must be ignored")
+		t.Fail()
+	}
+
+	if _, err := config.FromArgs([]string{"-set", "Name"}); err == nil {
+		t.Error("Expected error for override without value")
+		t.Fail()
+	}
+
+}
+
+func TestBindTypedFlags(t *testing.T) {
+
+	ref, _ := config.ParseFromString("Server Main {\n    Port 8080\n    Timeout 1s\n}\nServer Backup {\n    Port 8081\n}\n")
+	types := map[string]string{"Server/Port": "string", "Server/Timeout": "duration"}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	flags := config.BindTypedFlags(fs, ref, types)
+
+	if err := fs.Parse([]string{"-Server:Main/Port", "http", "-set", "Server/Timeout=5s", "-set", "Server:Backup/Port=9090"}); err != nil {
+		t.Errorf("Cannot parse flags: %v", err)
+		t.FailNow()
+	}
+
+	if err := fs.Parse([]string{"-Server:Main/Timeout", "5"}); err == nil {
+		t.Error("Expected error for invalid duration value")
+		t.Fail()
+	}
+
+	var tests = map[string]string{
+		"Server:Main/Port":    "http",
+		"Server:Main/Timeout": "5s",
+		"Server:Backup/Port":  "9090",
+	}
+
+	for name, cfg := range map[string]config.Config{"Config": config.Merge(ref, flags.Config()), "Apply": flags.Apply(ref)} {
+		for query, expected := range tests {
+			if val, ok := cfg.String(query); !ok || val != expected {
+				t.Errorf("Invalid value of %s for query '%s': %q", name, query, val)
+				t.Fail()
+			}
+		}
+		if sections := cfg.QueryAll("Server"); len(sections) != 2 {
+			t.Errorf("Invalid number of sections of %s: %d", name, len(sections))
+			t.Fail()
+		}
+	}
+
+}
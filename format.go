@@ -0,0 +1,114 @@
+package config
+
+import "bytes"
+
+// Format returns the canonical formatting of the configuration data in src.
+// Nested sections are indented with four spaces, values are quoted only when
+// necessary, values of consecutive keys are aligned and runs of blank lines
+// are collapsed. Comments are preserved.
+func Format(src []byte) ([]byte, error) {
+	return FormatIndent(src, "    ")
+}
+
+// FormatIndent is like Format but indents nested sections with the specified
+// string, e.g. with a tab.
+func FormatIndent(src []byte, indent string) ([]byte, error) {
+	p := newBytesParser(src)
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	w := newWriter(buf, indent)
+	w.wChildren(p.config().(*config))
+	w.Flush()
+	return buf.Bytes(), nil
+}
+
+// wChildren writes all children of the specified node together with their
+// comments in the canonical format.
+func (this *writer) wChildren(cfg *config) *writer {
+	started := false
+	width := 0
+	for i, child := range cfg.children {
+		if this.wDecoration(child.lead, started, true) || i == 0 || cfg.children[i-1].children != nil || child.children != nil {
+			width = this.alignment(cfg.children[i:])
+		}
+		if child.children != nil {
+			this.wIndent().wNameValue(child.name, child.value)
+			if child.extends != "" {
+				this.wSpace().wText(extendsKeyword).wSpace().wQuery(child.extends)
+			}
+			this.wSpace().wSectionStart()
+			this.wInlineComment(child.comment).wLine()
+			this.wLevelUp().wChildren(child).wLevelDown()
+			this.wIndent().wSectionEnd().wLine()
+		} else {
+			this.wIndent().wName(child.name)
+			if child.stored() != "" {
+				this.wPad(width - len(child.name) + 1).wValue(child.stored())
+			}
+			this.wInlineComment(child.comment).wLine()
+		}
+		started = true
+	}
+	this.wDecoration(cfg.trailer, started, false)
+	return this
+}
+
+// wDecoration writes comment lines and collapsed blank lines. Blank lines are
+// dropped at the beginning of a section and, unless followed by a node, also
+// at its end. It reports whether a blank line has been written.
+func (this *writer) wDecoration(lines []string, started bool, node bool) bool {
+	blank := false
+	separated := false
+	for _, line := range lines {
+		if line == "" {
+			blank = started
+			continue
+		}
+		if blank {
+			this.wLine()
+			blank = false
+			separated = true
+		}
+		this.wIndent().wText(line).wLine()
+		started = true
+	}
+	if blank && node {
+		this.wLine()
+		separated = true
+	}
+	return separated
+}
+
+func (this *writer) wInlineComment(comment string) *writer {
+	if comment != "" {
+		this.wSpace().wText(comment)
+	}
+	return this
+}
+
+// alignment returns the length of the longest key name in the run of keys
+// at the beginning of the specified nodes. A run ends with a section or with
+// a blank line.
+func (this *writer) alignment(nodes []*config) int {
+	width := 0
+	for i, node := range nodes {
+		if node.children != nil {
+			break
+		}
+		if i > 0 {
+			blank := false
+			for _, line := range node.lead {
+				blank = blank || line == ""
+			}
+			if blank {
+				break
+			}
+		}
+		if node.stored() != "" && len(node.name) > width {
+			width = len(node.name)
+		}
+	}
+	return width
+}
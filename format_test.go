@@ -0,0 +1,60 @@
+package config_test
+
+import "github.com/twoleds-golang/config"
+import "testing"
+
+func TestFormat(t *testing.T) {
+
+	var src = "\n" +
+		"# Flat values\n" +
+		"BoolValue   true\n" +
+		"StringValue \"Test\"   # inline\n" +
+		"\n\n\n" +
+		"Section One {\n" +
+		"\tIntValue 1\n" +
+		"  FloatValue \"3.14\"\n" +
+		"  StringValue \"Test \\\"String\\\"\"\n" +
+		"\n" +
+		"  # trailing\n" +
+		"\n" +
+		"}\n"
+
+	var expected = "# Flat values\n" +
+		"BoolValue   true\n" +
+		"StringValue Test # inline\n" +
+		"\n" +
+		"Section One {\n" +
+		"    IntValue    1\n" +
+		"    FloatValue  3.14\n" +
+		"    StringValue \"Test \\\"String\\\"\"\n" +
+		"\n" +
+		"    # trailing\n" +
+		"}\n"
+
+	out, err := config.Format([]byte(src))
+	if err != nil {
+		t.Errorf("Cannot format config: %s", err.Error())
+		t.FailNow()
+	}
+
+	if string(out) != expected {
+		t.Errorf("Invalid formatted config:\n%s", out)
+		t.Fail()
+	}
+
+	if again, err := config.Format(out); err != nil || string(again) != string(out) {
+		t.Error("Formatting is not idempotent")
+		t.Fail()
+	}
+
+	if out, err := config.FormatIndent([]byte("Section {\nValue 1\n}\n"), "\t"); err != nil || string(out) != "Section {\n\tValue 1\n}\n" {
+		t.Error("Invalid indentation with tabs")
+		t.Fail()
+	}
+
+	if _, err := config.Format([]byte("Section {\n")); err == nil {
+		t.Error("Expected error for unterminated section")
+		t.Fail()
+	}
+
+}
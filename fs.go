@@ -0,0 +1,161 @@
+package config
+
+import "errors"
+import "fmt"
+import "io/fs"
+import "os"
+import "path"
+import "path/filepath"
+import "strings"
+
+// IncludeDirective is the name of keys which include other files, e.g.
+// `@include "conf.d/*.conf"`. The nodes of included files replace the
+// directive, so includes work inside sections too.
+const IncludeDirective = "@include"
+
+// ParseFromFS parses and returns a hierarchical configuration data from the
+// specified file of the file system, e.g. embed.FS or os.DirFS. Include
+// directives are resolved against the same file system: paths are relative
+// to the directory of the including file (or to the root of the file system
+// if they start with '/') and may contain glob patterns, matching files are
+// included in order of their names. A pattern without matches includes
+// nothing, a missing file without a pattern is an error, and so are cycles.
+//
+// Positions of nodes refer to the files they were parsed from, nodes of
+// included files have the name of the parsed file as their layer. The other
+// Parse functions keep include directives as keys named IncludeDirective, so
+// formatting and editing keep them too.
+//
+// Keys and sections whose names start with '@' are directives, names other
+// than IncludeDirective, UseDirective, ProfileDirective and IfDirective are
+// errors.
+func ParseFromFS(fsys fs.FS, name string) (cfg Config, err error) {
+	i := &includer{fsys: fsys}
+	return i.root(name)
+}
+
+type includer struct {
+	fsys  fs.FS
+	stack []string
+	// files are names of parsed files and of directories searched for
+	// patterns, e.g. for watching them.
+	files []string
+}
+
+// root parses the file with includes and sets sources of its nodes.
+func (this *includer) root(name string) (*config, error) {
+	root, err := this.parse(path.Clean(name))
+	if err != nil {
+		return nil, err
+	}
+	root.setSource(path.Clean(name), path.Clean(name))
+	return root, nil
+}
+
+func (this *includer) parse(name string) (*config, error) {
+	for index, parent := range this.stack {
+		if parent == name {
+			cycle := append(append([]string{}, this.stack[index:]...), name)
+			return nil, errors.New(fmt.Sprintf("Include cycle: %s", strings.Join(cycle, " -> ")))
+		}
+	}
+	this.files = append(this.files, name)
+	data, err := fs.ReadFile(this.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := withSource(name)(ParseFromBytes(data))
+	if err != nil {
+		return nil, err
+	}
+	this.stack = append(this.stack, name)
+	defer func() { this.stack = this.stack[:len(this.stack)-1] }()
+	root := cfg.(*config)
+	if err := this.resolve(root, path.Dir(name)); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// resolve replaces include directives in the section and its subsections by
+// nodes of included files.
+func (this *includer) resolve(section *config, dir string) error {
+	children := make([]*config, 0, len(section.children))
+	for _, child := range section.children {
+		if child.children != nil {
+			if err := this.resolve(child, dir); err != nil {
+				return err
+			}
+		}
+		if err := checkDirective(child); err != nil {
+			return err
+		}
+		if child.children != nil || child.name != IncludeDirective {
+			children = append(children, child)
+			continue
+		}
+		pattern := path.Join(dir, child.value)
+		if strings.HasPrefix(child.value, "/") {
+			pattern = path.Clean(strings.TrimLeft(child.value, "/"))
+		}
+		names := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			this.files = append(this.files, path.Dir(pattern))
+			var err error
+			if names, err = fs.Glob(this.fsys, pattern); err != nil {
+				return err
+			}
+		}
+		for _, name := range names {
+			included, err := this.parse(name)
+			if err != nil {
+				return err
+			}
+			children = append(children, included.children...)
+		}
+	}
+	section.children = children
+	return nil
+}
+
+// checkDirective returns an error if the name of the node starts with '@' and
+// it is not a known directive.
+func checkDirective(node *config) error {
+	if !strings.HasPrefix(node.name, "@") {
+		return nil
+	}
+	if node.children == nil && (node.name == IncludeDirective || node.name == UseDirective) {
+		return nil
+	}
+	if node.children != nil && (node.name == ProfileDirective || node.name == IfDirective) {
+		return nil
+	}
+	return errors.New(node.locate(fmt.Sprintf("Unknown directive '%s'", node.name)))
+}
+
+// dirFS is a file system of the directory like os.DirFS, but names may start
+// with "..", so files can include files of sibling directories.
+type dirFS string
+
+func (this dirFS) Open(name string) (fs.File, error) {
+	return os.Open(this.join(name))
+}
+
+func (this dirFS) Glob(pattern string) ([]string, error) {
+	matches, err := filepath.Glob(this.join(pattern))
+	if err != nil {
+		return nil, err
+	}
+	for key, match := range matches {
+		rel, err := filepath.Rel(string(this), match)
+		if err != nil {
+			return nil, err
+		}
+		matches[key] = filepath.ToSlash(rel)
+	}
+	return matches, nil
+}
+
+func (this dirFS) join(name string) string {
+	return filepath.Join(string(this), filepath.FromSlash(name))
+}
@@ -0,0 +1,90 @@
+package config_test
+
+import "github.com/twoleds-golang/config"
+import "testing"
+import "testing/fstest"
+
+func TestParseFromFS(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"app.conf": &fstest.MapFile{Data: []byte("Name app\n" +
+			"@include \"conf.d/*.conf\"\n" +
+			"Section One {\n" +
+			"    @include section.conf\n" +
+			"}\n")},
+		"conf.d/10-a.conf": &fstest.MapFile{Data: []byte("Host a\n")},
+		"conf.d/20-b.conf": &fstest.MapFile{Data: []byte("Host b\n@include \"/shared/port.conf\"\n")},
+		"section.conf":     &fstest.MapFile{Data: []byte("IntValue 1\n")},
+		"shared/port.conf": &fstest.MapFile{Data: []byte("Port 8080\n")},
+		"cycle/a.conf":     &fstest.MapFile{Data: []byte("@include b.conf\n")},
+		"cycle/b.conf":     &fstest.MapFile{Data: []byte("@include a.conf\n")},
+		"missing.conf":     &fstest.MapFile{Data: []byte("@include none.conf\n")},
+		"invalid.conf":     &fstest.MapFile{Data: []byte("@include bad.conf\n")},
+		"bad.conf":         &fstest.MapFile{Data: []byte("Name {\n")},
+		"unknown.conf":     &fstest.MapFile{Data: []byte("Section {\n    @inlcude section.conf\n}\n")},
+	}
+
+	cfg, err := config.ParseFromFS(fsys, "app.conf")
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	var tests = map[string]string{
+		"Name":                 "app",
+		"Host":                 "a",
+		"Port":                 "8080",
+		"Section:One/IntValue": "1",
+	}
+
+	for query, expected := range tests {
+		if val, ok := cfg.String(query); !ok || val != expected {
+			t.Errorf("Invalid value for query '%s': %q", query, val)
+			t.Fail()
+		}
+	}
+
+	if hosts := cfg.QueryAll("Host"); len(hosts) != 2 || config.Children(cfg)[2].Value() != "b" {
+		t.Error("Invalid order of included nodes")
+		t.Fail()
+	}
+
+	var positions = map[string]string{
+		"Name":                 "app.conf:1:1",
+		"Port":                 "shared/port.conf:1:1 (app.conf)",
+		"Section:One/IntValue": "section.conf:1:1 (app.conf)",
+	}
+
+	for query, expected := range positions {
+		if node, ok := cfg.Query(query); !ok || config.PositionOf(node).String() != expected {
+			t.Errorf("Invalid position for query '%s': %v", query, config.PositionOf(node))
+			t.Fail()
+		}
+	}
+
+	if _, ok := cfg.Query(config.IncludeDirective); ok {
+		t.Error("Include directive was not resolved")
+		t.Fail()
+	}
+
+	var errors = map[string]string{
+		"cycle/a.conf": "Include cycle: cycle/a.conf -> cycle/b.conf -> cycle/a.conf",
+		"invalid.conf": "bad.conf: Unexpected end of file on line 2 at column 1",
+		"missing.conf": "open none.conf: file does not exist",
+		"unknown.conf": "unknown.conf:2:5: Unknown directive '@inlcude'",
+	}
+
+	for name, expected := range errors {
+		if _, err := config.ParseFromFS(fsys, name); err == nil || err.Error() != expected {
+			t.Errorf("Invalid error for '%s': %v", name, err)
+			t.Fail()
+		}
+	}
+
+	raw, _ := config.ParseFromString("@include \"conf.d/*.conf\"\n")
+	if val, _ := raw.String(config.IncludeDirective); val != "conf.d/*.conf" {
+		t.Error("Include directive is not kept by ParseFromString")
+		t.Fail()
+	}
+
+}
@@ -0,0 +1,148 @@
+package config_test
+
+import "bytes"
+import "github.com/twoleds-golang/config"
+import "testing"
+
+// fuzzLimits keep inputs of fuzz targets small.
+var fuzzLimits = config.Limits{MaxDepth: 64, MaxKeyLength: 256, MaxValueLength: 4096, MaxNodes: 4096}
+
+// fuzzSeeds returns sources of other tests as the seed corpus.
+func fuzzSeeds() []string {
+	buf := new(bytes.Buffer)
+	w := config.NewWriter(buf)
+	writeTestConfig(w)
+	w.Flush()
+	return []string{
+		parserSrc,
+		buf.String(),
+		string(benchSmall),
+		extendsSrc,
+		profileSrc,
+		string(benchNested(8)),
+		"Name {\n",
+		"}\n",
+		"Name \"value\n",
+		"Name value value\n",
+		"Name extends \"A:B\" {\n}\n",
+		"# comment\n\nKey \"a\\\"b\" # inline\n",
+	}
+}
+
+func FuzzParseFromBytes(f *testing.F) {
+
+	for _, seed := range fuzzSeeds() {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		cfg, err := config.ParseFromBytes(data)
+		if _, ok := err.(*config.ParseError); err != nil && !ok {
+			t.Errorf("Invalid error: %v", err)
+		}
+
+		// Data within the small limits are parsed the same with DefaultLimits.
+		limited, lerr := fuzzLimits.ParseBytes(data)
+		if lerr != nil {
+			if _, ok := lerr.(*config.ParseError); !ok {
+				t.Errorf("Invalid error: %v", lerr)
+			}
+			return
+		}
+		if err != nil || config.Dump(cfg) != config.Dump(limited) {
+			t.Errorf("DefaultLimits differ from smaller limits: %v", err)
+			t.FailNow()
+		}
+
+		out, err := config.Format(data)
+		if err != nil {
+			t.Errorf("Cannot format parsed data: %s", err.Error())
+			t.FailNow()
+		}
+		if again, err := config.Format(out); err != nil || string(again) != string(out) {
+			t.Errorf("Format is not stable: %v\n%s\n%s", err, out, again)
+		}
+		if formatted, err := config.ParseFromBytes(out); err != nil || config.Dump(formatted) != config.Dump(cfg) {
+			t.Errorf("Formatted data differ: %v", err)
+		}
+
+		d := fuzzLimits.NewDecoder(bytes.NewReader(data))
+		for skip := false; ; skip = !skip {
+			tok, err := d.Next()
+			if err != nil {
+				break
+			}
+			if _, ok := tok.(config.SectionStart); ok && skip {
+				if err := d.Skip(); err != nil {
+					t.Errorf("Cannot skip section: %s", err.Error())
+					break
+				}
+			}
+		}
+	})
+
+}
+
+func FuzzQuery(f *testing.F) {
+
+	for _, seed := range []string{"Name", "Server:Main/Port", "Server:*/Host", "Server/", ":", "/", "a:b:c//d"} {
+		f.Add(seed)
+	}
+
+	cfg, err := config.ParseFromBytes(benchSmall)
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, query string) {
+		found, ok := cfg.Query(query)
+		all := cfg.QueryAll(query)
+		if ok != (len(all) > 0) || (ok && found != all[0]) {
+			t.Errorf("Query and QueryAll differ for %q", query)
+		}
+		if val, ok := cfg.String(query); ok && val != found.Value() {
+			t.Errorf("Invalid value for %q", query)
+		}
+	})
+
+}
+
+func FuzzWriter(f *testing.F) {
+
+	f.Add("Name", "value")
+	f.Add("Section", "")
+	f.Add("@include", "conf.d/*.conf")
+	f.Add("Key", "a \"quoted\" \\ value\n")
+	f.Add("extends", "extends")
+	f.Add("Key", "\xff\xfe")
+
+	f.Fuzz(func(t *testing.T, name string, value string) {
+		if !config.IsValidName(name) || len(name) > fuzzLimits.MaxKeyLength || len(value) > fuzzLimits.MaxValueLength {
+			t.Skip()
+		}
+
+		buf := new(bytes.Buffer)
+		w := config.NewWriter(buf)
+		w.String(name, value).Section(name, value).String("Key", value).CloseSection()
+		w.Flush()
+
+		cfg, err := fuzzLimits.ParseBytes(buf.Bytes())
+		if err != nil {
+			t.Errorf("Cannot parse written data: %s\n%s", err.Error(), buf.String())
+			t.FailNow()
+		}
+		children := config.Children(cfg)
+		if len(children) != 2 || children[0].Name() != name || children[0].Value() != value || config.IsSection(children[0]) {
+			t.Errorf("Invalid key:\n%s", buf.String())
+			t.FailNow()
+		}
+		if !config.IsSection(children[1]) || children[1].Name() != name || children[1].Value() != value {
+			t.Errorf("Invalid section:\n%s", buf.String())
+			t.FailNow()
+		}
+		if key, ok := children[1].String("Key"); !ok || key != value {
+			t.Errorf("Invalid key of section:\n%s", buf.String())
+		}
+	})
+
+}
@@ -0,0 +1,238 @@
+// Package httpkv provides a configuration source backed by an HTTP key/value
+// service with a Consul-style API.
+//
+// The source reads all keys under a prefix with
+//
+//	GET <url>/v1/kv/<prefix>?recurse=true
+//
+// which returns a JSON array of objects with the fields Key and Value (the
+// value encoded in base64), the header X-Consul-Index with the modification
+// index of the data and optionally an ETag. Responses are cached: the ETag is
+// sent back in If-None-Match and 304 Not Modified reuses the cached data. A
+// missing prefix (404 Not Found) means empty configuration data.
+//
+// Changes are watched by blocking queries: the parameters index (the last
+// modification index) and wait make the service hold the request until the
+// index changes or the wait time elapses. Watch fetches the index first if
+// it is not known from the last Load and fails if the service does not send
+// the header X-Consul-Index.
+//
+// Keys are converted to queries by removing the prefix, e.g. with the prefix
+// "app" the key "app/Section:Two/IntValue" becomes the key IntValue in the
+// section "Section Two" and "app/Server/Port" the key Port in the section
+// Server. Keys ending with '/' are folders and are ignored.
+package httpkv
+
+import "context"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "io"
+import "net/http"
+import "net/url"
+import "sort"
+import "strconv"
+import "strings"
+import "sync"
+import "time"
+
+// Options controls the source, nil options mean default options.
+type Options struct {
+	// Client is the HTTP client, http.DefaultClient by default.
+	Client *http.Client
+	// Wait is the maximal time of a blocking query, 5 minutes by default.
+	Wait time.Duration
+}
+
+// Pair is a key with its value as returned by the service.
+type Pair struct {
+	Key   string
+	Value []byte
+}
+
+type source struct {
+	url    string
+	prefix string
+	client *http.Client
+	wait   time.Duration
+	mutex  sync.Mutex
+	etag   string
+	index  uint64
+	cached config.Config
+}
+
+var _ config.Source = new(source)
+var _ config.Watcher = new(source)
+
+// NewSource returns a source of keys under the prefix from the service at the
+// specified URL. The source implements config.Watcher with blocking queries.
+func NewSource(url string, prefix string, opts *Options) config.Source {
+	s := new(source)
+	s.url = strings.TrimRight(url, "/")
+	s.prefix = strings.Trim(prefix, "/")
+	s.client = http.DefaultClient
+	s.wait = 5 * time.Minute
+	if opts != nil && opts.Client != nil {
+		s.client = opts.Client
+	}
+	if opts != nil && opts.Wait > 0 {
+		s.wait = opts.Wait
+	}
+	return s
+}
+
+func (this *source) Name() string {
+	return this.url + "/v1/kv/" + this.prefix
+}
+
+func (this *source) Load(ctx context.Context) (config.Config, error) {
+	return this.fetch(ctx, 0)
+}
+
+func (this *source) Watch(ctx context.Context) error {
+	index := this.lastIndex()
+	if index == 0 {
+		// A blocking query without an index returns at once, so the index is
+		// fetched first and the first response is not reported as a change.
+		if _, err := this.fetch(ctx, 0); err != nil {
+			return err
+		}
+		index = this.lastIndex()
+	}
+	for index != 0 {
+		if _, err := this.fetch(ctx, index); err != nil {
+			return err
+		}
+		if next := this.lastIndex(); next != index {
+			if next != 0 {
+				return nil
+			}
+			index = next
+		}
+	}
+	return errors.New("Missing header X-Consul-Index, changes cannot be watched")
+}
+
+// lastIndex returns the modification index of the last response, zero if it
+// is unknown.
+func (this *source) lastIndex() uint64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.index
+}
+
+// fetch reads the keys, a blocking query is used if the index is not zero.
+func (this *source) fetch(ctx context.Context, index uint64) (config.Config, error) {
+	query := url.Values{"recurse": {"true"}}
+	if index > 0 {
+		query.Set("index", strconv.FormatUint(index, 10))
+		query.Set("wait", this.wait.String())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, this.Name()+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	this.mutex.Lock()
+	if this.etag != "" && this.cached != nil {
+		req.Header.Set("If-None-Match", this.etag)
+	}
+	cached := this.cached
+	this.mutex.Unlock()
+	res, err := this.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	defer res.Body.Close()
+	next, err := strconv.ParseUint(res.Header.Get("X-Consul-Index"), 10, 64)
+	if err != nil {
+		next = 0
+	}
+	this.mutex.Lock()
+	this.index = next
+	this.mutex.Unlock()
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		return cached, nil
+	case http.StatusNotFound:
+		cfg := config.NewBuilder().Config()
+		this.store("", cfg)
+		return cfg, nil
+	case http.StatusOK:
+	default:
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		return nil, errors.New(fmt.Sprintf("Unexpected status %s: %s", res.Status, strings.TrimSpace(string(body))))
+	}
+	pairs := make([]Pair, 0, 16)
+	if err := json.NewDecoder(res.Body).Decode(&pairs); err != nil {
+		return nil, err
+	}
+	cfg, err := Convert(this.prefix, pairs)
+	if err != nil {
+		return nil, err
+	}
+	this.store(res.Header.Get("ETag"), cfg)
+	return cfg, nil
+}
+
+func (this *source) store(etag string, cfg config.Config) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.etag = etag
+	this.cached = cfg
+}
+
+// Convert returns configuration data created from keys under the prefix,
+// other keys and folders are ignored. Keys are sorted, so keys of a section
+// are grouped together. Positions of keys have the source "kv:" followed by
+// the key.
+func Convert(prefix string, pairs []Pair) (config.Config, error) {
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix = prefix + "/"
+	}
+	sorted := make([]Pair, 0, len(pairs))
+	for _, pair := range pairs {
+		if strings.HasPrefix(pair.Key, prefix) && len(pair.Key) > len(prefix) && !strings.HasSuffix(pair.Key, "/") {
+			sorted = append(sorted, Pair{Key: pair.Key[len(prefix):], Value: pair.Value})
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+	b := config.NewLayoutBuilder()
+	open := make([]string, 0, 8)
+	for _, pair := range sorted {
+		parts := strings.Split(pair.Key, "/")
+		sections := parts[:len(parts)-1]
+		common := 0
+		for common < len(open) && common < len(sections) && open[common] == sections[common] {
+			common++
+		}
+		for len(open) > common {
+			b.CloseSection()
+			open = open[:len(open)-1]
+		}
+		for _, part := range sections[common:] {
+			name, val := part, ""
+			if index := strings.IndexByte(part, ':'); index >= 0 {
+				name, val = part[:index], part[index+1:]
+			}
+			if name == "" {
+				return nil, errors.New(fmt.Sprintf("Invalid key '%s%s'", prefix, pair.Key))
+			}
+			b.Section(name, val)
+			open = append(open, part)
+		}
+		if parts[len(parts)-1] == "" {
+			return nil, errors.New(fmt.Sprintf("Invalid key '%s%s'", prefix, pair.Key))
+		}
+		b.String(parts[len(parts)-1], string(pair.Value))
+		b.At(config.Position{Source: "kv:" + prefix + pair.Key})
+	}
+	for range open {
+		b.CloseSection()
+	}
+	return b.Config(), nil
+}
@@ -0,0 +1,108 @@
+package httpkv_test
+
+import "context"
+import "github.com/twoleds-golang/config"
+import "github.com/twoleds-golang/config/httpkv"
+import "github.com/twoleds-golang/config/httpkv/httpkvtest"
+import "net/http"
+import "net/http/httptest"
+import "strings"
+import "testing"
+import "time"
+
+func TestSource(t *testing.T) {
+
+	server := httpkvtest.NewServer()
+	defer server.Close()
+
+	server.Set("app/Name", "Test String")
+	server.Set("app/Section:Two/IntValue", "2")
+	server.Set("app/Section:One/IntValue", "1")
+	server.Set("app/Server/Limits/Max", "10")
+	server.Set("app/Server/Port", "8080")
+	server.Set("other/Name", "Other")
+
+	source := httpkv.NewSource(server.URL, "app", &httpkv.Options{Wait: 5 * time.Second})
+	ctx := context.Background()
+
+	cfg, err := source.Load(ctx)
+	if err != nil {
+		t.Errorf("Cannot load config: %s", err.Error())
+		t.FailNow()
+	}
+
+	var tests = map[string]string{
+		"Name":                 "Test String",
+		"Section:One/IntValue": "1",
+		"Section:Two/IntValue": "2",
+		"Server/Limits/Max":    "10",
+		"Server/Port":          "8080",
+	}
+
+	for query, expected := range tests {
+		if val, ok := cfg.String(query); !ok || val != expected {
+			t.Errorf("Invalid value for query '%s': %q", query, val)
+			t.Fail()
+		}
+	}
+
+	if len(cfg.QueryAll("Server")) != 1 || len(config.Children(cfg)) != 4 {
+		t.Error("Invalid structure of converted keys")
+		t.Fail()
+	}
+
+	if _, err := source.Load(ctx); err != nil {
+		t.Errorf("Cannot load config: %s", err.Error())
+		t.Fail()
+	}
+	if _, notModified := server.Counts(); notModified != 1 {
+		t.Error("Expected cached response")
+		t.Fail()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- source.(config.Watcher).Watch(ctx)
+	}()
+	server.Set("app/Server/Port", "9090")
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Cannot watch source: %s", err.Error())
+			t.FailNow()
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Change was not reported")
+		t.FailNow()
+	}
+
+	if cfg, _ := source.Load(ctx); cfg.IntOrDefault("Server/Port", 0) != 9090 {
+		t.Error("Invalid value after change")
+		t.Fail()
+	}
+
+	// Watch without Load fetches the index first and reports only changes.
+	short, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	if err := httpkv.NewSource(server.URL, "app", &httpkv.Options{Wait: time.Second}).(config.Watcher).Watch(short); err != context.DeadlineExceeded {
+		t.Errorf("Expected no change, got %v", err)
+		t.Fail()
+	}
+
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	}))
+	defer plain.Close()
+	if err := httpkv.NewSource(plain.URL, "app", nil).(config.Watcher).Watch(ctx); err == nil || !strings.Contains(err.Error(), "X-Consul-Index") {
+		t.Errorf("Expected error for missing index, got %v", err)
+		t.Fail()
+	}
+
+	empty, err := httpkv.NewSource(server.URL, "missing", nil).Load(ctx)
+	if err != nil || len(config.Children(empty)) != 0 {
+		t.Error("Expected empty config for missing prefix")
+		t.Fail()
+	}
+
+}
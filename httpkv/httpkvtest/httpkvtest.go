@@ -0,0 +1,142 @@
+// Package httpkvtest provides an in-memory key/value service for tests of
+// the httpkv source.
+package httpkvtest
+
+import "encoding/json"
+import "fmt"
+import "hash/fnv"
+import "net/http"
+import "net/http/httptest"
+import "sort"
+import "strconv"
+import "strings"
+import "sync"
+import "time"
+
+// Server is a key/value service which implements the API used by the httpkv
+// source: recursive reads of prefixes, ETags and blocking queries.
+type Server struct {
+	*httptest.Server
+	mutex   sync.Mutex
+	values  map[string][]byte
+	index   uint64
+	changed chan struct{}
+	// requests counts handled requests, notModified requests answered with
+	// 304 Not Modified.
+	requests    int
+	notModified int
+}
+
+// NewServer starts and returns a new server, it has to be closed by Close.
+func NewServer() *Server {
+	s := new(Server)
+	s.values = make(map[string][]byte)
+	s.index = 1
+	s.changed = make(chan struct{})
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Set sets the value of the key and wakes up blocking queries.
+func (this *Server) Set(key string, value string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	this.values[key] = []byte(value)
+	this.notify()
+}
+
+// Counts returns the number of handled requests and the number of requests
+// answered with 304 Not Modified.
+func (this *Server) Counts() (requests int, notModified int) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.requests, this.notModified
+}
+
+// Delete deletes the key and wakes up blocking queries.
+func (this *Server) Delete(key string) {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	delete(this.values, key)
+	this.notify()
+}
+
+// notify increments the index and wakes up blocking queries, the caller
+// holds the mutex.
+func (this *Server) notify() {
+	this.index++
+	close(this.changed)
+	this.changed = make(chan struct{})
+}
+
+type pair struct {
+	Key   string
+	Value []byte
+}
+
+func (this *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet || !strings.HasPrefix(r.URL.Path, "/v1/kv/") {
+		http.NotFound(w, r)
+		return
+	}
+	prefix := strings.TrimPrefix(r.URL.Path, "/v1/kv/")
+	if index, err := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64); err == nil {
+		wait, err := time.ParseDuration(r.URL.Query().Get("wait"))
+		if err != nil {
+			wait = time.Minute
+		}
+		this.block(r, index, wait)
+	}
+	this.mutex.Lock()
+	this.requests++
+	pairs := make([]pair, 0, len(this.values))
+	for key, value := range this.values {
+		if strings.HasPrefix(key, prefix) {
+			pairs = append(pairs, pair{Key: key, Value: value})
+		}
+	}
+	index := this.index
+	this.mutex.Unlock()
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i].Key < pairs[j].Key })
+	data, _ := json.Marshal(pairs)
+	h := fnv.New64a()
+	h.Write(data)
+	etag := fmt.Sprintf("\"%x\"", h.Sum64())
+	w.Header().Set("X-Consul-Index", strconv.FormatUint(index, 10))
+	w.Header().Set("ETag", etag)
+	if len(pairs) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Header.Get("If-None-Match") == etag {
+		this.mutex.Lock()
+		this.notModified++
+		this.mutex.Unlock()
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// block waits until the index of the server differs from the index, the wait
+// time elapses or the request is canceled.
+func (this *Server) block(r *http.Request, index uint64, wait time.Duration) {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		this.mutex.Lock()
+		current, changed := this.index, this.changed
+		this.mutex.Unlock()
+		if current != index {
+			return
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
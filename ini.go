@@ -0,0 +1,106 @@
+package config
+
+import "bufio"
+import "fmt"
+import "io"
+import "strings"
+
+// ParseINI parses configuration data from an INI file. Keys are separated
+// from values by '=' or ':', a key without separator has an empty value.
+// Section headers are mapped to sections: "[Name]" starts the section Name
+// and "[Name Value]" or "[Name "Value"]" starts the section Name with the
+// value Value. Keys in front of the first header belong to the root. Lines
+// starting with ';' or '#' are comments, they are kept as comments of the
+// following node. Values may be enclosed in double quotes, otherwise a ';'
+// or '#' preceded by a space starts an inline comment. Names of sections and
+// keys must be valid names, see IsValidName.
+func ParseINI(r io.Reader) (Config, error) {
+	b := newBuilder()
+	reader := bufio.NewReader(r)
+	open := false
+	for num := 1; ; num++ {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" && err == io.EOF {
+			break
+		}
+		text := strings.TrimSpace(line)
+		switch {
+		case text == "":
+			b.Line()
+		case text[0] == ';' || text[0] == '#':
+			b.Comment(strings.TrimPrefix(text[1:], " "))
+		case text[0] == '[':
+			end := strings.IndexByte(text, ']')
+			if end < 0 {
+				return nil, &ParseError{Line: num, Column: len(line), Msg: "Unterminated section header"}
+			}
+			if rest := strings.TrimSpace(text[end+1:]); rest != "" && rest[0] != ';' && rest[0] != '#' {
+				return nil, &ParseError{Line: num, Column: strings.Index(line, "]") + 2, Msg: "Wrong character"}
+			}
+			header := strings.TrimSpace(text[1:end])
+			name, val := header, ""
+			if index := strings.IndexAny(header, " \t"); index >= 0 {
+				name = header[:index]
+				val = iniValue(strings.TrimSpace(header[index:]))
+			}
+			if name == "" {
+				return nil, &ParseError{Line: num, Column: strings.Index(line, "[") + 1, Msg: "Missing section name"}
+			}
+			if !IsValidName(name) {
+				return nil, &ParseError{Line: num, Column: strings.Index(line, name) + 1, Msg: fmt.Sprintf("Invalid name '%s'", name)}
+			}
+			if open {
+				b.CloseSection()
+			}
+			b.Section(name, val)
+			b.At(Position{Line: num, Column: strings.IndexByte(line, '[') + 1})
+			open = true
+		default:
+			name, val := text, ""
+			if index := strings.IndexAny(text, "=:"); index >= 0 {
+				name = strings.TrimSpace(text[:index])
+				val = iniValue(strings.TrimSpace(text[index+1:]))
+			}
+			if name == "" {
+				return nil, &ParseError{Line: num, Column: strings.IndexAny(line, "=:") + 1, Msg: "Missing key name"}
+			}
+			if !IsValidName(name) {
+				return nil, &ParseError{Line: num, Column: strings.Index(line, name) + 1, Msg: fmt.Sprintf("Invalid name '%s'", name)}
+			}
+			b.String(name, val)
+			b.At(Position{Line: num, Column: len(line) - len(strings.TrimLeft(line, " \t")) + 1})
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if open {
+		b.CloseSection()
+	}
+	return b.Config(), nil
+}
+
+// iniValue returns the value without quotes or without an inline comment.
+func iniValue(text string) string {
+	if len(text) >= 2 && text[0] == '"' {
+		buf := make([]byte, 0, len(text))
+		for i := 1; i < len(text); i++ {
+			if text[i] == '"' {
+				return string(buf)
+			}
+			if text[i] == '\\' && i+1 < len(text) {
+				i++
+			}
+			buf = append(buf, text[i])
+		}
+	}
+	for i := 1; i < len(text); i++ {
+		if (text[i] == ';' || text[i] == '#') && (text[i-1] == ' ' || text[i-1] == '\t') {
+			return strings.TrimSpace(text[:i])
+		}
+	}
+	return text
+}
@@ -0,0 +1,76 @@
+package config_test
+
+import "github.com/twoleds-golang/config"
+import "strings"
+import "testing"
+
+func TestParseINI(t *testing.T) {
+
+	var src = "; Global settings\n" +
+		"Name = \"Test String\" ; quoted\n" +
+		"Enabled: true\n" +
+		"Verbose\n" +
+		"\n" +
+		"# First section\n" +
+		"[Section One]\n" +
+		"IntValue = 123 # inline\n" +
+		"Url = http://host/#fragment\n" +
+		"[Section \"Two\"]\n" +
+		"IntValue = 456\n"
+
+	cfg, err := config.ParseINI(strings.NewReader(src))
+	if err != nil {
+		t.Errorf("Cannot parse INI: %s", err.Error())
+		t.FailNow()
+	}
+
+	var tests = map[string]string{
+		"Name":                 "Test String",
+		"Enabled":              "true",
+		"Verbose":              "",
+		"Section:One/IntValue": "123",
+		"Section:One/Url":      "http://host/#fragment",
+		"Section:Two/IntValue": "456",
+	}
+
+	for query, expected := range tests {
+		if val, ok := cfg.String(query); !ok || val != expected {
+			t.Errorf("Invalid value for query '%s': %q", query, val)
+			t.Fail()
+		}
+	}
+
+	if name, _ := cfg.Query("Name"); len(config.Comments(name)) != 1 || config.Comments(name)[0] != "Global settings" {
+		t.Error("Invalid comments for query 'Name'")
+		t.Fail()
+	}
+
+	if section, _ := cfg.Query("Section:One"); len(config.Comments(section)) != 1 || config.Comments(section)[0] != "First section" {
+		t.Error("Invalid comments for query 'Section:One'")
+		t.Fail()
+	}
+
+	if key, _ := cfg.Query("Section:Two/IntValue"); config.PositionOf(key) != (config.Position{Line: 11, Column: 1}) {
+		t.Errorf("Invalid position for query 'Section:Two/IntValue': %v", config.PositionOf(key))
+		t.Fail()
+	}
+
+	if _, err := config.ParseINI(strings.NewReader("[Section\n")); err == nil {
+		t.Error("Expected error for unterminated section header")
+		t.Fail()
+	}
+
+	var names = map[string]string{
+		"[my-section foo]\n":    "Invalid name 'my-section' on line 1 at column 2",
+		"[A]\n  key.name = 1\n": "Invalid name 'key.name' on line 2 at column 3",
+		"my key = 1\n":          "Invalid name 'my key' on line 1 at column 1",
+	}
+
+	for src, msg := range names {
+		if _, err := config.ParseINI(strings.NewReader(src)); err == nil || err.Error() != msg {
+			t.Errorf("Expected error %q for %q, got %v", msg, src, err)
+			t.Fail()
+		}
+	}
+
+}
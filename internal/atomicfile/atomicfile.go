@@ -0,0 +1,37 @@
+// Package atomicfile replaces files so an interrupted write never leaves a
+// truncated file behind, it is shared by the commands of this repository.
+package atomicfile
+
+import "io/ioutil"
+import "os"
+import "path/filepath"
+
+// WriteFile replaces the file atomically by writing a temporary file in the
+// same directory and renaming it over the original, the permissions of the
+// original are kept.
+func WriteFile(file string, data []byte) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(f.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), file)
+}
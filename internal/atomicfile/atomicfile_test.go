@@ -0,0 +1,40 @@
+package atomicfile
+
+import "io/ioutil"
+import "os"
+import "path/filepath"
+import "testing"
+
+func TestWriteFile(t *testing.T) {
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.conf")
+	if err := ioutil.WriteFile(file, []byte("Port 80\n"), 0600); err != nil {
+		t.Errorf("Cannot write file: %s", err.Error())
+		t.FailNow()
+	}
+
+	if err := WriteFile(file, []byte("Port 8080\n")); err != nil {
+		t.Errorf("Cannot replace file: %s", err.Error())
+		t.FailNow()
+	}
+
+	if data, _ := ioutil.ReadFile(file); string(data) != "Port 8080\n" {
+		t.Errorf("Invalid content: %q", data)
+		t.Fail()
+	}
+	if info, _ := os.Stat(file); info.Mode().Perm() != 0600 {
+		t.Errorf("Invalid mode: %s", info.Mode())
+		t.Fail()
+	}
+	if files, _ := ioutil.ReadDir(dir); len(files) != 1 {
+		t.Error("Temporary file was not removed")
+		t.Fail()
+	}
+
+	if err := WriteFile(filepath.Join(dir, "missing.conf"), nil); err == nil {
+		t.Error("Expected error for missing file")
+		t.Fail()
+	}
+
+}
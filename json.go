@@ -0,0 +1,415 @@
+package config
+
+import "bytes"
+import "encoding/json"
+import "fmt"
+import "io"
+import "io/ioutil"
+import "sort"
+import "strings"
+
+// DefaultJSONValueKey is the name of the JSON field which holds values of
+// sections in JSON documents.
+const DefaultJSONValueKey = "@value"
+
+// DefaultJSONChildrenKey is the name of the JSON field which holds children
+// of sections in their order when grouping by names would reorder them.
+const DefaultJSONChildrenKey = "@children"
+
+// JSONMapping converts configuration data from and to JSON documents.
+//
+// Sections are mapped to objects and the value of a section is stored in the
+// field named by ValueKey. Names used more than once in the same section are
+// mapped to arrays of values or objects. If nodes with the same name are
+// not adjacent, the children of the section are stored in the field named by
+// ChildrenKey instead, as an array of objects with one field each, so their
+// order is kept. Values are mapped to JSON strings except "true" and "false",
+// which are mapped to booleans, and values which are valid JSON numbers,
+// which are mapped to numbers with the same text. Null is mapped to an empty
+// value. Names of fields have to be valid names of keys.
+//
+// The conversion from configuration data to JSON and back keeps names,
+// values and the order of nodes, comments are not exported.
+type JSONMapping struct {
+	// ValueKey is the name of the field with values of sections. It has to
+	// differ from all names of keys, DefaultJSONValueKey is used if empty.
+	ValueKey string
+	// ChildrenKey is the name of the field with ordered children of sections.
+	// It has to differ from all names of keys, DefaultJSONChildrenKey is used
+	// if empty.
+	ChildrenKey string
+	// Redactor redacts values of sensitive keys in exported documents,
+	// DefaultRedactor is used if nil.
+	Redactor *Redactor
+	// Raw disables redaction, values are exported as they are stored, i.e.
+	// decrypted secrets are still exported encrypted.
+	Raw bool
+}
+
+// ParseJSON parses configuration data from a JSON document with the default
+// mapping.
+func ParseJSON(r io.Reader) (Config, error) {
+	return JSONMapping{}.Parse(r)
+}
+
+// ToJSON converts configuration data to a JSON document with the default
+// mapping, values of sensitive keys are redacted by DefaultRedactor.
+func ToJSON(cfg Config) ([]byte, error) {
+	return JSONMapping{}.Marshal(cfg)
+}
+
+func (this JSONMapping) valueKey() string {
+	if this.ValueKey == "" {
+		return DefaultJSONValueKey
+	}
+	return this.ValueKey
+}
+
+func (this JSONMapping) childrenKey() string {
+	if this.ChildrenKey == "" {
+		return DefaultJSONChildrenKey
+	}
+	return this.ChildrenKey
+}
+
+// Parse parses configuration data from a JSON document. The document has to
+// be an object. Nodes get positions of their names, or of their values in
+// arrays, in the document.
+func (this JSONMapping) Parse(r io.Reader) (Config, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	in := newJSONInput(data)
+	b := newBuilder()
+	if err := this.expectDelim(in, '{'); err != nil {
+		return nil, err
+	}
+	if err := this.parseObject(in, b); err != nil {
+		return nil, err
+	}
+	if _, err := in.d.Token(); err != io.EOF {
+		return nil, in.error("unexpected data after the document")
+	}
+	return b.Config(), nil
+}
+
+// jsonInput is a JSON document being decoded, it maps offsets of the decoder
+// to lines and columns.
+type jsonInput struct {
+	d     *json.Decoder
+	data  []byte
+	lines []int
+}
+
+func newJSONInput(data []byte) *jsonInput {
+	in := &jsonInput{data: data, lines: make([]int, 0, 64)}
+	in.d = json.NewDecoder(bytes.NewReader(data))
+	in.d.UseNumber()
+	for index, b := range data {
+		if b == '\n' {
+			in.lines = append(in.lines, index)
+		}
+	}
+	return in
+}
+
+// next returns the position of the next token, i.e. the first byte after the
+// current offset which is not white space or a separator.
+func (this *jsonInput) next() Position {
+	offset := int(this.d.InputOffset())
+	for offset < len(this.data) && strings.IndexByte(" \t\r\n,:", this.data[offset]) >= 0 {
+		offset++
+	}
+	return this.position(offset)
+}
+
+func (this *jsonInput) position(offset int) Position {
+	line := sort.SearchInts(this.lines, offset)
+	start := 0
+	if line > 0 {
+		start = this.lines[line-1] + 1
+	}
+	return Position{Line: line + 1, Column: offset - start + 1}
+}
+
+// token returns the next token of the decoder, errors of the decoder are
+// returned as parse errors with their positions.
+func (this *jsonInput) token() (json.Token, error) {
+	tok, err := this.d.Token()
+	serr, ok := err.(*json.SyntaxError)
+	if err == io.EOF || err == io.ErrUnexpectedEOF || (ok && int(serr.Offset) >= len(this.data)) {
+		return nil, this.errorAt(this.position(len(this.data)), "unexpected end of input")
+	} else if ok {
+		offset := int(serr.Offset) - 1
+		if offset < 0 {
+			offset = 0
+		}
+		return nil, this.errorAt(this.position(offset), serr.Error())
+	}
+	return tok, err
+}
+
+func (this *jsonInput) error(msg string) error {
+	return this.errorAt(this.position(int(this.d.InputOffset())), msg)
+}
+
+func (this *jsonInput) errorAt(pos Position, msg string) error {
+	return &ParseError{Line: pos.Line, Column: pos.Column, Msg: "json: " + msg}
+}
+
+// parseObject parses fields of an object whose opening brace has been
+// already consumed, the closing brace is consumed too.
+func (this JSONMapping) parseObject(in *jsonInput, b *builder) error {
+	for {
+		pos := in.next()
+		tok, err := in.token()
+		if err != nil {
+			return err
+		}
+		if tok == json.Delim('}') {
+			return nil
+		}
+		name := tok.(string)
+		if name == this.valueKey() {
+			if err := this.parseSectionValue(in, b); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == this.childrenKey() {
+			if err := this.parseChildren(in, b); err != nil {
+				return err
+			}
+			continue
+		}
+		if !IsValidName(name) {
+			return in.errorAt(pos, fmt.Sprintf("key %q is not a valid name", name))
+		}
+		tok, err = in.token()
+		if err != nil {
+			return err
+		}
+		if tok == json.Delim('[') {
+			for in.d.More() {
+				pos := in.next()
+				if tok, err = in.token(); err != nil {
+					return err
+				}
+				if err := this.parseValue(in, b, name, tok, pos); err != nil {
+					return err
+				}
+			}
+			if _, err := in.token(); err != nil {
+				return err
+			}
+		} else if err := this.parseValue(in, b, name, tok, pos); err != nil {
+			return err
+		}
+	}
+}
+
+// parseChildren parses the array of ordered children, every object of the
+// array adds its fields to the current section.
+func (this JSONMapping) parseChildren(in *jsonInput, b *builder) error {
+	if err := this.expectDelim(in, '['); err != nil {
+		return err
+	}
+	for in.d.More() {
+		if err := this.expectDelim(in, '{'); err != nil {
+			return err
+		}
+		if err := this.parseObject(in, b); err != nil {
+			return err
+		}
+	}
+	_, err := in.token()
+	return err
+}
+
+func (this JSONMapping) parseSectionValue(in *jsonInput, b *builder) error {
+	tok, err := in.token()
+	if err != nil {
+		return err
+	}
+	val, ok := this.scalar(tok)
+	if !ok {
+		return in.error(fmt.Sprintf("field %s must be a scalar", this.valueKey()))
+	}
+	b.current().value = val
+	return nil
+}
+
+func (this JSONMapping) parseValue(in *jsonInput, b *builder, name string, tok json.Token, pos Position) error {
+	if tok == json.Delim('{') {
+		b.Section(name, "")
+		b.At(pos)
+		if err := this.parseObject(in, b); err != nil {
+			return err
+		}
+		b.CloseSection()
+		return nil
+	}
+	val, ok := this.scalar(tok)
+	if !ok {
+		return in.error(fmt.Sprintf("unexpected nested array in field %s", name))
+	}
+	b.String(name, val)
+	b.At(pos)
+	return nil
+}
+
+func (this JSONMapping) scalar(tok json.Token) (string, bool) {
+	switch val := tok.(type) {
+	case nil:
+		return "", true
+	case bool:
+		if val {
+			return "true", true
+		}
+		return "false", true
+	case json.Number:
+		return val.String(), true
+	case string:
+		return val, true
+	}
+	return "", false
+}
+
+func (this JSONMapping) expectDelim(in *jsonInput, delim json.Delim) error {
+	tok, err := in.token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return in.error(fmt.Sprintf("expected %s", delim))
+	}
+	return nil
+}
+
+// Marshal converts configuration data to a JSON document.
+func (this JSONMapping) Marshal(cfg Config) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if this.Redactor != nil && !this.Raw {
+		cfg = this.Redactor.Redact(cfg)
+	} else if !this.Raw {
+		cfg = DefaultRedactor.Redact(cfg)
+	}
+	if err := this.marshalSection(buf, cfg); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (this JSONMapping) marshalSection(buf *bytes.Buffer, cfg Config) error {
+	buf.WriteByte('{')
+	first := true
+	if cfg.Value() != "" {
+		this.marshalString(buf, this.valueKey())
+		buf.WriteByte(':')
+		this.marshalString(buf, cfg.Value())
+		first = false
+	}
+	children := Children(cfg)
+	if !this.grouped(children) {
+		if !first {
+			buf.WriteByte(',')
+		}
+		this.marshalString(buf, this.childrenKey())
+		buf.WriteString(":[")
+		for key, child := range children {
+			if key > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('{')
+			this.marshalString(buf, child.Name())
+			buf.WriteByte(':')
+			if err := this.marshalNode(buf, child); err != nil {
+				return err
+			}
+			buf.WriteByte('}')
+		}
+		buf.WriteString("]}")
+		return nil
+	}
+	done := make(map[string]bool)
+	for _, child := range children {
+		if done[child.Name()] {
+			continue
+		}
+		done[child.Name()] = true
+		group := make([]Config, 0, 1)
+		for _, other := range children {
+			if other.Name() == child.Name() {
+				group = append(group, other)
+			}
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		this.marshalString(buf, child.Name())
+		buf.WriteByte(':')
+		if len(group) > 1 {
+			buf.WriteByte('[')
+		}
+		for key, node := range group {
+			if key > 0 {
+				buf.WriteByte(',')
+			}
+			if err := this.marshalNode(buf, node); err != nil {
+				return err
+			}
+		}
+		if len(group) > 1 {
+			buf.WriteByte(']')
+		}
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+// grouped reports whether nodes with the same name are adjacent, so grouping
+// them to arrays keeps their order.
+func (this JSONMapping) grouped(children []Config) bool {
+	seen := make(map[string]bool)
+	for key, child := range children {
+		if key > 0 && children[key-1].Name() == child.Name() {
+			continue
+		}
+		if seen[child.Name()] {
+			return false
+		}
+		seen[child.Name()] = true
+	}
+	return true
+}
+
+func (this JSONMapping) marshalNode(buf *bytes.Buffer, cfg Config) error {
+	if IsSection(cfg) {
+		return this.marshalSection(buf, cfg)
+	}
+	val := StoredValue(cfg)
+	if val == "true" || val == "false" || this.isNumber(val) {
+		buf.WriteString(val)
+	} else {
+		this.marshalString(buf, val)
+	}
+	return nil
+}
+
+// marshalString writes the string as JSON, characters like '<' are not
+// escaped, so redacted values stay readable.
+func (this JSONMapping) marshalString(buf *bytes.Buffer, str string) {
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	e.Encode(str)
+	// The encoder ends every value with a newline.
+	buf.Truncate(buf.Len() - 1)
+}
+
+// isNumber reports whether the value is a valid JSON number.
+func (this JSONMapping) isNumber(val string) bool {
+	var num json.Number
+	return val != "" && val[0] != '"' && json.Unmarshal([]byte(val), &num) == nil && num.String() == val
+}
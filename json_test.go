@@ -0,0 +1,171 @@
+package config_test
+
+import "bytes"
+import "github.com/twoleds-golang/config"
+import "testing"
+
+func TestJSON(t *testing.T) {
+
+	var src = "Name \"Test String\"\n" +
+		"Enabled true\n" +
+		"Flag T\n" +
+		"Count 010\n" +
+		"Ratio 1.50\n" +
+		"Empty\n" +
+		"Host a\n" +
+		"Host b\n" +
+		"Section One {\n" +
+		"    IntValue -1\n" +
+		"}\n" +
+		"Section Two {\n" +
+		"    Nested {\n" +
+		"    }\n" +
+		"}\n"
+
+	var expected = `{"Name":"Test String","Enabled":true,"Flag":"T","Count":"010","Ratio":1.50,"Empty":"",` +
+		`"Host":["a","b"],"Section":[{"@value":"One","IntValue":-1},{"@value":"Two","Nested":{}}]}`
+
+	cfg, err := config.ParseFromString(src)
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	data, err := config.ToJSON(cfg)
+	if err != nil || string(data) != expected {
+		t.Errorf("Invalid JSON: %s", data)
+		t.FailNow()
+	}
+
+	parsed, err := config.ParseJSON(bytes.NewReader(data))
+	if err != nil {
+		t.Errorf("Cannot parse JSON: %s", err.Error())
+		t.FailNow()
+	}
+
+	formatted, _ := config.Format([]byte(src))
+	buf := new(bytes.Buffer)
+	w := config.NewWriter(buf)
+	for _, child := range config.Children(parsed) {
+		writeNode(w, child)
+	}
+	w.Flush()
+
+	if roundTrip, _ := config.Format(buf.Bytes()); string(roundTrip) != string(formatted) {
+		t.Errorf("Round trip is not lossless:\n%s", roundTrip)
+		t.Fail()
+	}
+
+	positions, err := config.ParseJSON(bytes.NewReader([]byte("{\n  \"Name\": \"a\",\n  \"Section\": [\n    {\"Key\": 1},\n    {}\n  ]\n}\n")))
+	if err != nil {
+		t.Errorf("Cannot parse JSON: %s", err.Error())
+		t.FailNow()
+	}
+	for query, expected := range map[string]string{"Name": "2:3", "Section": "4:5", "Section/Key": "4:6"} {
+		if node, ok := positions.Query(query); !ok || config.PositionOf(node).String() != expected {
+			t.Errorf("Invalid position for query '%s': %v", query, config.PositionOf(node))
+			t.Fail()
+		}
+	}
+	if sections := positions.QueryAll("Section"); len(sections) != 2 || config.PositionOf(sections[1]).String() != "5:5" {
+		t.Error("Invalid position of second section")
+		t.Fail()
+	}
+	if _, err := config.ParseJSON(bytes.NewReader([]byte("{\n  \"A\": [[1]]\n}"))); err == nil || err.Error() != "json: unexpected nested array in field A on line 2 at column 10" {
+		t.Errorf("Expected error with position, got %v", err)
+		t.Fail()
+	}
+
+	mapping := config.JSONMapping{ValueKey: "_value"}
+	if data, _ := mapping.Marshal(cfg); !bytes.Contains(data, []byte(`"_value":"One"`)) {
+		t.Errorf("Invalid value key: %s", data)
+		t.Fail()
+	}
+
+	if _, err := config.ParseJSON(bytes.NewReader([]byte(`{"A":[[1]]}`))); err == nil {
+		t.Error("Expected error for nested array")
+		t.Fail()
+	}
+
+	for src, msg := range map[string]string{
+		"{":                 "json: unexpected end of input on line 1 at column 2",
+		"{\n  \"A\": [1,\n": "json: unexpected end of input on line 3 at column 1",
+		"{\n  \"A\" 1\n}":   "json: invalid character '1' after object key on line 2 at column 7",
+	} {
+		if _, err := config.ParseJSON(bytes.NewReader([]byte(src))); err == nil || err.Error() != msg {
+			t.Errorf("Invalid error for truncated document %q: %v", src, err)
+			t.Fail()
+		}
+	}
+
+	if data, _ := config.ToJSON(config.NewBuilder().String("Password", "secret").String("Html", "<a href=\"x\">&</a>").Config()); string(data) != `{"Password":"<redacted>","Html":"<a href=\"x\">&</a>"}` {
+		t.Errorf("Invalid escaping: %s", data)
+		t.Fail()
+	}
+
+	if _, err := config.ParseJSON(bytes.NewReader([]byte("{\n  \"Server\": {\"bad key\": 1}\n}"))); err == nil || err.Error() != "json: key \"bad key\" is not a valid name on line 2 at column 14" {
+		t.Errorf("Expected error for invalid key, got %v", err)
+		t.Fail()
+	}
+
+}
+
+func TestJSONOrder(t *testing.T) {
+
+	var src = "Host a\n" +
+		"Port 80\n" +
+		"Host b\n" +
+		"Server Main {\n" +
+		"    Port 8080\n" +
+		"}\n" +
+		"Server Backup {\n" +
+		"    Port 8081\n" +
+		"}\n"
+
+	var expected = `{"@children":[{"Host":"a"},{"Port":80},{"Host":"b"},` +
+		`{"Server":{"@value":"Main","Port":8080}},{"Server":{"@value":"Backup","Port":8081}}]}`
+
+	cfg, err := config.ParseFromString(src)
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	data, err := config.ToJSON(cfg)
+	if err != nil || string(data) != expected {
+		t.Errorf("Invalid JSON: %s", data)
+		t.FailNow()
+	}
+
+	parsed, err := config.ParseJSON(bytes.NewReader(data))
+	if err != nil {
+		t.Errorf("Cannot parse JSON: %s", err.Error())
+		t.FailNow()
+	}
+
+	buf := new(bytes.Buffer)
+	w := config.NewWriter(buf)
+	for _, child := range config.Children(parsed) {
+		writeNode(w, child)
+	}
+	w.Flush()
+
+	formatted, _ := config.Format([]byte(src))
+	if roundTrip, _ := config.Format(buf.Bytes()); string(roundTrip) != string(formatted) {
+		t.Errorf("Order is not kept:\n%s", roundTrip)
+		t.Fail()
+	}
+
+}
+
+func writeNode(w config.Writer, cfg config.Config) {
+	if config.IsSection(cfg) {
+		w.Section(cfg.Name(), cfg.Value())
+		for _, child := range config.Children(cfg) {
+			writeNode(w, child)
+		}
+		w.CloseSection()
+	} else {
+		w.String(cfg.Name(), cfg.Value())
+	}
+}
@@ -0,0 +1,47 @@
+package config
+
+import "io"
+
+// Limits bound configuration data read by the parser, so a broken or hostile
+// input can't exhaust memory or the stack of functions which walk the tree.
+// Zero fields are unlimited. Exceeded limits are reported as parse errors.
+type Limits struct {
+	// MaxDepth is the maximum nesting of sections.
+	MaxDepth int
+	// MaxKeyLength is the maximum length of names of keys and sections in
+	// bytes.
+	MaxKeyLength int
+	// MaxValueLength is the maximum length of values, of queries of extended
+	// sections and of comments in bytes.
+	MaxValueLength int
+	// MaxNodes is the maximum number of keys and sections.
+	MaxNodes int
+}
+
+// DefaultLimits are used by the parse functions like ParseFromBytes, by
+// Format and by NewDecoder. The number of nodes is not limited, so huge
+// generated files can be parsed.
+var DefaultLimits = Limits{MaxDepth: 10000, MaxKeyLength: 4096, MaxValueLength: 16 << 20}
+
+// Parse parses configuration data from the reader within the limits.
+func (this Limits) Parse(reader io.Reader) (Config, error) {
+	p := newParser(reader)
+	p.limits = this
+	return decode(&decoder{p: p})
+}
+
+// ParseBytes parses configuration data from the slice of bytes within the
+// limits.
+func (this Limits) ParseBytes(data []byte) (Config, error) {
+	p := newBytesParser(data)
+	p.limits = this
+	return decode(&decoder{p: p})
+}
+
+// NewDecoder returns a decoder which reads configuration data from the reader
+// within the limits.
+func (this Limits) NewDecoder(reader io.Reader) Decoder {
+	p := newParser(reader)
+	p.limits = this
+	return &decoder{p: p}
+}
@@ -0,0 +1,139 @@
+// Package lint reports suspicious constructs in configuration files.
+package lint
+
+import "bytes"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "regexp"
+import "sort"
+import "strings"
+
+// File is a configuration file passed to rules.
+type File struct {
+	// Name of the file used in reported problems.
+	Name string
+	// Src holds content of the file.
+	Src []byte
+	// Lines holds content of the file split to lines without line endings.
+	Lines []string
+	// Config holds the parsed configuration.
+	Config config.Config
+	// values holds first and last lines of values which span more lines.
+	values [][2]int
+}
+
+// inString reports whether the line starts inside of a quoted value, or ends
+// inside of it if start is false.
+func (this *File) inString(line int, start bool) bool {
+	for _, value := range this.values {
+		if (start && line > value[0] && line <= value[1]) || (!start && line >= value[0] && line < value[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Problem describes a single problem found by a rule.
+type Problem struct {
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+func (this Problem) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", this.File, this.Line, this.Column, this.Rule, this.Message)
+}
+
+// Rule checks a configuration file and reports found problems.
+type Rule interface {
+	// Name returns name of the rule used in reports and disable comments.
+	Name() string
+	// Check reports problems found in the specified file.
+	Check(file *File, report func(pos config.Position, msg string))
+}
+
+// SyntaxRule is the name used for problems reported when the file cannot be
+// parsed at all.
+const SyntaxRule = "syntax"
+
+// DefaultRules returns rules which don't need any external information.
+func DefaultRules() []Rule {
+	return []Rule{
+		DuplicateKey(),
+		CaseMismatch(),
+		SuspiciousValue(),
+		TrailingWhitespace(),
+		MixedIndentation(),
+		DeepNesting(4),
+	}
+}
+
+var disableComment = regexp.MustCompile(`^#\s*configlint:disable=([A-Za-z0-9_,-]+)`)
+
+// Lint checks the configuration file with the specified rules and returns
+// found problems sorted by position. Rules can be disabled for the whole file
+// with a "# configlint:disable=rule,rule" comment.
+func Lint(name string, src []byte, rules []Rule) []Problem {
+	problems := make([]Problem, 0, 16)
+
+	cfg, err := config.ParseFromBytes(src)
+	if err != nil {
+		problem := Problem{File: name, Line: 1, Column: 1, Rule: SyntaxRule, Message: err.Error()}
+		if perr, ok := err.(*config.ParseError); ok {
+			problem.Line = perr.Line
+			problem.Column = perr.Column
+			problem.Message = perr.Msg
+		}
+		return append(problems, problem)
+	}
+
+	file := new(File)
+	file.Name = name
+	file.Src = src
+	file.Lines = strings.Split(strings.Replace(string(src), "\r\n", "\n", -1), "\n")
+	file.Config = cfg
+
+	disabled := make(map[string]bool)
+	decoder := config.NewDecoder(bytes.NewReader(src))
+	for {
+		tok, err := decoder.Next()
+		if err != nil {
+			break
+		}
+		switch tok := tok.(type) {
+		case config.Comment:
+			// Values which only look like a disable comment are not
+			// taken into account.
+			if match := disableComment.FindStringSubmatch(tok.Text); match != nil {
+				for _, rule := range strings.Split(match[1], ",") {
+					disabled[rule] = true
+				}
+			}
+		case config.KeyValue:
+			if lines := bytes.Count(src[tok.Start:tok.End], []byte{'\n'}); lines > 0 {
+				file.values = append(file.values, [2]int{tok.Pos.Line, tok.Pos.Line + lines})
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		if disabled[rule.Name()] {
+			continue
+		}
+		name := rule.Name()
+		rule.Check(file, func(pos config.Position, msg string) {
+			problems = append(problems, Problem{File: file.Name, Line: pos.Line, Column: pos.Column, Rule: name, Message: msg})
+		})
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Line != problems[j].Line {
+			return problems[i].Line < problems[j].Line
+		}
+		return problems[i].Column < problems[j].Column
+	})
+
+	return problems
+}
@@ -0,0 +1,97 @@
+package lint_test
+
+import "github.com/twoleds-golang/config/lint"
+import "github.com/twoleds-golang/config/schema"
+import "testing"
+
+func TestLint(t *testing.T) {
+
+	var src = "Port 80\n" +
+		"port 81\n" +
+		"Port 82 \n" +
+		"Debug T\n" +
+		"Section One {\n" +
+		"    A {\n" +
+		"\t\tB {\n" +
+		"        }\n" +
+		"    }\n" +
+		"}\n"
+
+	s, err := schema.ParseFromString("Key Port {\n    Type int\n}\nKey Timeout {\n}\nKey Mode {\n    Default debug\n}\n" +
+		"Section Section {\n    Key Workers {\n    }\n}\nSection Cache {\n}\n")
+	if err != nil {
+		t.Errorf("Cannot parse schema: %s", err.Error())
+		t.FailNow()
+	}
+
+	rules := append(lint.DefaultRules(), lint.UnknownKey(s), lint.UnusedKey(s))
+	for key, rule := range rules {
+		if rule.Name() == "deep-nesting" {
+			rules[key] = lint.DeepNesting(2)
+		}
+	}
+
+	expected := []string{
+		"test.conf:1:1: unused-key: key Timeout of the schema is not used",
+		"test.conf:1:1: unused-key: section Cache of the schema is not used",
+		"test.conf:2:1: case-mismatch: name port differs only in case from Port",
+		"test.conf:2:1: unknown-key: unknown name port",
+		"test.conf:3:1: duplicate-key: key Port is already defined at 1:1",
+		"test.conf:3:8: trailing-whitespace: trailing whitespace",
+		"test.conf:4:1: suspicious-value: boolean value T should be written as true",
+		"test.conf:4:1: unknown-key: unknown name Debug",
+		"test.conf:5:1: unused-key: key Workers of the schema is not used",
+		"test.conf:6:5: unknown-key: unknown name A",
+		"test.conf:7:1: mixed-indentation: indentation uses tabs, file uses spaces",
+		"test.conf:7:3: deep-nesting: section B is nested deeper than 2 levels",
+	}
+
+	problems := lint.Lint("test.conf", []byte(src), rules)
+	if len(problems) != len(expected) {
+		t.Errorf("Invalid number of problems: %v", problems)
+		t.FailNow()
+	}
+
+	for key, problem := range problems {
+		if problem.String() != expected[key] {
+			t.Errorf("Invalid problem %q, expected %q", problem.String(), expected[key])
+			t.Fail()
+		}
+	}
+
+	problems = lint.Lint("test.conf", []byte("# configlint:disable=suspicious-value\nDebug T\n"), lint.DefaultRules())
+	if len(problems) != 0 {
+		t.Errorf("Disabled rule reported problems: %v", problems)
+		t.Fail()
+	}
+
+	problems = lint.Lint("test.conf", []byte("Note \"# configlint:disable=suspicious-value\"\nDebug T\n"), lint.DefaultRules())
+	if len(problems) != 1 || problems[0].Rule != "suspicious-value" {
+		t.Errorf("Value disabled a rule: %v", problems)
+		t.Fail()
+	}
+
+	problems = lint.Lint("test.conf", []byte("Server Main {\n}\nServer Backup {\n}\nServer Main {\n}\n"), lint.DefaultRules())
+	if len(problems) != 1 || problems[0].String() != "test.conf:5:1: duplicate-key: section Server Main is already defined at 1:1" {
+		t.Errorf("Invalid duplicate section problems: %v", problems)
+		t.Fail()
+	}
+
+	src = "Text \"line  \n" +
+		"\tindented\" \n" +
+		"Section {\n" +
+		"    Key 1\n" +
+		"}\n"
+	problems = lint.Lint("test.conf", []byte(src), lint.DefaultRules())
+	if len(problems) != 1 || problems[0].String() != "test.conf:2:11: trailing-whitespace: trailing whitespace" {
+		t.Errorf("Invalid problems of quoted value: %v", problems)
+		t.Fail()
+	}
+
+	problems = lint.Lint("test.conf", []byte("Section {\n"), lint.DefaultRules())
+	if len(problems) != 1 || problems[0].Rule != lint.SyntaxRule {
+		t.Errorf("Invalid syntax problem: %v", problems)
+		t.Fail()
+	}
+
+}
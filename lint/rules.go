@@ -0,0 +1,290 @@
+package lint
+
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "github.com/twoleds-golang/config/schema"
+import "strconv"
+import "strings"
+
+// walk calls the function for every section of the configuration including
+// the root, depth of the root is zero.
+func walk(cfg config.Config, depth int, fn func(section config.Config, depth int)) {
+	fn(cfg, depth)
+	for _, child := range config.Children(cfg) {
+		if config.IsSection(child) {
+			walk(child, depth+1, fn)
+		}
+	}
+}
+
+type duplicateKey struct{}
+
+// DuplicateKey reports keys defined more than once in the same section and
+// sections with the same name and value, only the first one is returned by
+// Config.Query. Conditional blocks may repeat.
+func DuplicateKey() Rule {
+	return duplicateKey{}
+}
+
+func (this duplicateKey) Name() string {
+	return "duplicate-key"
+}
+
+func (this duplicateKey) Check(file *File, report func(pos config.Position, msg string)) {
+	walk(file.Config, 0, func(section config.Config, depth int) {
+		keys := make(map[string]config.Position)
+		sections := make(map[[2]string]config.Position)
+		for _, child := range config.Children(section) {
+			if config.IsBlock(child) {
+				continue
+			} else if config.IsSection(child) {
+				id := [2]string{child.Name(), child.Value()}
+				if pos, ok := sections[id]; ok {
+					report(config.PositionOf(child), fmt.Sprintf("section %s is already defined at %s", strings.TrimSpace(child.Name()+" "+child.Value()), pos))
+				} else {
+					sections[id] = config.PositionOf(child)
+				}
+			} else if pos, ok := keys[child.Name()]; ok {
+				report(config.PositionOf(child), fmt.Sprintf("key %s is already defined at %s", child.Name(), pos))
+			} else {
+				keys[child.Name()] = config.PositionOf(child)
+			}
+		}
+	})
+}
+
+type caseMismatch struct{}
+
+// CaseMismatch reports keys and sections whose names differ only in case from
+// a name used earlier in the same section.
+func CaseMismatch() Rule {
+	return caseMismatch{}
+}
+
+func (this caseMismatch) Name() string {
+	return "case-mismatch"
+}
+
+func (this caseMismatch) Check(file *File, report func(pos config.Position, msg string)) {
+	walk(file.Config, 0, func(section config.Config, depth int) {
+		seen := make(map[string]string)
+		for _, child := range config.Children(section) {
+			folded := strings.ToLower(child.Name())
+			if name, ok := seen[folded]; ok && name != child.Name() {
+				report(config.PositionOf(child), fmt.Sprintf("name %s differs only in case from %s", child.Name(), name))
+			} else if !ok {
+				seen[folded] = child.Name()
+			}
+		}
+	})
+}
+
+type unknownKey struct {
+	schema *schema.Schema
+}
+
+// UnknownKey reports keys and sections which are not described by the
+// schema. A name is known when the schema describes a key or a section with
+// the same name at the same place. Values of sections are not taken into
+// account, include and use directives are always known.
+func UnknownKey(s *schema.Schema) Rule {
+	return unknownKey{s}
+}
+
+func (this unknownKey) Name() string {
+	return "unknown-key"
+}
+
+func (this unknownKey) Check(file *File, report func(pos config.Position, msg string)) {
+	this.check(file.Config, this.schema, report)
+}
+
+func (this unknownKey) check(section config.Config, s *schema.Schema, report func(pos config.Position, msg string)) {
+	for _, child := range config.Children(section) {
+		if config.IsBlock(child) {
+			this.check(child, s, report)
+			continue
+		}
+		if child.Name() == config.IncludeDirective || child.Name() == config.UseDirective {
+			continue
+		}
+		if config.IsSection(child) {
+			if next := s.Section(child.Name()); next != nil {
+				this.check(child, &next.Schema, report)
+				continue
+			}
+		} else if s.Key(child.Name()) != nil {
+			continue
+		}
+		report(config.PositionOf(child), fmt.Sprintf("unknown name %s", child.Name()))
+	}
+}
+
+type unusedKey struct {
+	schema *schema.Schema
+}
+
+// UnusedKey reports keys and sections described by the schema which are not
+// set in the file, so forgotten settings and stale entries of the schema
+// show up. Keys with a default are used through it. Problems are reported at
+// the section which should contain the key, or at the first line of the file.
+// Nested keys are checked only in sections which are set.
+func UnusedKey(s *schema.Schema) Rule {
+	return unusedKey{s}
+}
+
+func (this unusedKey) Name() string {
+	return "unused-key"
+}
+
+func (this unusedKey) Check(file *File, report func(pos config.Position, msg string)) {
+	this.check([]config.Config{file.Config}, config.Position{Line: 1, Column: 1}, this.schema, report)
+}
+
+func (this unusedKey) check(sections []config.Config, pos config.Position, s *schema.Schema, report func(pos config.Position, msg string)) {
+	keys := make(map[string]bool)
+	nested := make(map[string][]config.Config)
+	for _, section := range sections {
+		this.collect(section, keys, nested)
+	}
+	for _, key := range s.Keys {
+		if !keys[key.Name] && key.Default == "" {
+			report(pos, fmt.Sprintf("key %s of the schema is not used", key.Name))
+		}
+	}
+	for _, section := range s.Sections {
+		if found := nested[section.Name]; len(found) == 0 {
+			report(pos, fmt.Sprintf("section %s of the schema is not used", section.Name))
+		} else {
+			this.check(found, config.PositionOf(found[0]), &section.Schema, report)
+		}
+	}
+}
+
+// collect records names of keys and sections of the section, children of
+// conditional blocks count too.
+func (this unusedKey) collect(section config.Config, keys map[string]bool, nested map[string][]config.Config) {
+	for _, child := range config.Children(section) {
+		if config.IsBlock(child) {
+			this.collect(child, keys, nested)
+		} else if config.IsSection(child) {
+			nested[child.Name()] = append(nested[child.Name()], child)
+		} else {
+			keys[child.Name()] = true
+		}
+	}
+}
+
+type suspiciousValue struct{}
+
+// SuspiciousValue reports boolean values which are not written as "true" or
+// "false", e.g. "T", "TRUE" or "yes". Words like "yes" or "on" are not
+// recognized by Config.Bool at all.
+func SuspiciousValue() Rule {
+	return suspiciousValue{}
+}
+
+func (this suspiciousValue) Name() string {
+	return "suspicious-value"
+}
+
+func (this suspiciousValue) Check(file *File, report func(pos config.Position, msg string)) {
+	walk(file.Config, 0, func(section config.Config, depth int) {
+		for _, child := range config.Children(section) {
+			val := child.Value()
+			if config.IsSection(child) || val == "true" || val == "false" || val == "0" || val == "1" {
+				continue
+			}
+			if b, err := strconv.ParseBool(val); err == nil {
+				report(config.PositionOf(child), fmt.Sprintf("boolean value %s should be written as %t", val, b))
+			}
+			switch strings.ToLower(val) {
+			case "yes", "on":
+				report(config.PositionOf(child), fmt.Sprintf("value %s is not a boolean, use true", val))
+			case "no", "off":
+				report(config.PositionOf(child), fmt.Sprintf("value %s is not a boolean, use false", val))
+			}
+		}
+	})
+}
+
+type trailingWhitespace struct{}
+
+// TrailingWhitespace reports spaces and tabs at the end of lines, except
+// lines which end inside of a quoted value.
+func TrailingWhitespace() Rule {
+	return trailingWhitespace{}
+}
+
+func (this trailingWhitespace) Name() string {
+	return "trailing-whitespace"
+}
+
+func (this trailingWhitespace) Check(file *File, report func(pos config.Position, msg string)) {
+	for key, line := range file.Lines {
+		if file.inString(key+1, false) {
+			continue
+		}
+		if trimmed := strings.TrimRight(line, " \t"); len(trimmed) != len(line) {
+			report(config.Position{Line: key + 1, Column: len(trimmed) + 1}, "trailing whitespace")
+		}
+	}
+}
+
+type mixedIndentation struct{}
+
+// MixedIndentation reports lines indented with a different character than
+// the first indented line of the file and lines indented with both spaces
+// and tabs. Lines which start inside of a quoted value are skipped.
+func MixedIndentation() Rule {
+	return mixedIndentation{}
+}
+
+func (this mixedIndentation) Name() string {
+	return "mixed-indentation"
+}
+
+func (this mixedIndentation) Check(file *File, report func(pos config.Position, msg string)) {
+	var style byte
+	for key, line := range file.Lines {
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if indent == "" || indent == line || file.inString(key+1, true) {
+			continue
+		}
+		if strings.IndexByte(indent, ' ') >= 0 && strings.IndexByte(indent, '\t') >= 0 {
+			report(config.Position{Line: key + 1, Column: 1}, "indentation mixes spaces and tabs")
+		} else if style == 0 {
+			style = indent[0]
+		} else if indent[0] != style {
+			report(config.Position{Line: key + 1, Column: 1}, fmt.Sprintf("indentation uses %s, file uses %s", this.describe(indent[0]), this.describe(style)))
+		}
+	}
+}
+
+func (this mixedIndentation) describe(b byte) string {
+	if b == '\t' {
+		return "tabs"
+	}
+	return "spaces"
+}
+
+type deepNesting struct {
+	max int
+}
+
+// DeepNesting reports sections nested deeper than the specified level.
+func DeepNesting(max int) Rule {
+	return deepNesting{max}
+}
+
+func (this deepNesting) Name() string {
+	return "deep-nesting"
+}
+
+func (this deepNesting) Check(file *File, report func(pos config.Position, msg string)) {
+	walk(file.Config, 0, func(section config.Config, depth int) {
+		if depth == this.max+1 {
+			report(config.PositionOf(section), fmt.Sprintf("section %s is nested deeper than %d levels", section.Name(), this.max))
+		}
+	})
+}
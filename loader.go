@@ -0,0 +1,404 @@
+package config
+
+import "context"
+import "errors"
+import "fmt"
+import "strings"
+import "sync"
+import "time"
+
+// Loader loads configuration data from several sources. The sources are
+// loaded in order and merged with Merge, so later sources win. The merged
+// data are interpolated and validated. Positions of the loaded nodes have the
+// name of their source as the layer.
+type Loader interface {
+	// Add appends sources to the loader.
+	Add(sources ...Source) Loader
+	// Decrypt enables decryption of encrypted values with keys of the
+	// provider, see DecryptSecrets. Values are decrypted after interpolation.
+	Decrypt(p KeyProvider) Loader
+	// Explain loads configuration data like Load and describes how the value
+	// of the key matched by the query was composed from the sources.
+	// Validation functions are not called, so invalid values can be explained
+	// too.
+	Explain(ctx context.Context, query string) (*Explanation, error)
+	// Interpolate enables or disables interpolation of values, see the
+	// function Interpolate. Interpolation is enabled by default.
+	Interpolate(enabled bool) Loader
+	// Load loads, merges, interpolates and validates configuration data. The
+	// report is returned even if loading fails.
+	Load(ctx context.Context) (Config, *Report, error)
+	// Profiles sets active profiles and tags for conditional blocks, see
+	// ResolveProfiles. Blocks are resolved in every source before merging,
+	// without profiles only blocks activated by negations are active.
+	Profiles(p *Profiles) Loader
+	// Validate adds a function which checks the loaded configuration data,
+	// e.g. against a schema. Functions are called in order of addition.
+	Validate(fn func(cfg Config) error) Loader
+	// Watch loads configuration data and calls the function with the result
+	// every time a source which implements Watcher reports a change. Watch
+	// blocks until the context is done. A watcher which fails is stopped and
+	// its error is passed to the function, Watch returns the error of the
+	// last watcher once every watcher has failed.
+	Watch(ctx context.Context, fn func(cfg Config, report *Report, err error)) error
+}
+
+// Report describes the last load of a loader.
+type Report struct {
+	Sources []SourceReport
+}
+
+// SourceReport describes the load of a single source.
+type SourceReport struct {
+	// Name is the name of the source.
+	Name string
+	// Keys is the number of keys provided by the source.
+	Keys int
+	// Duration is the time spent loading the source.
+	Duration time.Duration
+	// Err is the error of the source, nil if the source was loaded.
+	Err error
+}
+
+func (this *Report) String() string {
+	lines := make([]string, 0, len(this.Sources))
+	for _, source := range this.Sources {
+		if source.Err != nil {
+			lines = append(lines, fmt.Sprintf("%s: %s", source.Name, source.Err.Error()))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: %d keys in %s", source.Name, source.Keys, source.Duration))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+type loader struct {
+	sources     []Source
+	validators  []func(cfg Config) error
+	interpolate bool
+	keys        KeyProvider
+	profiles    *Profiles
+}
+
+var _ Loader = new(loader)
+
+// NewLoader returns a loader of the specified sources.
+func NewLoader(sources ...Source) Loader {
+	l := new(loader)
+	l.sources = sources
+	l.interpolate = true
+	l.profiles = new(Profiles)
+	return l
+}
+
+func (this *loader) Add(sources ...Source) Loader {
+	this.sources = append(this.sources, sources...)
+	return this
+}
+
+func (this *loader) Decrypt(p KeyProvider) Loader {
+	this.keys = p
+	return this
+}
+
+func (this *loader) Explain(ctx context.Context, query string) (*Explanation, error) {
+	e := &Explanation{Query: query}
+	cfg, _, err := this.load(ctx, e)
+	if err != nil {
+		return nil, err
+	}
+	if node, ok := cfg.Query(query); ok && !IsSection(node) {
+		e.Value, e.Found = node.Value(), true
+		e.sensitive = e.sensitive || DefaultRedactor.IsSensitiveName(node.Name())
+	}
+	return e, nil
+}
+
+func (this *loader) Interpolate(enabled bool) Loader {
+	this.interpolate = enabled
+	return this
+}
+
+func (this *loader) Load(ctx context.Context) (Config, *Report, error) {
+	return this.load(ctx, nil)
+}
+
+// sourceError prefixes the error by the name of the source, parse errors
+// which already refer to a file are returned as they are.
+func sourceError(source Source, err error) error {
+	if perr, ok := err.(*ParseError); ok && perr.Source != "" {
+		return err
+	}
+	return errors.New(fmt.Sprintf("%s: %s", source.Name(), err.Error()))
+}
+
+// load loads configuration data, the explanation is filled if not nil.
+func (this *loader) load(ctx context.Context, e *Explanation) (Config, *Report, error) {
+	report := new(Report)
+	cfgs := make([]Config, 0, len(this.sources))
+	for _, source := range this.sources {
+		start := time.Now()
+		cfg, err := source.Load(ctx)
+		entry := SourceReport{Name: source.Name(), Duration: time.Since(start), Err: err}
+		if err != nil {
+			report.Sources = append(report.Sources, entry)
+			return nil, report, sourceError(source, err)
+		}
+		layer := cloneConfig(cfg)
+		if err := layer.resolveBlocks(this.profiles); err != nil {
+			entry.Err = err
+			report.Sources = append(report.Sources, entry)
+			return nil, report, errors.New(fmt.Sprintf("%s: %s", source.Name(), err.Error()))
+		}
+		entry.Keys = countKeys(layer)
+		report.Sources = append(report.Sources, entry)
+		layer.setLayer(source.Name())
+		cfgs = append(cfgs, layer)
+		e.source(source.Name(), layer)
+	}
+	merged := Merge(newBuilder().Config(), cfgs...)
+	cfg, err := ResolveExtends(merged)
+	if err != nil {
+		return nil, report, err
+	}
+	e.inherited(merged, cfg)
+	if this.interpolate {
+		resolved := cfg
+		if cfg, err = Interpolate(cfg); err != nil {
+			return nil, report, err
+		}
+		e.interpolated(resolved, cfg)
+	}
+	if this.keys != nil {
+		if cfg, err = DecryptSecrets(cfg, this.keys); err != nil {
+			return nil, report, err
+		}
+		e.decrypted(cfg)
+	}
+	// Explanations describe invalid values too, so validators are skipped.
+	if e == nil {
+		for _, fn := range this.validators {
+			if err := fn(cfg); err != nil {
+				return nil, report, err
+			}
+		}
+	}
+	return cfg, report, nil
+}
+
+func (this *loader) Profiles(p *Profiles) Loader {
+	this.profiles = p
+	if p == nil {
+		this.profiles = new(Profiles)
+	}
+	return this
+}
+
+func (this *loader) Validate(fn func(cfg Config) error) Loader {
+	this.validators = append(this.validators, fn)
+	return this
+}
+
+func (this *loader) Watch(ctx context.Context, fn func(cfg Config, report *Report, err error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	changes := make(chan struct{}, 1)
+	watchers := new(sync.WaitGroup)
+	// Watchers are stopped before Watch returns.
+	defer watchers.Wait()
+	defer cancel()
+	stopped := make(chan error, len(this.sources))
+	watched := 0
+	for _, source := range this.sources {
+		if watcher, ok := source.(Watcher); ok {
+			source := source
+			watched++
+			watchers.Add(1)
+			go func() {
+				defer watchers.Done()
+				for {
+					err := watcher.Watch(ctx)
+					if err != nil {
+						stopped <- sourceError(source, err)
+						return
+					}
+					select {
+					case changes <- struct{}{}:
+					default:
+					}
+				}
+			}()
+		}
+	}
+	if watched == 0 {
+		return errors.New("No source can be watched")
+	}
+	fn(this.Load(ctx))
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changes:
+			fn(this.Load(ctx))
+		case err := <-stopped:
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			// The error of the last watcher is returned, the errors of the
+			// others are passed to the function.
+			watched--
+			if watched == 0 {
+				return err
+			}
+			fn(nil, nil, err)
+		}
+	}
+}
+
+func countKeys(cfg Config) int {
+	count := 0
+	for _, child := range Children(cfg) {
+		if IsSection(child) {
+			count = count + countKeys(child)
+		} else {
+			count = count + 1
+		}
+	}
+	return count
+}
+
+// Interpolate returns configuration data where references "${query}" in
+// values are replaced by values of the queries in the same configuration
+// data, e.g. "${Section:Two/Host}:${Section:Two/Port}". Referenced values may
+// contain references too, "$$" stands for a single "$". References to
+// missing keys and cycles are errors. A secret, encrypted or decrypted, can
+// be referenced only by a whole value, so it is never stored in plain text.
+// Chains of whole values like "${Password}" are followed, so such a key can
+// reference another key which references the secret.
+// The loader interpolates values before it decrypts them, so secrets copied
+// by references are decrypted too.
+func Interpolate(cfg Config) (Config, error) {
+	// References are resolved against the original values, so escaped
+	// references are not resolved twice.
+	source, root := cloneConfig(cfg), cloneConfig(cfg)
+	i := &interpolator{root: source, values: make(map[*config]string), visiting: make(map[*config]bool)}
+	var walk func(node *config) error
+	walk = func(node *config) error {
+		for _, child := range node.children {
+			if child.children != nil {
+				if err := walk(child); err != nil {
+					return err
+				}
+				continue
+			}
+			// A secret referenced by the whole value is copied together with
+			// its encrypted value.
+			if ref := i.secret(child.value); ref != nil {
+				child.value, child.secret = ref.Value(), ref.secret
+				continue
+			}
+			val, err := i.interpolate(child.value)
+			if err != nil {
+				return errors.New(fmt.Sprintf("%s: %s", child.locate(child.name), err.Error()))
+			}
+			child.value = val
+		}
+		return nil
+	}
+	if err := walk(root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// interpolator resolves references of Interpolate. Resolved values of keys
+// are kept, so every key is resolved once, and keys being resolved are
+// visited, so a reference back to one of them is a cycle.
+type interpolator struct {
+	root     *config
+	values   map[*config]string
+	visiting map[*config]bool
+	stack    []string
+}
+
+// secret returns the secret referenced by the whole value, directly or
+// through keys which reference it by their whole value too, nil otherwise.
+func (this *interpolator) secret(val string) *config {
+	seen := make(map[*config]bool)
+	for strings.HasPrefix(val, "${") && strings.IndexByte(val, '}') == len(val)-1 {
+		found, ok := this.root.Query(val[2 : len(val)-1])
+		if !ok || IsSection(found) {
+			return nil
+		}
+		node := found.(*config)
+		if node.isSecret() {
+			return node
+		}
+		if seen[node] {
+			return nil
+		}
+		seen[node] = true
+		val = node.Value()
+	}
+	return nil
+}
+
+// interpolate returns the value with its references replaced.
+func (this *interpolator) interpolate(val string) (string, error) {
+	if strings.IndexByte(val, '$') < 0 {
+		return val, nil
+	}
+	buf := make([]byte, 0, len(val))
+	for index := 0; index < len(val); index++ {
+		if val[index] != '$' || index+1 >= len(val) {
+			buf = append(buf, val[index])
+			continue
+		}
+		switch val[index+1] {
+		case '$':
+			buf = append(buf, '$')
+			index++
+		case '{':
+			end := strings.IndexByte(val[index:], '}')
+			if end < 0 {
+				return "", errors.New("Unterminated reference")
+			}
+			ref, err := this.resolve(val[index+2 : index+end])
+			if err != nil {
+				return "", err
+			}
+			buf = append(buf, ref...)
+			index = index + end
+		default:
+			buf = append(buf, '$')
+		}
+	}
+	return string(buf), nil
+}
+
+// resolve returns the interpolated value of the key matched by the query.
+func (this *interpolator) resolve(query string) (string, error) {
+	found, ok := this.root.Query(query)
+	if !ok || IsSection(found) {
+		return "", errors.New(fmt.Sprintf("Reference '%s' does not match", query))
+	}
+	node := found.(*config)
+	if node.isSecret() {
+		return "", errors.New(fmt.Sprintf("Secret '%s' cannot be embedded in a value", query))
+	}
+	if val, ok := this.values[node]; ok {
+		return val, nil
+	}
+	this.stack = append(this.stack, query)
+	defer func() { this.stack = this.stack[:len(this.stack)-1] }()
+	if this.visiting[node] {
+		return "", errors.New(fmt.Sprintf("Cyclic reference: %s", strings.Join(this.stack, " -> ")))
+	}
+	this.visiting[node] = true
+	defer delete(this.visiting, node)
+	val, err := this.interpolate(node.Value())
+	if err != nil {
+		return "", err
+	}
+	this.values[node] = val
+	return val, nil
+}
@@ -0,0 +1,291 @@
+package config_test
+
+import "context"
+import "errors"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "os"
+import "path/filepath"
+import "strings"
+import "testing"
+import "testing/fstest"
+import "time"
+
+func TestLoader(t *testing.T) {
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "10-base.conf"), []byte("Host localhost\nPort 80\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "20-local.json"), []byte(`{"Port":8080}`), 0644)
+
+	fsys := fstest.MapFS{
+		"defaults.conf": &fstest.MapFile{Data: []byte("Name default\nDebug false\n")},
+	}
+
+	l := config.NewLoader(
+		config.FSSource(fsys, "defaults.conf"),
+		config.DirSource(dir, "*"),
+		config.OptionalSource(config.FileSource(filepath.Join(dir, "missing.conf"))),
+		config.BytesSource("inline.toml", []byte("Url = \"http://${Host}:${Port}/$${path}\"\n")),
+		config.EnvSource("APP_", &config.EnvOptions{Environ: []string{"APP_DEBUG=true"}, FoldCase: true}),
+	)
+
+	cfg, report, err := l.Load(context.Background())
+	if err != nil {
+		t.Errorf("Cannot load config: %s", err.Error())
+		t.FailNow()
+	}
+
+	var tests = map[string]string{
+		"Name":  "default",
+		"Debug": "true",
+		"Port":  "8080",
+		"Url":   "http://localhost:8080/${path}",
+	}
+
+	for query, expected := range tests {
+		if val, ok := cfg.String(query); !ok || val != expected {
+			t.Errorf("Invalid value for query '%s': %q", query, val)
+			t.Fail()
+		}
+	}
+
+	var positions = map[string]string{
+		"Name":  "defaults.conf:1:1",
+		"Debug": "env:APP_DEBUG (env:APP_)",
+		"Port":  filepath.Join(dir, "20-local.json") + ":1:2 (" + filepath.Join(dir, "*") + ")",
+		"Host":  filepath.Join(dir, "10-base.conf") + ":1:1 (" + filepath.Join(dir, "*") + ")",
+	}
+
+	for query, expected := range positions {
+		if node, ok := cfg.Query(query); !ok || config.PositionOf(node).String() != expected {
+			t.Errorf("Invalid position for query '%s': %v", query, config.PositionOf(node))
+			t.Fail()
+		}
+	}
+
+	if len(report.Sources) != 5 || report.Sources[1].Keys != 2 || report.Sources[2].Keys != 0 {
+		t.Errorf("Invalid report:\n%s", report)
+		t.Fail()
+	}
+
+	l.Validate(func(cfg config.Config) error {
+		if cfg.StringOrDefault("Name", "") == "default" {
+			return errors.New("Name is not set")
+		}
+		return nil
+	})
+	if _, _, err := l.Load(context.Background()); err == nil || err.Error() != "Name is not set" {
+		t.Error("Expected validation error")
+		t.Fail()
+	}
+
+	l = config.NewLoader(config.FileSource(filepath.Join(dir, "missing.conf")))
+	if _, report, err := l.Load(context.Background()); err == nil || report.Sources[0].Err == nil {
+		t.Error("Expected error for missing file")
+		t.Fail()
+	}
+
+	if _, err := config.Interpolate(config.NewBuilder().String("A", "${B}").String("B", "${C}").String("C", "${B}").Config()); err == nil || !strings.HasSuffix(err.Error(), "Cyclic reference: B -> C -> B") {
+		t.Errorf("Expected error for cyclic reference, got %v", err)
+		t.Fail()
+	}
+
+	// A long chain of references is not a cycle.
+	b := config.NewBuilder()
+	for index := 0; index < 100; index++ {
+		b.String(fmt.Sprintf("K%d", index), fmt.Sprintf("${K%d}", index+1))
+	}
+	b.String("K100", "end")
+	if chain, err := config.Interpolate(b.Config()); err != nil || chain.StringOrDefault("K0", "") != "end" {
+		t.Errorf("Cannot resolve chain of references: %v", err)
+		t.Fail()
+	}
+
+}
+
+func TestLoaderWatch(t *testing.T) {
+
+	config.WatchInterval = 10 * time.Millisecond
+	defer func() { config.WatchInterval = time.Second }()
+
+	file := filepath.Join(t.TempDir(), "app.conf")
+	os.WriteFile(file, []byte("Port 80\n"), 0644)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ports := make([]int64, 0, 2)
+	err := config.NewLoader(config.FileSource(file)).Watch(ctx, func(cfg config.Config, report *config.Report, err error) {
+		if err != nil {
+			t.Errorf("Cannot load config: %s", err.Error())
+			cancel()
+			return
+		}
+		ports = append(ports, cfg.IntOrDefault("Port", 0))
+		if len(ports) == 1 {
+			os.WriteFile(file, []byte("Port 8080\n"), 0644)
+		} else {
+			cancel()
+		}
+	})
+
+	if err != context.Canceled || len(ports) != 2 || ports[1] != 8080 {
+		t.Errorf("Invalid reloads: %v %v", err, ports)
+		t.Fail()
+	}
+
+}
+
+type failingSource struct {
+	name string
+}
+
+func (this failingSource) Name() string {
+	return this.name
+}
+
+func (this failingSource) Load(ctx context.Context) (config.Config, error) {
+	return config.NewBuilder().Config(), nil
+}
+
+func (this failingSource) Watch(ctx context.Context) error {
+	return errors.New("Connection refused")
+}
+
+func TestLoaderWatchFailure(t *testing.T) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errs := make([]string, 0, 1)
+	err := config.NewLoader(failingSource{"a"}, failingSource{"b"}).Watch(ctx, func(cfg config.Config, report *config.Report, err error) {
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+	})
+
+	if err == nil || ctx.Err() != nil || len(errs) != 1 || !strings.HasSuffix(err.Error(), ": Connection refused") || !strings.HasSuffix(errs[0], ": Connection refused") {
+		t.Errorf("Expected error of failed watchers, got %v %v", err, errs)
+		t.Fail()
+	}
+
+}
+
+func TestLoaderParseError(t *testing.T) {
+
+	file := filepath.Join(t.TempDir(), "app.conf")
+	os.WriteFile(file, []byte("Name {\n"), 0644)
+
+	_, _, err := config.NewLoader(config.FileSource(file)).Load(context.Background())
+	if err == nil || err.Error() != file+": Unexpected end of file on line 2 at column 1" {
+		t.Errorf("Invalid error: %v", err)
+		t.Fail()
+	}
+
+}
+
+func TestLoaderWatchIncludes(t *testing.T) {
+
+	config.WatchInterval = 10 * time.Millisecond
+	defer func() { config.WatchInterval = time.Second }()
+
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "app"), 0755)
+	os.Mkdir(filepath.Join(dir, "shared"), 0755)
+	file := filepath.Join(dir, "app", "app.conf")
+	shared := filepath.Join(dir, "shared", "port.conf")
+	os.WriteFile(file, []byte("@include \"../shared/port.conf\"\n"), 0644)
+	os.WriteFile(shared, []byte("Port 80\n"), 0644)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ports := make([]int64, 0, 2)
+	err := config.NewLoader(config.FileSource(file)).Watch(ctx, func(cfg config.Config, report *config.Report, err error) {
+		if err != nil {
+			t.Errorf("Cannot load config: %s", err.Error())
+			cancel()
+			return
+		}
+		if node, ok := cfg.Query("Port"); !ok || config.PositionOf(node).Source != shared {
+			t.Errorf("Invalid position of included key: %v", config.PositionOf(node))
+			t.Fail()
+		}
+		ports = append(ports, cfg.IntOrDefault("Port", 0))
+		if len(ports) == 1 {
+			os.WriteFile(shared, []byte("Port 8080\n"), 0644)
+		} else {
+			cancel()
+		}
+	})
+
+	if err != context.Canceled || len(ports) != 2 || ports[1] != 8080 {
+		t.Errorf("Invalid reloads: %v %v", err, ports)
+		t.Fail()
+	}
+
+}
+
+func TestLoaderExplain(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"app.conf": &fstest.MapFile{Data: []byte("Port 8080\nUrl \"http://${Host}:${Port}/\"\n")},
+	}
+
+	l := config.NewLoader(
+		config.ConfigSource("defaults", config.NewBuilder().String("Host", "localhost").String("Port", "80").Config()),
+		config.FSSource(fsys, "app.conf"),
+		config.EnvSource("APP_", &config.EnvOptions{Environ: []string{"APP_PORT=9090"}, FoldCase: true}),
+	)
+
+	e, err := l.Explain(context.Background(), "Port")
+	if err != nil {
+		t.Errorf("Cannot explain: %s", err.Error())
+		t.FailNow()
+	}
+
+	expected := "Port = \"9090\"\n" +
+		"  source      defaults: \"80\" (overridden)\n" +
+		"  source      app.conf:1:1: \"8080\" (overridden)\n" +
+		"  source      env:APP_PORT (env:APP_): \"9090\"\n"
+	if !e.Found || e.Value != "9090" || e.String() != expected {
+		t.Errorf("Invalid explanation:\n%s", e)
+		t.Fail()
+	}
+
+	e, _ = l.Explain(context.Background(), "Url")
+	if len(e.Steps) != 4 || e.Steps[1].Kind != config.ExplainReference || e.Steps[2].Position.Source != "env:APP_PORT" || e.Steps[3].Value != "http://localhost:9090/" {
+		t.Errorf("Invalid explanation of interpolation:\n%s", e)
+		t.Fail()
+	}
+
+	if e, _ = l.Explain(context.Background(), "Missing"); e.Found || len(e.Steps) != 0 {
+		t.Errorf("Invalid explanation of missing key:\n%s", e)
+		t.Fail()
+	}
+
+	var src = "Server Defaults {\n" +
+		"    Port 80\n" +
+		"    Host localhost\n" +
+		"}\n" +
+		"Limits {\n" +
+		"    MaxConn 10\n" +
+		"}\n" +
+		"Server Main extends Server:Defaults {\n" +
+		"    @use Limits\n" +
+		"    Host example.com\n" +
+		"}\n"
+
+	l = config.NewLoader(config.BytesSource("app.conf", []byte(src)))
+	for query, expected := range map[string]string{
+		"Server:Main/Port":    "Server:Main/Port = \"80\"\n  inherit     app.conf:2:5: \"80\" from Server:Defaults\n",
+		"Server:Main/MaxConn": "Server:Main/MaxConn = \"10\"\n  inherit     app.conf:6:5: \"10\" from Limits\n",
+		"Server:Main/Host":    "Server:Main/Host = \"example.com\"\n  source      app.conf:10:5: \"example.com\"\n",
+	} {
+		if e, err = l.Explain(context.Background(), query); err != nil || e.String() != expected {
+			t.Errorf("Invalid explanation of inheritance:\n%s", e)
+			t.Fail()
+		}
+	}
+
+}
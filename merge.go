@@ -0,0 +1,123 @@
+package config
+
+import "strings"
+
+// Merge returns configuration data where the overlays are applied in order
+// over the base, so the last overlay wins. The base and the overlays are not
+// modified.
+//
+// Keys of an overlay replace all keys with the same name in the same section
+// of the base, i.e. repeated keys are replaced as a whole. Sections of an
+// overlay are merged into the first section of the base with the same name
+// and value, sections which don't exist in the base are appended. Names and
+// values are compared case-insensitively if one of the nodes comes from a
+// case-folding source like FromEnv, the names of the base are kept then.
+func Merge(base Config, overlays ...Config) Config {
+	root := cloneConfig(base)
+	for _, overlay := range overlays {
+		root.merge(cloneConfig(overlay))
+	}
+	return root
+}
+
+// cloneConfig returns a deep copy of the configuration data, other
+// implementations of Config are converted.
+func cloneConfig(cfg Config) *config {
+	if node, ok := cfg.(*config); ok {
+		clone := new(config)
+		*clone = *node
+		if node.children != nil {
+			clone.children = make([]*config, len(node.children))
+			for key, child := range node.children {
+				clone.children[key] = cloneConfig(child)
+			}
+		}
+		return clone
+	}
+	clone := &config{name: cfg.Name(), value: cfg.Value(), pos: PositionOf(cfg)}
+	if IsSection(cfg) {
+		clone.children = make([]*config, 0, len(Children(cfg)))
+		for _, child := range Children(cfg) {
+			clone.children = append(clone.children, cloneConfig(child))
+		}
+	}
+	return clone
+}
+
+// sameName reports whether the nodes have the same name.
+func (this *config) sameName(other *config) bool {
+	return this.name == other.name || ((this.fold || other.fold) && strings.EqualFold(this.name, other.name))
+}
+
+// sameValue reports whether the nodes have the same value.
+func (this *config) sameValue(other *config) bool {
+	return this.value == other.value || ((this.fold || other.fold) && strings.EqualFold(this.value, other.value))
+}
+
+// merge applies the overlay over this section, nodes of the overlay are
+// moved into this section.
+func (this *config) merge(overlay *config) {
+	if overlay.extends != "" {
+		this.extends = overlay.extends
+	}
+	replaced := make(map[*config]bool)
+	for _, child := range overlay.children {
+		if child.children != nil {
+			if section := this.findSection(child); section != nil {
+				section.merge(child)
+			} else {
+				this.children = append(this.children, child)
+			}
+			continue
+		}
+		if replaced[child] {
+			continue
+		}
+		this.replaceKeys(overlay, child, replaced)
+	}
+}
+
+func (this *config) findSection(other *config) *config {
+	for _, child := range this.children {
+		if child.children != nil && child.sameName(other) && child.sameValue(other) {
+			return child
+		}
+	}
+	return nil
+}
+
+// replaceKeys replaces keys of this section with the same name as the key of
+// the overlay by all such keys of the overlay. The new keys take the place
+// of the first replaced key and keep its name and comments.
+func (this *config) replaceKeys(overlay *config, key *config, replaced map[*config]bool) {
+	group := make([]*config, 0, 1)
+	for _, child := range overlay.children {
+		if child.children == nil && child.sameName(key) {
+			group = append(group, child)
+			replaced[child] = true
+		}
+	}
+	children := make([]*config, 0, len(this.children)+len(group))
+	inserted := false
+	for _, child := range this.children {
+		if child.children != nil || !child.sameName(key) {
+			children = append(children, child)
+			continue
+		}
+		if !inserted {
+			for index, node := range group {
+				node.name = child.name
+				node.fold = child.fold
+				if index == 0 {
+					node.lead, node.comment = child.lead, child.comment
+				}
+				children = append(children, node)
+			}
+			inserted = true
+		}
+	}
+	if !inserted {
+		children = append(children, group...)
+	}
+	this.children = children
+}
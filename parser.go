@@ -1,43 +1,42 @@
 package config
 
-import "bufio"
 import "bytes"
 import "errors"
 import "fmt"
 import "io"
 import "os"
+import "strings"
 
 // ParseFromBytes parses and returns a hierarchical configuration data from
 // the specified slice of bytes.
 func ParseFromBytes(data []byte) (cfg Config, err error) {
-	return ParseFromReader(bytes.NewReader(data))
+	return DefaultLimits.ParseBytes(data)
 }
 
 // ParseFromByteReader parses and returns a hierarchical configuration data
 // from the specified byte reader.
 func ParseFromByteReader(reader io.ByteReader) (cfg Config, err error) {
-	p := newParser(reader)
-	if err := p.parse(); err != nil {
-		return nil, err
+	if r, ok := reader.(io.Reader); ok {
+		return ParseFromReader(r)
 	}
-	return p.config(), nil
+	return ParseFromReader(byteReader{reader})
 }
 
 // ParseFromFile parses and returns a hierarchical configuration data from
-// the specified file.
+// the specified file. Positions of nodes and parse errors refer to the file.
 func ParseFromFile(file string) (cfg Config, err error) {
 	r, err := os.Open(file)
 	if err != nil {
 		return nil, err
 	}
 	defer r.Close()
-	return ParseFromReader(r)
+	return withSource(file)(ParseFromReader(r))
 }
 
 // ParseFromReader parses and returns a hierarchical configuration data from
 // the specified reader.
 func ParseFromReader(reader io.Reader) (cfg Config, err error) {
-	return ParseFromByteReader(bufio.NewReader(reader))
+	return DefaultLimits.Parse(reader)
 }
 
 // ParseFromString parses and returns a hierarchical configuration data from
@@ -46,15 +45,89 @@ func ParseFromString(str string) (cfg Config, err error) {
 	return ParseFromBytes([]byte(str))
 }
 
+// IsValidName reports whether the name can be parsed as a name of a key or a
+// section: it starts with a letter, '_' or '@' (directives like @include)
+// followed by letters, digits and '_'.
+func IsValidName(name string) bool {
+	if name == "" {
+		return false
+	}
+	b := name[0]
+	if !((b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || b == '_' || b == '@') {
+		return false
+	}
+	for i := 1; i < len(name); i++ {
+		if byteClasses[name[i]]&className == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseError describes a problem found in configuration data, the position
+// refers to the line and column where the problem was detected. Source is
+// the name of the file if known.
+type ParseError struct {
+	Source string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (this *ParseError) Error() string {
+	if this.Source != "" {
+		return fmt.Sprintf("%s: %s on line %d at column %d", this.Source, this.Msg, this.Line, this.Column)
+	}
+	return fmt.Sprintf("%s on line %d at column %d", this.Msg, this.Line, this.Column)
+}
+
+// withSource returns a function which sets the source of positions of the
+// parsed nodes or of the parse error, other errors are prefixed with it.
+func withSource(source string) func(cfg Config, err error) (Config, error) {
+	return func(cfg Config, err error) (Config, error) {
+		if perr, ok := err.(*ParseError); ok && perr.Source == "" {
+			perr.Source = source
+		} else if err != nil && !ok {
+			err = errors.New(fmt.Sprintf("%s: %s", source, err.Error()))
+		}
+		if err != nil {
+			return nil, err
+		}
+		cfg.(*config).setSource(source, "")
+		return cfg, nil
+	}
+}
+
 type parser struct {
-	reader   io.ByteReader
-	bufName  []byte
-	bufValue []byte
-	state    parserState
-	builder  Builder
-	curLine  uint32
-	curCol   uint32
-	lastByte byte
+	reader     io.Reader
+	chunk      []byte
+	data       []byte
+	index      int
+	names      map[string]string
+	bufName    []byte
+	bufValue   []byte
+	bufComment []byte
+	bufExtends []byte
+	state      parserState
+	limits     Limits
+	nodes      int
+	builder    *builder
+	tokens     []token
+	head       int
+	curLine    uint32
+	curCol     uint32
+	curOffset  int
+	nameOffset int
+	namePos    Position
+	commentPos Position
+	endOffset  int
+	depth      int
+	blank      bool
+	escaped    bool
+	inline     bool
+	done       bool
+	err        error
+	discard    bool
 }
 
 type parserState uint16
@@ -67,18 +140,96 @@ const (
 	parserValueEnd
 	parserValueEscaped
 	parserValueStart
+	parserExtendsKeyword
+	parserExtendsStart
+	parserExtends
+	parserExtendsEscaped
+	parserExtendsEnd
 )
 
-func newParser(reader io.ByteReader) *parser {
-	p := new(parser)
+// extendsKeyword follows the name and the value of a section which inherits
+// from another section, e.g. "Server Two extends Server:Defaults {".
+const extendsKeyword = "extends"
+
+const (
+	// parserChunkSize is the size of chunks read from readers.
+	parserChunkSize = 64 << 10
+	// parserMaxNames limits the number of interned names, so generated names
+	// of a huge input don't stay in memory.
+	parserMaxNames = 4096
+)
+
+// Classes of bytes, a byte may belong to several classes.
+const (
+	className uint8 = 1 << iota
+	classValue
+	classQuery
+	// classString are bytes copied as they are from quoted strings.
+	classString
+)
+
+var byteClasses = newByteClasses()
+
+func newByteClasses() (classes [256]uint8) {
+	for b := 0; b < 256; b++ {
+		if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_' {
+			classes[b] |= className | classValue | classQuery
+		}
+		if b == '+' || b == '-' || b == '.' {
+			classes[b] |= classValue | classQuery
+		}
+		if b == ':' || b == '/' || b == '*' || b == '@' {
+			classes[b] |= classQuery
+		}
+		if b != '"' && b != '\\' && b != '\n' {
+			classes[b] |= classString
+		}
+	}
+	return classes
+}
+
+// byteReader reads an io.ByteReader which is not an io.Reader.
+type byteReader struct {
+	io.ByteReader
+}
+
+func (this byteReader) Read(buf []byte) (int, error) {
+	for n := range buf {
+		b, err := this.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		buf[n] = b
+	}
+	return len(buf), nil
+}
+
+// newParser returns a parser which reads the input from the reader in
+// chunks.
+func newParser(reader io.Reader) *parser {
+	p := newBytesParser(nil)
 	p.reader = reader
+	return p
+}
+
+// newBytesParser returns a parser of the input in memory, it is not copied.
+// The parser checks DefaultLimits.
+func newBytesParser(data []byte) *parser {
+	p := new(parser)
+	p.data = data
+	p.names = make(map[string]string)
 	p.bufName = make([]byte, 0, 128)
 	p.bufValue = make([]byte, 0, 128)
+	p.bufComment = make([]byte, 0, 128)
+	p.bufExtends = make([]byte, 0, 64)
 	p.state = parserBegin
-	p.builder = NewBuilder()
+	p.limits = DefaultLimits
+	p.builder = newBuilder()
+	p.tokens = make([]token, 0, 4)
 	p.curLine = 1
 	p.curCol = 1
-	p.lastByte = 0
+	p.depth = 0
+	p.blank = true
 	return p
 }
 
@@ -86,127 +237,510 @@ func (this *parser) config() Config {
 	return this.builder.Config()
 }
 
+// parse parses the whole input into the builder.
 func (this *parser) parse() error {
-
 	for {
+		tok, err := this.next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		this.builder.token(&tok)
+	}
+}
 
-		b, err := this.reader.ReadByte()
-		if err != nil {
-			if err == io.EOF {
-				if this.state != parserBegin {
-					return err
-				}
-				return nil
-			} else {
+// next returns the next token or io.EOF at the end of the input.
+func (this *parser) next() (token, error) {
+	for len(this.tokens) == 0 {
+		if this.err != nil {
+			return token{}, this.err
+		}
+		if this.done {
+			return token{}, io.EOF
+		}
+		this.err = this.scan()
+	}
+	tok := this.tokens[this.head]
+	this.head = this.head + 1
+	if this.head == len(this.tokens) {
+		this.tokens = this.tokens[:0]
+		this.head = 0
+	}
+	return tok, nil
+}
+
+// fill reads the next chunk of the input, it returns io.EOF at the end.
+func (this *parser) fill() error {
+	if this.reader == nil {
+		return io.EOF
+	}
+	if this.chunk == nil {
+		this.chunk = make([]byte, parserChunkSize)
+	}
+	for retry := 0; retry < 100; retry++ {
+		n, err := this.reader.Read(this.chunk)
+		if n > 0 {
+			this.data = this.chunk[:n]
+			this.index = 0
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+	return io.ErrNoProgress
+}
+
+// span returns the number of bytes of the class from the current byte.
+func (this *parser) span(class uint8) int {
+	end := this.index
+	for end < len(this.data) && byteClasses[this.data[end]]&class != 0 {
+		end++
+	}
+	return end - this.index
+}
+
+// advance skips n bytes of the current line which are not all blank.
+func (this *parser) advance(n int) {
+	this.index = this.index + n
+	this.curOffset = this.curOffset + n
+	this.curCol = this.curCol + uint32(n)
+	this.blank = false
+}
+
+// intern returns the name as a string, repeated names share one string.
+func (this *parser) intern(name []byte) string {
+	if str, ok := this.names[string(name)]; ok {
+		return str
+	}
+	str := string(name)
+	if len(this.names) < parserMaxNames {
+		this.names[str] = str
+	}
+	return str
+}
+
+func (this *parser) error(msg string) error {
+	return &ParseError{Line: int(this.curLine), Column: int(this.curCol), Msg: msg}
+}
+
+func (this *parser) isNameByte(b byte) bool {
+	return byteClasses[b]&className != 0
+}
+
+func (this *parser) isValueByte(b byte) bool {
+	return byteClasses[b]&this.valueClass() != 0
+}
+
+// valueClass returns the class of bytes of unquoted values, values of @use
+// directives are queries, e.g. `@use Server:Main`.
+func (this *parser) valueClass() uint8 {
+	if string(this.bufName) == UseDirective {
+		return classQuery
+	}
+	return classValue
+}
+
+func (this *parser) isQueryByte(b byte) bool {
+	return byteClasses[b]&classQuery != 0
+}
+
+// emitComment emits a comment token, tokens are not built while discarding.
+func (this *parser) emitComment() {
+	if !this.discard {
+		raw := strings.TrimRight(string(this.bufComment), " \t\r")
+		this.tokens = append(this.tokens, token{kind: tokenComment, value: raw, inline: this.inline, pos: this.commentPos})
+	}
+	this.bufComment = this.bufComment[:0]
+	this.inline = false
+}
+
+func (this *parser) emitValue() error {
+	if err := this.count(); err != nil {
+		return err
+	}
+	if !this.discard {
+		this.tokens = append(this.tokens, token{
+			kind:  tokenKeyValue,
+			name:  this.intern(this.bufName),
+			value: string(this.bufValue),
+			pos:   this.namePos,
+			start: this.nameOffset,
+			end:   this.endOffset,
+		})
+	}
+	this.bufName = this.bufName[:0]
+	this.bufValue = this.bufValue[:0]
+	return nil
+}
+
+func (this *parser) emitSection() error {
+	if err := this.count(); err != nil {
+		return err
+	}
+	if this.limits.MaxDepth > 0 && this.depth >= this.limits.MaxDepth {
+		return this.error("Nesting too deep")
+	}
+	if this.discard {
+		this.tokens = append(this.tokens, token{kind: tokenSectionStart})
+	} else {
+		this.tokens = append(this.tokens, token{
+			kind:    tokenSectionStart,
+			name:    this.intern(this.bufName),
+			value:   string(this.bufValue),
+			extends: string(this.bufExtends),
+			pos:     this.namePos,
+			start:   this.nameOffset,
+		})
+	}
+	this.bufName = this.bufName[:0]
+	this.bufValue = this.bufValue[:0]
+	this.bufExtends = this.bufExtends[:0]
+	this.depth = this.depth + 1
+	this.state = parserBegin
+	return nil
+}
+
+// count counts a new key or section.
+func (this *parser) count() error {
+	this.nodes = this.nodes + 1
+	if this.limits.MaxNodes > 0 && this.nodes > this.limits.MaxNodes {
+		return this.error("Too many nodes")
+	}
+	return nil
+}
+
+// appendName appends bytes to the name and checks its length.
+func (this *parser) appendName(b ...byte) error {
+	this.bufName = append(this.bufName, b...)
+	if this.limits.MaxKeyLength > 0 && len(this.bufName) > this.limits.MaxKeyLength {
+		return this.error("Name too long")
+	}
+	return nil
+}
+
+// appendText appends bytes to the value, the extended query or the comment
+// and checks their length.
+func (this *parser) appendText(buf *[]byte, b ...byte) error {
+	*buf = append(*buf, b...)
+	if this.limits.MaxValueLength > 0 && len(*buf) > this.limits.MaxValueLength {
+		return this.error("Value too long")
+	}
+	return nil
+}
+
+// pos returns the current position.
+func (this *parser) pos() Position {
+	return Position{Line: int(this.curLine), Column: int(this.curCol)}
+}
+
+func (this *parser) startComment(b byte) {
+	this.bufComment = append(this.bufComment, b)
+	this.commentPos = this.pos()
+	this.inline = !this.blank
+	this.state = parserComment
+}
+
+// end finishes the input, it emits the pending key or comment.
+func (this *parser) end() error {
+	switch this.state {
+	case parserComment:
+		this.emitComment()
+	case parserName, parserValue, parserValueEnd, parserValueStart:
+		if err := this.emitValue(); err != nil {
+			return err
+		}
+	case parserValueEscaped, parserExtendsEscaped:
+		return this.error("Unterminated string")
+	case parserExtendsKeyword, parserExtendsStart, parserExtends, parserExtendsEnd:
+		return this.error("Unexpected end of file")
+	}
+	this.state = parserBegin
+	if this.depth > 0 {
+		return this.error("Unexpected end of file")
+	}
+	this.done = true
+	return nil
+}
+
+// scan reads the input until it emits at least one token or it ends. Runs
+// of bytes of names, values and comments are copied at once from the
+// current chunk, other bytes drive the state machine one by one.
+func (this *parser) scan() error {
+
+	for len(this.tokens) == 0 {
+
+		if this.index == len(this.data) {
+			if err := this.fill(); err == io.EOF {
+				return this.end()
+			} else if err != nil {
 				return err
 			}
 		}
+		b := this.data[this.index]
 
 		switch this.state {
 		case parserBegin:
 			if b == '#' {
-				this.state = parserComment
+				this.startComment(b)
 			} else if b == '}' {
-				this.builder.CloseSection()
-				return nil
-			} else if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || b == '_' {
-				this.bufName = append(this.bufName, b)
+				if this.depth == 0 {
+					return this.error("Wrong character")
+				}
+				if this.discard {
+					this.tokens = append(this.tokens, token{kind: tokenSectionEnd})
+				} else {
+					this.tokens = append(this.tokens, token{kind: tokenSectionEnd, pos: this.pos(), end: this.curOffset + 1})
+				}
+				this.depth = this.depth - 1
+			} else if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || b == '_' || b == '@' {
+				// Names starting with '@' are directives, e.g. @include.
+				if err := this.appendName(b); err != nil {
+					return err
+				}
+				this.nameOffset = this.curOffset
+				this.namePos = this.pos()
+				this.endOffset = this.curOffset + 1
 				this.state = parserName
+			} else if b == '\n' && this.blank {
+				if !this.discard {
+					this.tokens = append(this.tokens, token{kind: tokenBlankLine, pos: this.pos()})
+				}
 			} else if b != ' ' && b != '\t' && b != '\r' && b != '\n' {
-				return errors.New(fmt.Sprintf("Wrong character on line %d at column %d", this.curLine, this.curCol))
+				return this.error("Wrong character")
 			}
 		case parserComment:
 			if b == '\n' {
+				this.emitComment()
 				this.state = parserBegin
+			} else {
+				n := bytes.IndexByte(this.data[this.index:], '\n')
+				if n < 0 {
+					n = len(this.data) - this.index
+				}
+				if err := this.appendText(&this.bufComment, this.data[this.index:this.index+n]...); err != nil {
+					return err
+				}
+				this.advance(n)
+				continue
 			}
 		case parserName:
-			if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_' {
-				this.bufName = append(this.bufName, b)
+			if this.isNameByte(b) {
+				n := this.span(className)
+				if err := this.appendName(this.data[this.index : this.index+n]...); err != nil {
+					return err
+				}
+				this.endOffset = this.curOffset + n
+				this.advance(n)
+				continue
 			} else if b == ' ' || b == '\t' {
 				this.state = parserValueStart
+			} else if b == '\r' || b == '\n' {
+				if err := this.emitValue(); err != nil {
+					return err
+				}
+				this.state = parserBegin
+			} else if b == '#' {
+				if err := this.emitValue(); err != nil {
+					return err
+				}
+				this.startComment(b)
+			} else if b == '{' {
+				if err := this.emitSection(); err != nil {
+					return err
+				}
 			} else {
-				// TODO: Throw an error
-				panic("Wrong configuration")
+				return this.error("Wrong character")
 			}
 		case parserValueStart:
-			if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_' || b == '+' || b == '-' || b == '.' {
-				this.bufValue = append(this.bufValue, b)
+			if this.isValueByte(b) {
 				this.state = parserValue
+				continue
 			} else if b == '"' {
 				this.state = parserValueEscaped
 			} else if b == '\r' || b == '\n' {
-				this.builder.String(string(this.bufName), "")
-				this.bufName = this.bufName[:0]
+				if err := this.emitValue(); err != nil {
+					return err
+				}
 				this.state = parserBegin
 			} else if b == '#' {
-				this.builder.String(string(this.bufName), "")
-				this.bufName = this.bufName[:0]
-				this.state = parserComment
+				if err := this.emitValue(); err != nil {
+					return err
+				}
+				this.startComment(b)
 			} else if b == '{' {
-				this.builder.Section(string(this.bufName), "")
-				if err = this.parse(); err != nil {
+				if err := this.emitSection(); err != nil {
 					return err
 				}
-				this.state = parserBegin
 			} else if b != ' ' && b != '\t' {
-				// TODO: Throw an error
-				panic("Wrong configuration")
+				return this.error("Wrong character")
 			}
 		case parserValue:
-			if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_' || b == '+' || b == '-' || b == '.' {
-				this.bufValue = append(this.bufValue, b)
-				this.state = parserValue
+			if this.isValueByte(b) {
+				n := this.span(this.valueClass())
+				if err := this.appendText(&this.bufValue, this.data[this.index:this.index+n]...); err != nil {
+					return err
+				}
+				this.endOffset = this.curOffset + n
+				this.advance(n)
+				continue
 			} else if b == ' ' || b == '\t' {
 				this.state = parserValueEnd
 			} else if b == '\r' || b == '\n' {
-				this.builder.String(string(this.bufName), string(this.bufValue))
-				this.bufName = this.bufName[:0]
-				this.bufValue = this.bufValue[:0]
+				if err := this.emitValue(); err != nil {
+					return err
+				}
 				this.state = parserBegin
+			} else if b == '#' {
+				if err := this.emitValue(); err != nil {
+					return err
+				}
+				this.startComment(b)
+			} else if b == '{' {
+				if err := this.emitSection(); err != nil {
+					return err
+				}
 			} else {
-				// TODO: Throw an error
-				panic("Wrong configuration")
+				return this.error("Wrong character")
 			}
 		case parserValueEscaped:
-			if b == '"' && this.lastByte != '\\' {
+			if this.escaped {
+				if err := this.appendText(&this.bufValue, b); err != nil {
+					return err
+				}
+				this.escaped = false
+			} else if b == '\\' {
+				this.escaped = true
+			} else if b == '"' {
+				this.endOffset = this.curOffset + 1
 				this.state = parserValueEnd
-			} else if (b == '\\' && this.lastByte == '\\') || (b != '\\') {
-				this.bufValue = append(this.bufValue, b)
-				//fmt.Println(string(this.bufValue))
+			} else if byteClasses[b]&classString != 0 {
+				n := this.span(classString)
+				if err := this.appendText(&this.bufValue, this.data[this.index:this.index+n]...); err != nil {
+					return err
+				}
+				this.advance(n)
+				continue
+			} else {
+				if err := this.appendText(&this.bufValue, b); err != nil {
+					return err
+				}
 			}
-			this.lastByte = b
 		case parserValueEnd:
 			if b == '\r' || b == '\n' {
-				this.builder.String(string(this.bufName), string(this.bufValue))
-				this.bufName = this.bufName[:0]
-				this.bufValue = this.bufValue[:0]
+				if err := this.emitValue(); err != nil {
+					return err
+				}
 				this.state = parserBegin
 			} else if b == '#' {
-				this.builder.String(string(this.bufName), string(this.bufValue))
-				this.bufName = this.bufName[:0]
-				this.bufValue = this.bufValue[:0]
-				this.state = parserBegin
+				if err := this.emitValue(); err != nil {
+					return err
+				}
+				this.startComment(b)
 			} else if b == '{' {
-				this.builder.Section(string(this.bufName), string(this.bufValue))
-				this.bufName = this.bufName[:0]
+				if err := this.emitSection(); err != nil {
+					return err
+				}
+			} else if this.isQueryByte(b) && string(this.bufValue) == extendsKeyword {
+				// A section without a value, "Name extends Query {".
+				this.bufValue = this.bufValue[:0]
+				this.state = parserExtends
+				continue
+			} else if b == '"' && string(this.bufValue) == extendsKeyword {
 				this.bufValue = this.bufValue[:0]
-				if err = this.parse(); err != nil {
+				this.state = parserExtendsEscaped
+			} else if b == extendsKeyword[0] {
+				if err := this.appendText(&this.bufExtends, b); err != nil {
 					return err
 				}
-				this.state = parserBegin
+				this.state = parserExtendsKeyword
 			} else if b != ' ' && b != '\t' {
-				// TODO: Throw an error
-				panic("Wrong configuration")
+				return this.error("Wrong character")
+			}
+		case parserExtendsKeyword:
+			if n := len(this.bufExtends); n < len(extendsKeyword) && b == extendsKeyword[n] {
+				if err := this.appendText(&this.bufExtends, b); err != nil {
+					return err
+				}
+			} else if (b == ' ' || b == '\t') && string(this.bufExtends) == extendsKeyword {
+				this.bufExtends = this.bufExtends[:0]
+				this.state = parserExtendsStart
+			} else {
+				return this.error("Wrong character")
+			}
+		case parserExtendsStart:
+			if this.isQueryByte(b) {
+				this.state = parserExtends
+				continue
+			} else if b == '"' {
+				this.state = parserExtendsEscaped
+			} else if b != ' ' && b != '\t' {
+				return this.error("Wrong character")
+			}
+		case parserExtends:
+			if this.isQueryByte(b) {
+				n := this.span(classQuery)
+				if err := this.appendText(&this.bufExtends, this.data[this.index:this.index+n]...); err != nil {
+					return err
+				}
+				this.advance(n)
+				continue
+			} else if b == ' ' || b == '\t' {
+				this.state = parserExtendsEnd
+			} else if b == '{' {
+				if err := this.emitSection(); err != nil {
+					return err
+				}
+			} else {
+				return this.error("Wrong character")
+			}
+		case parserExtendsEscaped:
+			if this.escaped {
+				if err := this.appendText(&this.bufExtends, b); err != nil {
+					return err
+				}
+				this.escaped = false
+			} else if b == '\\' {
+				this.escaped = true
+			} else if b == '"' {
+				this.state = parserExtendsEnd
+			} else if byteClasses[b]&classString != 0 {
+				n := this.span(classString)
+				if err := this.appendText(&this.bufExtends, this.data[this.index:this.index+n]...); err != nil {
+					return err
+				}
+				this.advance(n)
+				continue
+			} else {
+				if err := this.appendText(&this.bufExtends, b); err != nil {
+					return err
+				}
+			}
+		case parserExtendsEnd:
+			if b == '{' {
+				if err := this.emitSection(); err != nil {
+					return err
+				}
+			} else if b != ' ' && b != '\t' {
+				return this.error("Wrong character")
 			}
 		}
 
+		this.index = this.index + 1
+		this.curOffset = this.curOffset + 1
 		if b == '\n' {
 			this.curLine = this.curLine + 1
 			this.curCol = 1
+			this.blank = true
 		} else {
 			this.curCol = this.curCol + 1
+			if b != ' ' && b != '\t' && b != '\r' {
+				this.blank = false
+			}
 		}
 
 	}
 
+	return nil
+
 }
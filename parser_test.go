@@ -1,18 +1,22 @@
 package config_test
 
+import "bytes"
+import "fmt"
 import "github.com/twoleds-golang/config"
+import "strings"
 import "testing"
+import "testing/iotest"
 
-func TestParser(t *testing.T) {
-
-	var str = `
+var parserSrc = `
 		BoolValue T
 		FloatValue 3.14
 		IntValue 123
 		StringValue "Test String 123"
 	`
 
-	cfg, err := config.ParseFromString(str)
+func TestParser(t *testing.T) {
+
+	cfg, err := config.ParseFromString(parserSrc)
 	if err != nil {
 		t.Errorf("Cannot parse config: %s", err.Error())
 		t.FailNow()
@@ -40,3 +44,184 @@ func TestParser(t *testing.T) {
 	}
 
 }
+
+func TestParserComments(t *testing.T) {
+
+	cfg, err := config.ParseFromString("Port 8080 # default\nHost local#host\nName \"a # b\"\nSection {\n} # end\nLast value")
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	var expected = map[string]string{"Port": "8080", "Host": "local", "Name": "a # b", "Last": "value"}
+	for query, value := range expected {
+		if val, ok := cfg.String(query); !ok || val != value {
+			t.Errorf("Invalid value for query '%s': %q", query, val)
+			t.Fail()
+		}
+	}
+
+	if val, ok := cfg.Query("Port"); !ok || len(config.Comments(val)) != 1 || config.Comments(val)[0] != "default" {
+		t.Error("Invalid comments for query 'Port'")
+		t.Fail()
+	}
+
+}
+
+func TestParserErrors(t *testing.T) {
+
+	var tests = map[string]string{
+		"Name {\n":           "Unexpected end of file on line 2 at column 1",
+		"}\n":                "Wrong character on line 1 at column 1",
+		"Name \"value\n":     "Unterminated string on line 2 at column 1",
+		"Name value value\n": "Wrong character on line 1 at column 12",
+	}
+
+	for str, msg := range tests {
+		if _, err := config.ParseFromString(str); err == nil || err.Error() != msg {
+			t.Errorf("Invalid error for %q: %v", str, err)
+			t.Fail()
+		}
+	}
+
+}
+
+func TestParserDeeplyNested(t *testing.T) {
+
+	src := benchNested(100000)
+	if _, err := config.ParseFromBytes(src); err == nil || err.Error() != "Nesting too deep on line 10001 at column 9" {
+		t.Errorf("Expected error for default limits, got %v", err)
+		t.Fail()
+	}
+
+	cfg, err := config.Limits{}.ParseBytes(src)
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	depth := 0
+	for config.IsSection(cfg) && len(config.Children(cfg)) > 0 {
+		cfg = config.Children(cfg)[0]
+		depth++
+	}
+	if depth != 100001 || cfg.Name() != "Key" {
+		t.Errorf("Invalid depth: %d", depth)
+		t.Fail()
+	}
+
+}
+
+// byteReader is an io.ByteReader which is not an io.Reader.
+type byteReader struct {
+	r *bytes.Reader
+}
+
+func (this byteReader) ReadByte() (byte, error) {
+	return this.r.ReadByte()
+}
+
+func TestParserChunks(t *testing.T) {
+
+	src := append(benchSmall, "Section extends \"Server:Main\" {\n    Key \"a\\\"b\\nc\"\n}\n"...)
+	expected, err := config.ParseFromBytes(src)
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	cfg, err := config.ParseFromReader(iotest.OneByteReader(bytes.NewReader(src)))
+	if err != nil {
+		t.Errorf("Cannot parse config by bytes: %s", err.Error())
+		t.FailNow()
+	}
+
+	if config.Dump(cfg) != config.Dump(expected) {
+		t.Errorf("Invalid config parsed by bytes:\n%s", config.Dump(cfg))
+		t.Fail()
+	}
+	if cfg, err := config.ParseFromByteReader(byteReader{bytes.NewReader(src)}); err != nil || config.Dump(cfg) != config.Dump(expected) {
+		t.Errorf("Invalid config parsed from byte reader: %v", err)
+		t.Fail()
+	}
+	for _, query := range []string{"Server:Backup/Port", "Section/Key"} {
+		a, _ := cfg.Query(query)
+		b, _ := expected.Query(query)
+		if config.PositionOf(a) != config.PositionOf(b) || a.Value() != b.Value() {
+			t.Errorf("Invalid node for query '%s': %s %q", query, config.PositionOf(a), a.Value())
+			t.Fail()
+		}
+	}
+
+}
+
+var benchSmall = []byte(`# Example
+Name main
+Debug true
+
+Server Main {
+    Host "example.org"
+    Port 8080
+    Timeout 2.5
+}
+
+Server Backup {
+    Host "backup.example.org"
+    Port 8081
+}
+`)
+
+// benchRoutes returns a generated configuration of about the size.
+func benchRoutes(size int) []byte {
+	buf := new(bytes.Buffer)
+	for index := 0; buf.Len() < size; index++ {
+		fmt.Fprintf(buf, "Route r%d {\n", index)
+		fmt.Fprintf(buf, "    Path \"/api/v1/resource%d/*\"\n", index)
+		fmt.Fprintf(buf, "    Method GET # read only\n")
+		fmt.Fprintf(buf, "    Backend backend%d.internal\n", index%16)
+		fmt.Fprintf(buf, "    Timeout 30\n")
+		fmt.Fprintf(buf, "}\n\n")
+	}
+	return buf.Bytes()
+}
+
+// benchNested returns sections nested to the depth with a key inside.
+func benchNested(depth int) []byte {
+	return []byte(strings.Repeat("Section {\n", depth) + "Key 1\n" + strings.Repeat("}\n", depth))
+}
+
+func benchmarkParser(b *testing.B, src []byte) {
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := config.ParseFromBytes(src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParserSmall(b *testing.B) {
+	benchmarkParser(b, benchSmall)
+}
+
+func BenchmarkParser10MB(b *testing.B) {
+	benchmarkParser(b, benchRoutes(10<<20))
+}
+
+func BenchmarkParserNested(b *testing.B) {
+	benchmarkParser(b, benchNested(10000))
+}
+
+func BenchmarkDecoder10MB(b *testing.B) {
+	src := benchRoutes(10 << 20)
+	b.SetBytes(int64(len(src)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d := config.NewDecoder(bytes.NewReader(src))
+		for {
+			if _, err := d.Next(); err != nil {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package config
+
+import "errors"
+import "fmt"
+import "strings"
+
+// Directives of conditional blocks, see ResolveProfiles.
+const (
+	ProfileDirective = "@profile"
+	IfDirective      = "@if"
+)
+
+// Profiles holds active profiles and tags which activate conditional blocks.
+type Profiles struct {
+	// Active are names of active profiles, e.g. "production".
+	Active []string
+	// Tags are values for conditions of @if blocks, e.g. "env": "prod".
+	Tags map[string]string
+}
+
+// IsBlock reports whether the node is a conditional block, i.e. a section
+// named ProfileDirective or IfDirective.
+func IsBlock(cfg Config) bool {
+	return IsSection(cfg) && (cfg.Name() == ProfileDirective || cfg.Name() == IfDirective)
+}
+
+// ResolveProfiles returns configuration data where conditional blocks are
+// resolved. The parser keeps the blocks as sections, so inactive blocks are
+// checked for syntax errors and can be validated by a schema too.
+//
+// A block "@profile production { ... }" is active if the profile production
+// is active. A quoted value may list several profiles separated by ',', e.g.
+// "staging,production", any of them activates the block, and a profile
+// prefixed by '!' activates the block if it is not active. A block
+// `@if "env == prod" { ... }` is active if the condition holds for the tags.
+// Conditions compare tags with "==" and "!=", a bare tag holds if it is set,
+// not empty and not "false", "!" negates it, and conditions are combined
+// with "&&" and "||".
+//
+// Contents of active blocks are merged over the section which contains the
+// block like by Merge, so their keys replace keys of the section wherever
+// they are written. Inactive blocks are removed. Blocks may be nested.
+func ResolveProfiles(cfg Config, p *Profiles) (Config, error) {
+	if p == nil {
+		p = new(Profiles)
+	}
+	root := cloneConfig(cfg)
+	if err := root.resolveBlocks(p); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// resolveBlocks resolves conditional blocks of this section and its
+// subsections.
+func (this *config) resolveBlocks(p *Profiles) error {
+	active := make([]*config, 0)
+	children := make([]*config, 0, len(this.children))
+	for _, child := range this.children {
+		if child.name != ProfileDirective && child.name != IfDirective {
+			if child.children != nil {
+				if err := child.resolveBlocks(p); err != nil {
+					return err
+				}
+			}
+			children = append(children, child)
+			continue
+		}
+		if child.children == nil {
+			return errors.New(child.locate(fmt.Sprintf("%s must be a block", child.name)))
+		}
+		ok, err := p.match(child)
+		if err != nil {
+			return errors.New(child.locate(err.Error()))
+		}
+		if !ok {
+			continue
+		}
+		if err := child.resolveBlocks(p); err != nil {
+			return err
+		}
+		active = append(active, child)
+	}
+	this.children = children
+	for _, block := range active {
+		this.merge(block)
+	}
+	return nil
+}
+
+// match reports whether the conditional block is active.
+func (this *Profiles) match(block *config) (bool, error) {
+	if block.name == IfDirective {
+		return this.eval(block.value)
+	}
+	if strings.TrimSpace(block.value) == "" {
+		return false, errors.New("Missing profile")
+	}
+	for _, name := range strings.Split(block.value, ",") {
+		name = strings.TrimSpace(name)
+		negate := strings.HasPrefix(name, "!")
+		if this.isActive(strings.TrimPrefix(name, "!")) != negate {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (this *Profiles) isActive(name string) bool {
+	for _, active := range this.Active {
+		if active == name {
+			return true
+		}
+	}
+	return false
+}
+
+// eval evaluates the condition of an @if block.
+func (this *Profiles) eval(cond string) (bool, error) {
+	if strings.TrimSpace(cond) == "" {
+		return false, errors.New("Missing condition")
+	}
+	for _, alternative := range strings.Split(cond, "||") {
+		all := true
+		for _, term := range strings.Split(alternative, "&&") {
+			ok, err := this.evalTerm(strings.TrimSpace(term))
+			if err != nil {
+				return false, err
+			}
+			all = all && ok
+		}
+		if all {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (this *Profiles) evalTerm(term string) (bool, error) {
+	for _, op := range []string{"==", "!="} {
+		if index := strings.Index(term, op); index >= 0 {
+			tag := strings.TrimSpace(term[:index])
+			val := strings.Trim(strings.TrimSpace(term[index+2:]), "'\"")
+			if tag == "" {
+				return false, errors.New(fmt.Sprintf("Invalid condition '%s'", term))
+			}
+			return (this.Tags[tag] == val) == (op == "=="), nil
+		}
+	}
+	negate := strings.HasPrefix(term, "!")
+	tag := strings.TrimSpace(strings.TrimPrefix(term, "!"))
+	if tag == "" || strings.ContainsAny(tag, " \t=!<>") {
+		return false, errors.New(fmt.Sprintf("Invalid condition '%s'", term))
+	}
+	val := this.Tags[tag]
+	return (val != "" && val != "false") != negate, nil
+}
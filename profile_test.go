@@ -0,0 +1,84 @@
+package config_test
+
+import "context"
+import "github.com/twoleds-golang/config"
+import "strings"
+import "testing"
+
+var profileSrc = "Port 8080\n" +
+	"Debug true\n" +
+	"@profile production {\n" +
+	"    Port 80\n" +
+	"    Debug false\n" +
+	"    @if \"region == eu\" {\n" +
+	"        Host eu.example.org\n" +
+	"    }\n" +
+	"}\n" +
+	"@profile \"!production\" {\n" +
+	"    Host localhost\n" +
+	"}\n" +
+	"Server Main {\n" +
+	"    @if \"env != dev && tls\" {\n" +
+	"        Tls true\n" +
+	"    }\n" +
+	"}\n" +
+	"@profile \"staging,production\" {\n" +
+	"    Replicas 3\n" +
+	"}\n"
+
+func TestResolveProfiles(t *testing.T) {
+
+	cfg, err := config.ParseFromString(profileSrc)
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	if blocks := cfg.QueryAll(config.ProfileDirective); len(blocks) != 3 || !config.IsBlock(blocks[0]) {
+		t.Error("Parser must keep conditional blocks")
+		t.Fail()
+	}
+
+	var tests = []struct {
+		profiles *config.Profiles
+		values   map[string]string
+	}{
+		{nil, map[string]string{"Port": "8080", "Debug": "true", "Host": "localhost", "Server:Main/Tls": "", "Replicas": ""}},
+		{&config.Profiles{Active: []string{"production"}, Tags: map[string]string{"region": "eu", "tls": "true"}},
+			map[string]string{"Port": "80", "Debug": "false", "Host": "eu.example.org", "Server:Main/Tls": "true", "Replicas": "3"}},
+		{&config.Profiles{Active: []string{"staging"}, Tags: map[string]string{"env": "dev", "tls": "true"}},
+			map[string]string{"Port": "8080", "Host": "localhost", "Server:Main/Tls": "", "Replicas": "3"}},
+	}
+
+	for index, test := range tests {
+		resolved, err := config.ResolveProfiles(cfg, test.profiles)
+		if err != nil {
+			t.Errorf("Cannot resolve profiles %d: %s", index, err.Error())
+			t.Fail()
+			continue
+		}
+		for query, expected := range test.values {
+			if val := resolved.StringOrDefault(query, ""); val != expected {
+				t.Errorf("Invalid value for query '%s' with profiles %d: %q", query, index, val)
+				t.Fail()
+			}
+		}
+		if len(resolved.QueryAll("Port")) != 1 || len(resolved.QueryAll(config.ProfileDirective)) != 0 {
+			t.Errorf("Blocks of profiles %d were not resolved", index)
+			t.Fail()
+		}
+	}
+
+	l := config.NewLoader(config.BytesSource("app.conf", []byte(profileSrc))).Profiles(&config.Profiles{Active: []string{"production"}})
+	if loaded, _, err := l.Load(context.Background()); err != nil || loaded.StringOrDefault("Port", "") != "80" {
+		t.Errorf("Invalid loaded profile: %v", err)
+		t.Fail()
+	}
+
+	invalid, _ := config.ParseFromString("@if \"== prod\" {\n    Port 80\n}\n")
+	if _, err := config.ResolveProfiles(invalid, nil); err == nil || !strings.HasPrefix(err.Error(), "1:1: Invalid condition") {
+		t.Errorf("Expected error for invalid condition, got %v", err)
+		t.Fail()
+	}
+
+}
@@ -0,0 +1,235 @@
+package config
+
+import "bytes"
+import "fmt"
+import "io"
+import "path"
+import "strings"
+
+// RedactedValue replaces values of sensitive keys.
+const RedactedValue = "<redacted>"
+
+// DefaultSensitivePatterns are patterns of names of sensitive keys used by
+// redactors without patterns.
+var DefaultSensitivePatterns = []string{
+	"*password*",
+	"*passwd*",
+	"*secret*",
+	"*token*",
+	"*apikey*",
+	"*privatekey*",
+	"*credential*",
+}
+
+// DefaultRedactor is used by Dump, Diff and when configuration data are
+// printed by the fmt package.
+var DefaultRedactor = new(Redactor)
+
+// Redactor hides values of sensitive keys in dumps, diffs and messages. A key
+// is sensitive if its name matches one of the patterns, if it matches one of
+// the queries or if it holds a decrypted secret. Getters of configuration
+// data always return real values, only the output of the redactor is
+// redacted.
+type Redactor struct {
+	// Patterns are case-insensitive patterns of names of sensitive keys in
+	// the syntax of path.Match, DefaultSensitivePatterns are used if nil.
+	Patterns []string
+	// Queries match sensitive keys, e.g. keys marked as secret in a schema.
+	Queries []string
+}
+
+// Dump returns the configuration data in the configuration syntax with
+// values of sensitive keys redacted, see Redactor.
+func Dump(cfg Config) string {
+	return DefaultRedactor.Dump(cfg)
+}
+
+// Diff returns differences between values of keys of two configuration data
+// with values of sensitive keys redacted, see Redactor.Diff.
+func Diff(a Config, b Config) string {
+	return DefaultRedactor.Diff(a, b)
+}
+
+// IsSensitiveName reports whether the name matches one of the patterns.
+func (this *Redactor) IsSensitiveName(name string) bool {
+	patterns := this.Patterns
+	if patterns == nil {
+		patterns = DefaultSensitivePatterns
+	}
+	name = strings.ToLower(name)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sensitive returns sensitive keys of the configuration data.
+func (this *Redactor) sensitive(root *config) map[*config]bool {
+	set := make(map[*config]bool)
+	for _, query := range this.Queries {
+		for _, node := range root.QueryAll(query) {
+			set[node.(*config)] = true
+		}
+	}
+	var walk func(node *config)
+	walk = func(node *config) {
+		for _, child := range node.children {
+			if child.children != nil {
+				walk(child)
+			} else if child.secret != "" || this.IsSensitiveName(child.name) {
+				set[child] = true
+			}
+		}
+	}
+	walk(root)
+	return set
+}
+
+// Redact returns a copy of the configuration data with values of sensitive
+// keys replaced by RedactedValue.
+func (this *Redactor) Redact(cfg Config) Config {
+	root := cloneConfig(cfg)
+	for node := range this.sensitive(root) {
+		if node.children == nil {
+			node.value = RedactedValue
+			node.secret = ""
+		}
+	}
+	return root
+}
+
+// Dump returns the configuration data in the configuration syntax with
+// values of sensitive keys redacted.
+func (this *Redactor) Dump(cfg Config) string {
+	text, _ := this.Redact(cfg).(*config).MarshalText()
+	return string(text)
+}
+
+// Message returns the message with all values of sensitive keys of the
+// configuration data replaced by RedactedValue, e.g. an error message which
+// may echo a value. Values are replaced only as whole tokens, so a short
+// value like "1" doesn't replace parts of other words and numbers.
+func (this *Redactor) Message(cfg Config, msg string) string {
+	root := cloneConfig(cfg)
+	for node := range this.sensitive(root) {
+		if node.value != "" {
+			msg = replaceToken(msg, node.value, RedactedValue)
+		}
+	}
+	return msg
+}
+
+// replaceToken replaces occurrences of the token which are not parts of
+// longer words.
+func replaceToken(str string, token string, repl string) string {
+	buf := new(bytes.Buffer)
+	for {
+		index := strings.Index(str, token)
+		for index >= 0 {
+			end := index + len(token)
+			if !(isWordByte(token[0]) && index > 0 && isWordByte(str[index-1])) &&
+				!(isWordByte(token[len(token)-1]) && end < len(str) && isWordByte(str[end])) {
+				break
+			}
+			next := strings.Index(str[index+1:], token)
+			if next < 0 {
+				index = -1
+			} else {
+				index = index + 1 + next
+			}
+		}
+		if index < 0 {
+			buf.WriteString(str)
+			return buf.String()
+		}
+		buf.WriteString(str[:index])
+		buf.WriteString(repl)
+		str = str[index+len(token):]
+	}
+}
+
+func isWordByte(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_' || b >= 0x80
+}
+
+// diffEntry is a key with its path in the query syntax.
+type diffEntry struct {
+	path      string
+	value     string
+	sensitive bool
+}
+
+func (this *Redactor) entries(cfg Config) []diffEntry {
+	root := cloneConfig(cfg)
+	sensitive := this.sensitive(root)
+	entries := make([]diffEntry, 0, 16)
+	var walk func(node *config, prefix string)
+	walk = func(node *config, prefix string) {
+		for _, child := range node.children {
+			part := child.name
+			if child.children != nil {
+				if child.value != "" {
+					part = part + ":" + child.value
+				}
+				walk(child, prefix+part+"/")
+			} else {
+				entries = append(entries, diffEntry{path: prefix + part, value: child.value, sensitive: sensitive[child]})
+			}
+		}
+	}
+	walk(root, "")
+	return entries
+}
+
+// Diff returns differences between values of keys of two configuration data,
+// one line per removed ("-"), added ("+") or changed key ("-" and "+"). Keys
+// are identified by their paths in the query syntax, repeated keys are
+// compared in order. Values of sensitive keys are redacted, so the diff shows
+// only that they changed. The diff is empty if the data are equal.
+func (this *Redactor) Diff(a Config, b Config) string {
+	before, after := this.entries(a), this.entries(b)
+	index := make(map[string][]int)
+	for key, entry := range after {
+		index[entry.path] = append(index[entry.path], key)
+	}
+	matched := make([]bool, len(after))
+	buf := new(bytes.Buffer)
+	line := func(sign string, entry diffEntry) {
+		val := fmt.Sprintf("%q", entry.value)
+		if entry.sensitive {
+			val = RedactedValue
+		}
+		fmt.Fprintf(buf, "%s %s %s\n", sign, entry.path, val)
+	}
+	for _, entry := range before {
+		candidates := index[entry.path]
+		if len(candidates) == 0 {
+			line("-", entry)
+			continue
+		}
+		other := after[candidates[0]]
+		index[entry.path] = candidates[1:]
+		matched[candidates[0]] = true
+		if other.value != entry.value {
+			entry.sensitive = entry.sensitive || other.sensitive
+			other.sensitive = entry.sensitive
+			line("-", entry)
+			line("+", other)
+		}
+	}
+	for key, entry := range after {
+		if !matched[key] {
+			line("+", entry)
+		}
+	}
+	return buf.String()
+}
+
+// Format implements fmt.Formatter, configuration data printed by the fmt
+// package are written in the configuration syntax with values of sensitive
+// keys redacted by DefaultRedactor.
+func (this *config) Format(f fmt.State, verb rune) {
+	io.WriteString(f, DefaultRedactor.Dump(this))
+}
@@ -0,0 +1,94 @@
+package config_test
+
+import "bytes"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "strings"
+import "testing"
+
+func TestRedact(t *testing.T) {
+
+	key, _ := config.SymmetricKey(bytes.Repeat([]byte{1}, 32))
+	enc, _ := config.EncryptValue(key, "s3cr3t")
+	src := "Name main\n" +
+		"DbPassword hunter2\n" +
+		"Signing \"" + enc + "\"\n" +
+		"Server Main {\n" +
+		"    ApiToken abc123\n" +
+		"    Pin 1234\n" +
+		"}\n"
+	cfg, err := config.ParseFromString(src)
+	if err != nil {
+		t.Errorf("Cannot parse: %s", err.Error())
+		t.FailNow()
+	}
+	cfg, err = config.DecryptSecrets(cfg, key)
+	if err != nil {
+		t.Errorf("Cannot decrypt: %s", err.Error())
+		t.FailNow()
+	}
+
+	r := &config.Redactor{Queries: []string{"Server/Pin"}}
+	dump := r.Dump(cfg)
+	for _, val := range []string{"hunter2", "s3cr3t", enc, "abc123", "1234"} {
+		if strings.Contains(dump, val) {
+			t.Errorf("Dump contains sensitive value %s:\n%s", val, dump)
+			t.Fail()
+		}
+	}
+	if parsed, err := config.ParseFromString(dump); err != nil || parsed.StringOrDefault("Name", "") != "main" || strings.Count(dump, config.RedactedValue) != 4 {
+		t.Errorf("Invalid dump:\n%s", dump)
+		t.Fail()
+	}
+
+	if str := fmt.Sprint(cfg); strings.Contains(str, "hunter2") || !strings.Contains(str, "1234") {
+		t.Errorf("Invalid printed configuration:\n%s", str)
+		t.Fail()
+	}
+
+	if val, _ := cfg.String("DbPassword"); val != "hunter2" {
+		t.Error("Getters must return real values")
+		t.Fail()
+	}
+	if val, _ := cfg.String("Signing"); val != "s3cr3t" {
+		t.Error("Getters must return decrypted values")
+		t.Fail()
+	}
+
+	data, _ := config.JSONMapping{Redactor: r}.Marshal(cfg)
+	if strings.Contains(string(data), "hunter2") || strings.Contains(string(data), "1234") {
+		t.Errorf("JSON contains sensitive values: %s", data)
+		t.Fail()
+	}
+
+	other, _ := config.ParseFromString("Name backup\nDbPassword hunter3\nServer Main {\n    Pin 1234\n}\nPort 80\n")
+	expected := "- Name \"main\"\n" +
+		"+ Name \"backup\"\n" +
+		"- DbPassword <redacted>\n" +
+		"+ DbPassword <redacted>\n" +
+		"- Signing <redacted>\n" +
+		"- Server:Main/ApiToken <redacted>\n" +
+		"+ Port \"80\"\n"
+	if diff := config.Diff(cfg, other); diff != expected {
+		t.Errorf("Invalid diff:\n%s", diff)
+		t.Fail()
+	}
+	if diff := config.Diff(cfg, cfg); diff != "" {
+		t.Errorf("Expected empty diff, got:\n%s", diff)
+		t.Fail()
+	}
+
+	msg := r.Message(cfg, "cannot connect with password hunter2")
+	if msg != "cannot connect with password <redacted>" {
+		t.Errorf("Invalid redacted message: %s", msg)
+		t.Fail()
+	}
+
+	short := config.NewBuilder().String("Pin", "1").Config()
+	msg = (&config.Redactor{Patterns: []string{"pin"}}).Message(short, "pin 1 rejected on port 8010, retry 1.")
+	if msg != "pin <redacted> rejected on port 8010, retry <redacted>." {
+		t.Errorf("Invalid redacted message with short value: %s", msg)
+		t.Fail()
+	}
+
+}
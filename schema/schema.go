@@ -0,0 +1,308 @@
+// Package schema describes and validates structure of configuration data.
+//
+// Schemas are written in the configuration syntax itself:
+//
+//	Key Name {
+//	    Type string
+//	    Required true
+//	    Pattern "^[a-z]+$"
+//	}
+//
+//	Section Server {
+//	    Value Main
+//	    Value Backup
+//	    Min 1
+//	    Max 2
+//
+//	    Key Port {
+//	        Type int
+//	        Min 1
+//	        Max 65535
+//	        Default 8080
+//	    }
+//
+//	    Key Mode {
+//	        Enum debug
+//	        Enum release
+//	    }
+//	}
+//
+// A key may specify its Type (string, int, float, bool or duration), whether
+// it is Required or Repeated, Min and Max (bounds of numbers and durations or
+// length of strings), allowed values with repeated Enum, a regular expression
+// Pattern, a Default value and whether it is Secret. Values of secret keys are
+// never shown in validation errors, see SensitiveQueries. A section may
+// specify allowed section values with repeated Value, the minimal and maximal
+// count of sections with Min and Max and nested keys and sections.
+package schema
+
+import "errors"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "regexp"
+import "strconv"
+import "time"
+
+// Type is a type of key values.
+type Type string
+
+const (
+	Bool     Type = "bool"
+	Duration Type = "duration"
+	Float    Type = "float"
+	Int      Type = "int"
+	String   Type = "string"
+)
+
+// Schema describes keys and sections of a section, the root schema describes
+// the root of configuration data.
+type Schema struct {
+	Keys     []*Key
+	Sections []*Section
+}
+
+// Key describes a key and its value.
+type Key struct {
+	Name     string
+	Type     Type
+	Required bool
+	Repeated bool
+	Min      string
+	Max      string
+	Enum     []string
+	Pattern  string
+	Default  string
+	Secret   bool
+	pattern  *regexp.Regexp
+}
+
+// Section describes a section, its values and its content.
+type Section struct {
+	Name   string
+	Values []string
+	Min    int
+	Max    int
+	Schema
+}
+
+// ParseFromFile parses a schema from the specified file.
+func ParseFromFile(file string) (*Schema, error) {
+	cfg, err := config.ParseFromFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(cfg)
+}
+
+// ParseFromString parses a schema from the specified string.
+func ParseFromString(str string) (*Schema, error) {
+	cfg, err := config.ParseFromString(str)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(cfg)
+}
+
+// Parse returns a schema described by the specified configuration data.
+func Parse(cfg config.Config) (*Schema, error) {
+	s := new(Schema)
+	if err := s.parse(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (this *Schema) parse(cfg config.Config) error {
+	for _, child := range config.Children(cfg) {
+		switch {
+		case child.Name() == "Key" && config.IsSection(child):
+			key, err := parseKey(child)
+			if err != nil {
+				return err
+			}
+			this.Keys = append(this.Keys, key)
+		case child.Name() == "Section" && config.IsSection(child):
+			section, err := parseSection(child)
+			if err != nil {
+				return err
+			}
+			this.Sections = append(this.Sections, section)
+		case cfg.Name() == "Section" && !config.IsSection(child):
+			// Properties of the section are parsed by parseSection.
+		default:
+			return schemaError(child, fmt.Sprintf("Unexpected '%s'", child.Name()))
+		}
+	}
+	return nil
+}
+
+// Compile checks the schema and prepares it for validation. It is called by
+// Parse, schemas created in code have to be compiled before they are used.
+func (this *Schema) Compile() error {
+	for _, key := range this.Keys {
+		if err := key.compile(); err != nil {
+			return err
+		}
+	}
+	for _, section := range this.Sections {
+		if section.Name == "" {
+			return errors.New("Section without name")
+		}
+		if section.Min < 0 || (section.Max > 0 && section.Max < section.Min) {
+			return errors.New(fmt.Sprintf("Invalid count of section '%s'", section.Name))
+		}
+		if err := section.Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Key returns schema of the key with the specified name.
+func (this *Schema) Key(name string) *Key {
+	for _, key := range this.Keys {
+		if key.Name == name {
+			return key
+		}
+	}
+	return nil
+}
+
+// Section returns schema of the section with the specified name.
+func (this *Schema) Section(name string) *Section {
+	for _, section := range this.Sections {
+		if section.Name == name {
+			return section
+		}
+	}
+	return nil
+}
+
+func parseKey(cfg config.Config) (*Key, error) {
+	key := new(Key)
+	key.Name = cfg.Value()
+	key.Type = String
+	for _, child := range config.Children(cfg) {
+		var err error
+		switch child.Name() {
+		case "Type":
+			key.Type = Type(child.Value())
+		case "Required":
+			key.Required, err = strconv.ParseBool(child.Value())
+		case "Repeated":
+			key.Repeated, err = strconv.ParseBool(child.Value())
+		case "Min":
+			key.Min = child.Value()
+		case "Max":
+			key.Max = child.Value()
+		case "Enum":
+			key.Enum = append(key.Enum, child.Value())
+		case "Pattern":
+			key.Pattern = child.Value()
+		case "Default":
+			key.Default = child.Value()
+		case "Secret":
+			key.Secret, err = strconv.ParseBool(child.Value())
+		default:
+			return nil, schemaError(child, fmt.Sprintf("Unexpected '%s'", child.Name()))
+		}
+		if err != nil {
+			return nil, schemaError(child, fmt.Sprintf("Invalid '%s'", child.Name()))
+		}
+	}
+	if err := key.compile(); err != nil {
+		return nil, schemaError(cfg, err.Error())
+	}
+	return key, nil
+}
+
+func parseSection(cfg config.Config) (*Section, error) {
+	section := new(Section)
+	section.Name = cfg.Value()
+	for _, child := range config.Children(cfg) {
+		if config.IsSection(child) {
+			continue
+		}
+		var err error
+		switch child.Name() {
+		case "Value":
+			section.Values = append(section.Values, child.Value())
+		case "Required":
+			var required bool
+			if required, err = strconv.ParseBool(child.Value()); required && section.Min == 0 {
+				section.Min = 1
+			}
+		case "Min":
+			section.Min, err = strconv.Atoi(child.Value())
+		case "Max":
+			section.Max, err = strconv.Atoi(child.Value())
+		default:
+			return nil, schemaError(child, fmt.Sprintf("Unexpected '%s'", child.Name()))
+		}
+		if err != nil {
+			return nil, schemaError(child, fmt.Sprintf("Invalid '%s'", child.Name()))
+		}
+	}
+	if section.Name == "" {
+		return nil, schemaError(cfg, "Section without name")
+	}
+	if section.Min < 0 || (section.Max > 0 && section.Max < section.Min) {
+		return nil, schemaError(cfg, fmt.Sprintf("Invalid count of section '%s'", section.Name))
+	}
+	if err := section.parse(cfg); err != nil {
+		return nil, err
+	}
+	return section, nil
+}
+
+func (this *Key) compile() error {
+	if this.Name == "" {
+		return errors.New("Key without name")
+	}
+	switch this.Type {
+	case Bool, Duration, Float, Int, String:
+	default:
+		return errors.New(fmt.Sprintf("Unknown type '%s' of key '%s'", this.Type, this.Name))
+	}
+	for _, bound := range []string{this.Min, this.Max} {
+		if _, err := this.parseBound(bound); err != nil {
+			return errors.New(fmt.Sprintf("Invalid bound '%s' of key '%s'", bound, this.Name))
+		}
+	}
+	if this.Pattern != "" {
+		pattern, err := regexp.Compile(this.Pattern)
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid pattern of key '%s': %s", this.Name, err.Error()))
+		}
+		this.pattern = pattern
+	}
+	if this.Default != "" {
+		if msg := this.check(this.Default, this.IsSensitive()); msg != "" {
+			return errors.New(fmt.Sprintf("Invalid default of key '%s': %s", this.Name, msg))
+		}
+	}
+	return nil
+}
+
+// parseBound returns the bound as a float number comparable with values of
+// the key. Durations are compared in nanoseconds, strings by their length.
+func (this *Key) parseBound(bound string) (float64, error) {
+	if bound == "" {
+		return 0, nil
+	}
+	switch this.Type {
+	case Duration:
+		d, err := time.ParseDuration(bound)
+		return float64(d), err
+	case Float:
+		return strconv.ParseFloat(bound, 64)
+	case Int, String:
+		i, err := strconv.ParseInt(bound, 10, 64)
+		return float64(i), err
+	}
+	return 0, errors.New("Bounds are not supported")
+}
+
+func schemaError(cfg config.Config, msg string) error {
+	return errors.New(fmt.Sprintf("%s: %s", config.PositionOf(cfg), msg))
+}
@@ -0,0 +1,156 @@
+package schema_test
+
+import "github.com/twoleds-golang/config"
+import "github.com/twoleds-golang/config/schema"
+import "strings"
+import "testing"
+
+var schemaSrc = `
+Key Name {
+    Required true
+    Pattern "^[a-z]+$"
+}
+
+Key Debug {
+    Type bool
+    Default false
+}
+
+Section Server {
+    Value Main
+    Value Backup
+    Min 1
+    Max 2
+
+    Key Port {
+        Type int
+        Min 1
+        Max 65535
+        Default 8080
+    }
+
+    Key Timeout {
+        Type duration
+        Max 1m
+    }
+
+    Key Mode {
+        Enum debug
+        Enum release
+    }
+}
+`
+
+func TestValidate(t *testing.T) {
+
+	s, err := schema.ParseFromString(schemaSrc)
+	if err != nil {
+		t.Errorf("Cannot parse schema: %s", err.Error())
+		t.FailNow()
+	}
+
+	var src = "Name main\n" +
+		"Server Main {\n" +
+		"    Port eighty\n" +
+		"    Timeout 2m\n" +
+		"    Mode test\n" +
+		"    Host localhost\n" +
+		"}\n" +
+		"Server Spare {\n" +
+		"    Port 0\n" +
+		"}\n" +
+		"Server Backup {\n" +
+		"}\n"
+
+	cfg, err := config.ParseFromString(src)
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	expected := []string{
+		`3:5: Server:Main/Port: value "eighty" is not an int`,
+		`4:5: Server:Main/Timeout: value "2m" is greater than 1m`,
+		`5:5: Server:Main/Mode: value "test" is not one of debug, release`,
+		`6:5: Server:Main/Host: unknown key Host`,
+		`8:1: Server:Spare: value "Spare" is not one of Main, Backup`,
+		`9:5: Server:Spare/Port: value "0" is less than 1`,
+		`11:1: Server:Backup: section Server may be used at most 2 times`,
+	}
+
+	errs := s.Validate(cfg)
+	if len(errs) != len(expected) {
+		t.Errorf("Invalid number of errors: %v", errs)
+		t.FailNow()
+	}
+
+	for key, err := range errs {
+		if err.Error() != expected[key] {
+			t.Errorf("Invalid error %q, expected %q", err.Error(), expected[key])
+			t.Fail()
+		}
+	}
+
+	cfg, _ = config.ParseFromString("Debug yes\n")
+	if errs := s.Validate(cfg); len(errs) != 3 {
+		t.Errorf("Invalid errors for missing values: %v", errs)
+		t.Fail()
+	}
+
+	if val, ok := s.Defaults().Bool("Debug"); !ok || val != false {
+		t.Error("Invalid default value for 'Debug'")
+		t.Fail()
+	}
+
+	if err := s.Check(s.Reference()); err == nil {
+		t.Error("Expected error for reference with empty 'Name'")
+		t.Fail()
+	}
+
+	ref := s.Reference()
+	if val, _ := ref.String("Server:Backup/Port"); val != "8080" || len(ref.QueryAll("Server")) != 2 {
+		t.Error("Invalid reference for 'Server:Backup/Port'")
+		t.Fail()
+	}
+
+	if types := s.Types(); types["Server/Port"] != "int" || types["Server/Timeout"] != "duration" {
+		t.Errorf("Invalid types: %v", types)
+		t.Fail()
+	}
+
+	if _, err := schema.ParseFromString("Key Port {\n    Type integer\n}\n"); err == nil {
+		t.Error("Expected error for unknown type")
+		t.Fail()
+	}
+
+	for src, expected := range map[string]string{
+		"Section Server {\n    Min -1\n}\n":                                                "1:1: Invalid count of section 'Server'",
+		"Section Server {\n    Min 2\n    Max 1\n}\n":                                      "1:1: Invalid count of section 'Server'",
+		"Section Server {\n    Min 1\n    Max 2\n}\n":                                      "",
+		"Section Server {\n    Section Listen {\n        Min 3\n        Max 2\n    }\n}\n": "2:5: Invalid count of section 'Listen'",
+	} {
+		if _, err := schema.ParseFromString(src); (expected == "" && err != nil) || (expected != "" && (err == nil || err.Error() != expected)) {
+			t.Errorf("Invalid error for section count:\n%s%v", src, err)
+			t.Fail()
+		}
+	}
+
+	s, _ = schema.ParseFromString("Key Pin {\n    Type int\n    Secret true\n}\nKey DbPassword {\n    Min 8\n}\n")
+	cfg, _ = config.ParseFromString("Pin abc\nDbPassword tiny\n")
+	if errs := s.Validate(cfg); len(errs) != 2 || strings.Contains(s.Check(cfg).Error(), "abc") || strings.Contains(s.Check(cfg).Error(), "tiny") {
+		t.Errorf("Invalid errors for sensitive values: %v", errs)
+		t.Fail()
+	}
+	if queries := s.SensitiveQueries(); len(queries) != 1 || queries[0] != "Pin" {
+		t.Errorf("Invalid sensitive queries: %v", queries)
+		t.Fail()
+	}
+
+	s, _ = schema.ParseFromString(schemaSrc)
+	cfg, _ = config.ParseFromString("Debug true\n@profile production {\n    Name prod\n    Debug maybe\n    Unknown 1\n}\nServer Main {\n}\n")
+	if errs := s.Validate(cfg); len(errs) != 2 || errs[0].Pos.Line != 4 || errs[1].Pos.Line != 5 {
+		t.Errorf("Invalid errors for conditional blocks: %v", errs)
+		t.Fail()
+	}
+
+}
@@ -0,0 +1,221 @@
+package schema
+
+import "errors"
+import "fmt"
+import "reflect"
+import "strconv"
+import "strings"
+import "time"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// FromStruct returns a schema derived from a struct. Names of keys and
+// sections are taken from names of fields or from the "config" tag:
+//
+//	type Server struct {
+//	    Name    string        `config:",value"`
+//	    Port    int           `config:"Port,required,min=1,max=65535,default=8080"`
+//	    Mode    string        `config:",enum=debug|release"`
+//	    Timeout time.Duration `config:",max=1m"`
+//	}
+//
+//	type Config struct {
+//	    Servers []Server `config:"Server,values=Main|Backup"`
+//	    Ignored string   `config:"-"`
+//	}
+//
+// Supported options are required, repeated, min, max, enum, pattern, default
+// and secret for keys, required, min, max and values for sections. Option value
+// may not contain a comma, multiple values are separated with "|". A string
+// field with the value option receives the value of its section.
+//
+// Booleans, numbers, strings and durations are keys, structs and pointers to
+// structs are sections and slices are repeated keys or sections. Fields of
+// embedded structs are treated as fields of the outer struct. Integers which
+// have less than 64 bits or are unsigned get the range of their type as min
+// and max unless the options set them, negative min of unsigned integers is
+// an error.
+func FromStruct(v interface{}) (*Schema, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New("FromStruct requires a struct")
+	}
+	s := new(Schema)
+	if err := s.fromStruct(t); err != nil {
+		return nil, err
+	}
+	if err := s.Compile(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (this *Schema) fromStruct(t reflect.Type) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("config")
+		if tag == "-" || field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		name, opts := parseTag(tag)
+		if _, ok := opts["value"]; ok {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		ft := field.Type
+		repeated := false
+		if ft.Kind() == reflect.Slice {
+			ft = ft.Elem()
+			repeated = true
+		}
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if field.Anonymous && ft.Kind() == reflect.Struct && tag == "" {
+			if err := this.fromStruct(ft); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if ft.Kind() == reflect.Struct && ft != durationType {
+			section := &Section{Name: name, Max: 1}
+			if repeated {
+				section.Max = 0
+			}
+			if err := section.fromOptions(opts); err != nil {
+				return errors.New(fmt.Sprintf("Field '%s': %s", field.Name, err.Error()))
+			}
+			if err := section.fromStruct(ft); err != nil {
+				return err
+			}
+			this.Sections = append(this.Sections, section)
+			continue
+		}
+
+		key := &Key{Name: name, Repeated: repeated}
+		switch {
+		case ft == durationType:
+			key.Type = Duration
+		case ft.Kind() == reflect.Bool:
+			key.Type = Bool
+		case ft.Kind() >= reflect.Int && ft.Kind() <= reflect.Uint64:
+			key.Type = Int
+		case ft.Kind() == reflect.Float32 || ft.Kind() == reflect.Float64:
+			key.Type = Float
+		case ft.Kind() == reflect.String:
+			key.Type = String
+		default:
+			return errors.New(fmt.Sprintf("Field '%s' has unsupported type %s", field.Name, field.Type))
+		}
+		if err := key.fromOptions(opts); err != nil {
+			return errors.New(fmt.Sprintf("Field '%s': %s", field.Name, err.Error()))
+		}
+		if key.Type == Int {
+			if err := key.intBounds(ft); err != nil {
+				return errors.New(fmt.Sprintf("Field '%s': %s", field.Name, err.Error()))
+			}
+		}
+		this.Keys = append(this.Keys, key)
+	}
+	return nil
+}
+
+func (this *Key) fromOptions(opts map[string]string) error {
+	for opt, val := range opts {
+		switch opt {
+		case "required":
+			this.Required = true
+		case "repeated":
+			this.Repeated = true
+		case "min":
+			this.Min = val
+		case "max":
+			this.Max = val
+		case "enum":
+			this.Enum = strings.Split(val, "|")
+		case "pattern":
+			this.Pattern = val
+		case "default":
+			this.Default = val
+		case "secret":
+			this.Secret = true
+		default:
+			return errors.New(fmt.Sprintf("Unknown option '%s'", opt))
+		}
+	}
+	return nil
+}
+
+// intBounds sets bounds of the integer type which are not set by options, so
+// values which don't fit the field are reported by the schema. Unsigned
+// integers have min 0, integers with less than 64 bits have their range.
+func (this *Key) intBounds(t reflect.Type) error {
+	unsigned := t.Kind() >= reflect.Uint && t.Kind() <= reflect.Uintptr
+	if unsigned && strings.HasPrefix(this.Min, "-") {
+		return errors.New("Negative min of unsigned integer")
+	}
+	bits := t.Bits()
+	if unsigned {
+		if this.Min == "" {
+			this.Min = "0"
+		}
+		if this.Max == "" && bits < 64 {
+			this.Max = strconv.FormatUint(1<<uint(bits)-1, 10)
+		}
+	} else if bits < 64 {
+		if this.Min == "" {
+			this.Min = strconv.FormatInt(-1<<uint(bits-1), 10)
+		}
+		if this.Max == "" {
+			this.Max = strconv.FormatInt(1<<uint(bits-1)-1, 10)
+		}
+	}
+	return nil
+}
+
+func (this *Section) fromOptions(opts map[string]string) error {
+	var err error
+	for opt, val := range opts {
+		switch opt {
+		case "required":
+			if this.Min == 0 {
+				this.Min = 1
+			}
+		case "min":
+			_, err = fmt.Sscan(val, &this.Min)
+		case "max":
+			_, err = fmt.Sscan(val, &this.Max)
+		case "values":
+			this.Values = strings.Split(val, "|")
+		default:
+			return errors.New(fmt.Sprintf("Unknown option '%s'", opt))
+		}
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid option '%s'", opt))
+		}
+	}
+	return nil
+}
+
+// parseTag splits the tag to the name and options.
+func parseTag(tag string) (name string, opts map[string]string) {
+	opts = make(map[string]string)
+	parts := strings.Split(tag, ",")
+	for _, part := range parts[1:] {
+		if index := strings.IndexByte(part, '='); index >= 0 {
+			opts[part[:index]] = part[index+1:]
+		} else if part != "" {
+			opts[part] = ""
+		}
+	}
+	return parts[0], opts
+}
@@ -0,0 +1,163 @@
+package schema_test
+
+import "bytes"
+import "github.com/twoleds-golang/config"
+import "github.com/twoleds-golang/config/schema"
+import "testing"
+import "time"
+
+type testServer struct {
+	Name    string        `config:",value"`
+	Port    int           `config:",required,min=1,max=65535"`
+	Mode    string        `config:",enum=debug|release"`
+	Timeout time.Duration `config:",max=1m"`
+	Workers uint
+	Retries uint8
+	Offset  int16 `config:",max=100"`
+}
+
+type testConfig struct {
+	Debug   bool         `config:",default=false"`
+	Servers []testServer `config:"Server,values=Main|Backup"`
+	Hosts   []string     `config:"Host"`
+	Ignored string       `config:"-"`
+	ignored string
+}
+
+func TestFromStruct(t *testing.T) {
+
+	s, err := schema.FromStruct(&testConfig{})
+	if err != nil {
+		t.Errorf("Cannot derive schema: %s", err.Error())
+		t.FailNow()
+	}
+
+	var src = "Host a\n" +
+		"Host b\n" +
+		"Server Main {\n" +
+		"    Port 0\n" +
+		"    Timeout 1s\n" +
+		"    Workers -1\n" +
+		"    Retries 300\n" +
+		"    Offset -40000\n" +
+		"}\n" +
+		"Server Backup {\n" +
+		"    Mode test\n" +
+		"}\n"
+
+	cfg, _ := config.ParseFromString(src)
+
+	expected := []string{
+		`4:5: Server:Main/Port: value "0" is less than 1`,
+		`6:5: Server:Main/Workers: value "-1" is less than 0`,
+		`7:5: Server:Main/Retries: value "300" is greater than 255`,
+		`8:5: Server:Main/Offset: value "-40000" is less than -32768`,
+		`11:5: Server:Backup/Mode: value "test" is not one of debug, release`,
+		`10:1: Server:Backup: missing required key Port`,
+	}
+
+	errs := s.Validate(cfg)
+	if len(errs) != len(expected) {
+		t.Errorf("Invalid number of errors: %v", errs)
+		t.FailNow()
+	}
+
+	for key, err := range errs {
+		if err.Error() != expected[key] {
+			t.Errorf("Invalid error %q, expected %q", err.Error(), expected[key])
+			t.Fail()
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	w := config.NewWriter(buf)
+	s.Write(w)
+	w.Flush()
+
+	var exportedSrc = "Key Debug {\n" +
+		"    Type bool\n" +
+		"    Default false\n" +
+		"}\n" +
+		"Key Host {\n" +
+		"    Type string\n" +
+		"    Repeated true\n" +
+		"}\n" +
+		"Section Server {\n" +
+		"    Value Main\n" +
+		"    Value Backup\n" +
+		"    Key Port {\n" +
+		"        Type int\n" +
+		"        Required true\n" +
+		"        Min 1\n" +
+		"        Max 65535\n" +
+		"    }\n" +
+		"    Key Mode {\n" +
+		"        Type string\n" +
+		"        Enum debug\n" +
+		"        Enum release\n" +
+		"    }\n" +
+		"    Key Timeout {\n" +
+		"        Type duration\n" +
+		"        Max 1m\n" +
+		"    }\n" +
+		"    Key Workers {\n" +
+		"        Type int\n" +
+		"        Min 0\n" +
+		"    }\n" +
+		"    Key Retries {\n" +
+		"        Type int\n" +
+		"        Min 0\n" +
+		"        Max 255\n" +
+		"    }\n" +
+		"    Key Offset {\n" +
+		"        Type int\n" +
+		"        Min -32768\n" +
+		"        Max 100\n" +
+		"    }\n" +
+		"}\n"
+
+	if buf.String() != exportedSrc {
+		t.Errorf("Invalid exported schema:\n%s", buf.String())
+		t.Fail()
+	}
+
+	exported, err := schema.ParseFromString(buf.String())
+	if err != nil {
+		t.Errorf("Cannot parse exported schema: %s\n%s", err.Error(), buf.String())
+		t.FailNow()
+	}
+
+	errs = exported.Validate(cfg)
+	if len(errs) != len(expected) {
+		t.Errorf("Exported schema differs: %v", errs)
+		t.FailNow()
+	}
+	for key, err := range errs {
+		if err.Error() != expected[key] {
+			t.Errorf("Invalid error of exported schema %q, expected %q", err.Error(), expected[key])
+			t.Fail()
+		}
+	}
+
+	if _, err := schema.FromStruct(42); err == nil || err.Error() != "FromStruct requires a struct" {
+		t.Errorf("Expected error for non-struct value, got %v", err)
+		t.Fail()
+	}
+
+	var negative struct {
+		Count uint `config:",min=-1"`
+	}
+	if _, err := schema.FromStruct(&negative); err == nil || err.Error() != "Field 'Count': Negative min of unsigned integer" {
+		t.Errorf("Expected error for negative min, got %v", err)
+		t.Fail()
+	}
+
+	var unknown struct {
+		Count int `config:",size=1"`
+	}
+	if _, err := schema.FromStruct(&unknown); err == nil || err.Error() != "Field 'Count': Unknown option 'size'" {
+		t.Errorf("Expected error for unknown option, got %v", err)
+		t.Fail()
+	}
+
+}
@@ -0,0 +1,317 @@
+package schema
+
+import "errors"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "strconv"
+import "strings"
+import "time"
+
+// ValidationError describes a single violation of a schema.
+type ValidationError struct {
+	// Pos is the position of the offending node, it is zero for problems of
+	// the root section.
+	Pos config.Position
+	// Path is the path of the offending node in the query syntax.
+	Path string
+	Msg  string
+}
+
+func (this ValidationError) Error() string {
+	if this.Pos.IsZero() {
+		return fmt.Sprintf("%s: %s", this.Path, this.Msg)
+	}
+	return fmt.Sprintf("%s: %s: %s", this.Pos, this.Path, this.Msg)
+}
+
+// Validate checks the configuration data against the schema and returns all
+// violations.
+func (this *Schema) Validate(cfg config.Config) []ValidationError {
+	v := new(validator)
+	v.validate(cfg, "", this)
+	return v.errors
+}
+
+// Check returns an error which lists all violations of the schema or nil if
+// there are none. It can be used as a validation function of config.Loader.
+func (this *Schema) Check(cfg config.Config) error {
+	errs := this.Validate(cfg)
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for key, err := range errs {
+		msgs[key] = err.Error()
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+type validator struct {
+	errors []ValidationError
+}
+
+func (this *validator) report(cfg config.Config, path string, msg string) {
+	this.errors = append(this.errors, ValidationError{Pos: config.PositionOf(cfg), Path: path, Msg: msg})
+}
+
+func (this *validator) path(parent string, cfg config.Config) string {
+	part := cfg.Name()
+	if config.IsSection(cfg) && cfg.Value() != "" {
+		part = part + ":" + cfg.Value()
+	}
+	if parent == "" {
+		return part
+	}
+	return parent + "/" + part
+}
+
+func (this *validator) validate(cfg config.Config, path string, schema *Schema) {
+	counts, blocks := make(map[string]int), make(map[string]int)
+	this.children(cfg, path, schema, counts, blocks)
+
+	for _, key := range schema.Keys {
+		if key.Required && key.Default == "" && counts[key.Name]+blocks[key.Name] == 0 {
+			this.report(cfg, path, fmt.Sprintf("missing required key %s", key.Name))
+		}
+	}
+	for _, section := range schema.Sections {
+		if counts[section.Name]+blocks[section.Name] < section.Min {
+			if section.Min == 1 {
+				this.report(cfg, path, fmt.Sprintf("missing required section %s", section.Name))
+			} else {
+				this.report(cfg, path, fmt.Sprintf("section %s must be used at least %d times", section.Name, section.Min))
+			}
+		}
+	}
+}
+
+// children validates children of the section and counts them. Conditional
+// blocks (see config.ResolveProfiles) are validated against the schema of
+// the section whether they are active or not. Their nodes are counted apart
+// in blocks, they may override nodes of the section but they also satisfy
+// requirements.
+func (this *validator) children(cfg config.Config, path string, schema *Schema, counts map[string]int, blocks map[string]int) {
+	for _, child := range config.Children(cfg) {
+		if config.IsBlock(child) {
+			inner := make(map[string]int)
+			this.children(child, path, schema, inner, blocks)
+			for name, count := range inner {
+				blocks[name] = blocks[name] + count
+			}
+			continue
+		}
+		childPath := this.path(path, child)
+		counts[child.Name()]++
+		if config.IsSection(child) {
+			section := schema.Section(child.Name())
+			if section == nil {
+				if schema.Key(child.Name()) != nil {
+					this.report(child, childPath, fmt.Sprintf("%s must be a key", child.Name()))
+				} else {
+					this.report(child, childPath, fmt.Sprintf("unknown section %s", child.Name()))
+				}
+				continue
+			}
+			if len(section.Values) > 0 && !this.contains(section.Values, child.Value()) {
+				this.report(child, childPath, fmt.Sprintf("value %q is not one of %s", child.Value(), strings.Join(section.Values, ", ")))
+			}
+			if section.Max > 0 && counts[child.Name()] == section.Max+1 {
+				this.report(child, childPath, fmt.Sprintf("section %s may be used at most %d times", child.Name(), section.Max))
+			}
+			this.validate(child, childPath, &section.Schema)
+		} else {
+			key := schema.Key(child.Name())
+			if key == nil {
+				if schema.Section(child.Name()) != nil {
+					this.report(child, childPath, fmt.Sprintf("%s must be a section", child.Name()))
+				} else {
+					this.report(child, childPath, fmt.Sprintf("unknown key %s", child.Name()))
+				}
+				continue
+			}
+			if !key.Repeated && counts[child.Name()] == 2 {
+				this.report(child, childPath, fmt.Sprintf("key %s is defined more than once", child.Name()))
+			}
+			sensitive := key.IsSensitive() || config.StoredValue(child) != child.Value()
+			if msg := key.check(child.Value(), sensitive); msg != "" {
+				this.report(child, childPath, msg)
+			}
+		}
+	}
+}
+
+func (this *validator) contains(values []string, val string) bool {
+	for _, v := range values {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
+
+// check returns a description of the problem with the value or an empty
+// string if the value is valid. Sensitive values are not echoed.
+func (this *Key) check(val string, sensitive bool) string {
+	shown := strconv.Quote(val)
+	if sensitive {
+		shown = config.RedactedValue
+	}
+	var num float64
+	switch this.Type {
+	case Bool:
+		if _, err := strconv.ParseBool(val); err != nil {
+			return fmt.Sprintf("value %s is not a bool", shown)
+		}
+	case Duration:
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			return fmt.Sprintf("value %s is not a duration", shown)
+		}
+		num = float64(d)
+	case Float:
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return fmt.Sprintf("value %s is not a float", shown)
+		}
+		num = f
+	case Int:
+		i, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return fmt.Sprintf("value %s is not an int", shown)
+		}
+		num = float64(i)
+	case String:
+		num = float64(len(val))
+	}
+	if min, _ := this.parseBound(this.Min); this.Min != "" && num < min {
+		if this.Type == String {
+			return fmt.Sprintf("value %s is shorter than %s", shown, this.Min)
+		}
+		return fmt.Sprintf("value %s is less than %s", shown, this.Min)
+	}
+	if max, _ := this.parseBound(this.Max); this.Max != "" && num > max {
+		if this.Type == String {
+			return fmt.Sprintf("value %s is longer than %s", shown, this.Max)
+		}
+		return fmt.Sprintf("value %s is greater than %s", shown, this.Max)
+	}
+	if len(this.Enum) > 0 {
+		found := false
+		for _, e := range this.Enum {
+			found = found || e == val
+		}
+		if !found {
+			return fmt.Sprintf("value %s is not one of %s", shown, strings.Join(this.Enum, ", "))
+		}
+	}
+	if this.pattern != nil && !this.pattern.MatchString(val) {
+		return fmt.Sprintf("value %s does not match %s", shown, this.Pattern)
+	}
+	return ""
+}
+
+// IsSensitive reports whether values of the key must not be shown in dumps
+// and messages, i.e. the key is marked as Secret or its name matches
+// config.DefaultSensitivePatterns.
+func (this *Key) IsSensitive() bool {
+	return this.Secret || config.DefaultRedactor.IsSensitiveName(this.Name)
+}
+
+// SensitiveQueries returns queries of keys marked as Secret, e.g. for
+// config.Redactor.
+func (this *Schema) SensitiveQueries() []string {
+	queries := make([]string, 0)
+	for _, key := range this.Keys {
+		if key.Secret {
+			queries = append(queries, key.Name)
+		}
+	}
+	for _, section := range this.Sections {
+		for _, query := range section.SensitiveQueries() {
+			queries = append(queries, section.Name+"/"+query)
+		}
+	}
+	return queries
+}
+
+// Types returns types of keys by their paths, i.e. names of sections and of
+// the key like "Server/Port", e.g. for config.BindTypedFlags.
+func (this *Schema) Types() map[string]string {
+	types := make(map[string]string)
+	for _, key := range this.Keys {
+		types[key.Name] = string(key.Type)
+	}
+	for _, section := range this.Sections {
+		for path, typ := range section.Types() {
+			types[section.Name+"/"+path] = typ
+		}
+	}
+	return types
+}
+
+// Defaults returns configuration data with default values of keys. Sections
+// which are required and have at most one allowed value are included too.
+func (this *Schema) Defaults() config.Config {
+	b := config.NewBuilder()
+	this.defaults(b)
+	return b.Config()
+}
+
+func (this *Schema) defaults(b config.Builder) {
+	for _, key := range this.Keys {
+		if key.Default != "" {
+			b.String(key.Name, key.Default)
+		}
+	}
+	for _, section := range this.Sections {
+		if section.Min > 0 && len(section.Values) <= 1 {
+			val := ""
+			if len(section.Values) == 1 {
+				val = section.Values[0]
+			}
+			b.Section(section.Name, val)
+			section.defaults(b)
+			b.CloseSection()
+		}
+	}
+}
+
+// Reference returns configuration data with every key and section of the
+// schema, e.g. as the reference for config.BindFlags. Keys have their
+// default value or the zero value of their type, sections are added once for
+// every allowed section value or once with an empty value.
+func (this *Schema) Reference() config.Config {
+	b := config.NewBuilder()
+	this.reference(b)
+	return b.Config()
+}
+
+func (this *Schema) reference(b config.Builder) {
+	for _, key := range this.Keys {
+		val := key.Default
+		if val == "" {
+			switch key.Type {
+			case Bool:
+				val = "false"
+			case Duration:
+				val = "0s"
+			case Float:
+				val = "0.0"
+			case Int:
+				val = "0"
+			}
+		}
+		b.String(key.Name, val)
+	}
+	for _, section := range this.Sections {
+		values := section.Values
+		if len(values) == 0 {
+			values = []string{""}
+		}
+		for _, val := range values {
+			b.Section(section.Name, val)
+			section.reference(b)
+			b.CloseSection()
+		}
+	}
+}
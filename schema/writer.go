@@ -0,0 +1,51 @@
+package schema
+
+import "github.com/twoleds-golang/config"
+
+// Write writes the schema in the configuration syntax, the output can be
+// parsed back with Parse.
+func (this *Schema) Write(w config.Writer) {
+	for _, key := range this.Keys {
+		w.Section("Key", key.Name)
+		w.String("Type", string(key.Type))
+		if key.Required {
+			w.Bool("Required", true)
+		}
+		if key.Repeated {
+			w.Bool("Repeated", true)
+		}
+		if key.Min != "" {
+			w.String("Min", key.Min)
+		}
+		if key.Max != "" {
+			w.String("Max", key.Max)
+		}
+		for _, val := range key.Enum {
+			w.String("Enum", val)
+		}
+		if key.Pattern != "" {
+			w.String("Pattern", key.Pattern)
+		}
+		if key.Default != "" {
+			w.String("Default", key.Default)
+		}
+		if key.Secret {
+			w.Bool("Secret", true)
+		}
+		w.CloseSection()
+	}
+	for _, section := range this.Sections {
+		w.Section("Section", section.Name)
+		for _, val := range section.Values {
+			w.String("Value", val)
+		}
+		if section.Min != 0 {
+			w.Int("Min", int64(section.Min))
+		}
+		if section.Max != 0 {
+			w.Int("Max", int64(section.Max))
+		}
+		section.Write(w)
+		w.CloseSection()
+	}
+}
@@ -0,0 +1,340 @@
+package config
+
+import "bytes"
+import "crypto/aes"
+import "crypto/cipher"
+import "crypto/ecdh"
+import "crypto/hmac"
+import "crypto/rand"
+import "crypto/sha256"
+import "encoding/base64"
+import "errors"
+import "fmt"
+import "os"
+import "strings"
+
+// SecretPrefix is the prefix of encrypted values. The rest of the value is
+// the base64url encoded envelope: a version byte, the length of the header,
+// the header of the key provider, the nonce and the AES-GCM ciphertext.
+const SecretPrefix = "enc:"
+
+const secretVersion = 1
+
+// KeyProvider provides 256-bit keys for AES-GCM encryption of secret values.
+// Every encrypted value stores a header which identifies its key.
+type KeyProvider interface {
+	// NewKey returns a key for encryption of a value and the header which is
+	// stored with the encrypted value.
+	NewKey() (key []byte, header []byte, err error)
+	// Key returns the key of a value encrypted with the specified header.
+	Key(header []byte) (key []byte, err error)
+}
+
+// IsSecret reports whether the value is encrypted.
+func IsSecret(val string) bool {
+	return strings.HasPrefix(val, SecretPrefix)
+}
+
+// EncryptValue encrypts the value with a key of the provider.
+func EncryptValue(p KeyProvider, val string) (string, error) {
+	key, header, err := p.NewKey()
+	if err != nil {
+		return "", err
+	}
+	if len(header) > 255 {
+		return "", errors.New("Key header is too long")
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	data := make([]byte, 0, 2+len(header)+len(nonce)+len(val)+aead.Overhead())
+	data = append(data, secretVersion, byte(len(header)))
+	data = append(data, header...)
+	data = append(data, nonce...)
+	data = aead.Seal(data, nonce, []byte(val), data[:2+len(header)])
+	return SecretPrefix + base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// DecryptValue decrypts the encrypted value with a key of the provider.
+func DecryptValue(p KeyProvider, val string) (string, error) {
+	if !IsSecret(val) {
+		return "", errors.New("Value is not encrypted")
+	}
+	data, err := base64.RawURLEncoding.DecodeString(val[len(SecretPrefix):])
+	if err != nil || len(data) < 2 || data[0] != secretVersion || len(data) < 2+int(data[1]) {
+		return "", errors.New("Invalid encrypted value")
+	}
+	header := data[2 : 2+int(data[1])]
+	key, err := p.Key(header)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	rest := data[2+len(header):]
+	if len(rest) < aead.NonceSize() {
+		return "", errors.New("Invalid encrypted value")
+	}
+	plain, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], data[:2+len(header)])
+	if err != nil {
+		return "", errors.New("Cannot decrypt value, wrong key or corrupted data")
+	}
+	return string(plain), nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// DecryptSecrets returns configuration data where encrypted values are
+// decrypted. The nodes remember their encrypted values, WriteConfig,
+// MarshalText, Format and the JSON and YAML exports write them instead of
+// the plain text, see StoredValue.
+func DecryptSecrets(cfg Config, p KeyProvider) (Config, error) {
+	root := cloneConfig(cfg)
+	var walk func(node *config, path string) error
+	walk = func(node *config, path string) error {
+		for _, child := range node.children {
+			if child.children != nil {
+				if err := walk(child, path+child.name+"/"); err != nil {
+					return err
+				}
+			} else if IsSecret(child.value) {
+				val, err := DecryptValue(p, child.value)
+				if err != nil {
+					return errors.New(fmt.Sprintf("%s: %s", child.locate(path+child.name), err.Error()))
+				}
+				child.secret = child.value
+				child.value = val
+			}
+		}
+		return nil
+	}
+	if err := walk(root, ""); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// isSecret reports whether the key holds a secret, encrypted or decrypted.
+func (this *config) isSecret() bool {
+	return this.secret != "" || IsSecret(this.value)
+}
+
+// StoredValue returns the value of the node as it is stored, i.e. the
+// encrypted value of a decrypted secret. Code which writes configuration data
+// should use it instead of Value.
+func StoredValue(cfg Config) string {
+	if node, ok := cfg.(*config); ok {
+		return node.stored()
+	}
+	return cfg.Value()
+}
+
+// MarshalText returns the children of the node in the configuration syntax
+// like WriteConfig, decrypted secrets are encrypted.
+func (this *config) MarshalText() ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w := newWriter(buf, "    ")
+	w.wChildren(this)
+	w.Flush()
+	return buf.Bytes(), nil
+}
+
+func (this *config) stored() string {
+	if this.secret != "" {
+		return this.secret
+	}
+	return this.value
+}
+
+type symmetricKey struct {
+	key []byte
+	id  []byte
+}
+
+// SymmetricKey returns a provider which uses the 256-bit key for all values.
+// The header of values holds a fingerprint of the key, so values encrypted
+// with another key are detected.
+func SymmetricKey(key []byte) (KeyProvider, error) {
+	if len(key) != 32 {
+		return nil, errors.New(fmt.Sprintf("Invalid key length %d, expected 32 bytes", len(key)))
+	}
+	sum := sha256.Sum256(key)
+	return &symmetricKey{key: key, id: append([]byte{'k'}, sum[:4]...)}, nil
+}
+
+func (this *symmetricKey) NewKey() ([]byte, []byte, error) {
+	return this.key, this.id, nil
+}
+
+func (this *symmetricKey) Key(header []byte) ([]byte, error) {
+	if string(header) != string(this.id) {
+		return nil, errors.New("Value was encrypted with another key")
+	}
+	return this.key, nil
+}
+
+// FileKey returns a symmetric provider with the base64 encoded key from the
+// file.
+func FileKey(file string) (KeyProvider, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return parseSymmetricKey(string(data))
+}
+
+// EnvKey returns a symmetric provider with the base64 encoded key from the
+// environment variable.
+func EnvKey(name string) (KeyProvider, error) {
+	val, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Environment variable %s is not set", name))
+	}
+	return parseSymmetricKey(val)
+}
+
+func parseSymmetricKey(str string) (KeyProvider, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(str))
+	if err != nil {
+		return nil, errors.New("Key is not base64 encoded")
+	}
+	return SymmetricKey(key)
+}
+
+// GenerateKey returns a new base64 encoded symmetric key.
+func GenerateKey() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// X25519 identities and recipients are encoded in base64 with these prefixes.
+const (
+	X25519IdentityPrefix  = "x25519-identity:"
+	X25519RecipientPrefix = "x25519:"
+)
+
+type x25519Key struct {
+	identity  *ecdh.PrivateKey
+	recipient *ecdh.PublicKey
+}
+
+// GenerateX25519 returns a new X25519 identity (the private key used for
+// decryption) and its recipient (the public key used for encryption).
+func GenerateX25519() (identity string, recipient string, err error) {
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", "", err
+	}
+	identity = X25519IdentityPrefix + base64.StdEncoding.EncodeToString(key.Bytes())
+	recipient = X25519RecipientPrefix + base64.StdEncoding.EncodeToString(key.PublicKey().Bytes())
+	return identity, recipient, nil
+}
+
+// X25519Identity returns a provider which encrypts and decrypts values with
+// the identity, see GenerateX25519. Lines starting with '#' are ignored, so
+// the identity may be read from a file with comments.
+func X25519Identity(identity string) (KeyProvider, error) {
+	for _, line := range strings.Split(identity, "\n") {
+		if line = strings.TrimSpace(line); line != "" && line[0] != '#' {
+			identity = line
+			break
+		}
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(identity), X25519IdentityPrefix))
+	if err != nil {
+		return nil, errors.New("Identity is not base64 encoded")
+	}
+	key, err := ecdh.X25519().NewPrivateKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return &x25519Key{identity: key, recipient: key.PublicKey()}, nil
+}
+
+// X25519Recipient returns a provider which only encrypts values for the
+// recipient, e.g. on machines which must not read secrets. Every value is
+// encrypted with a key derived by HKDF-SHA256 from an ephemeral key exchange,
+// like in age. X25519 keys require Go 1.20 or later.
+func X25519Recipient(recipient string) (KeyProvider, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(recipient), X25519RecipientPrefix))
+	if err != nil {
+		return nil, errors.New("Recipient is not base64 encoded")
+	}
+	key, err := ecdh.X25519().NewPublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return &x25519Key{recipient: key}, nil
+}
+
+func (this *x25519Key) NewKey() ([]byte, []byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	shared, err := ephemeral.ECDH(this.recipient)
+	if err != nil {
+		return nil, nil, err
+	}
+	header := append([]byte{'x'}, ephemeral.PublicKey().Bytes()...)
+	key, err := this.derive(shared, ephemeral.PublicKey().Bytes())
+	return key, header, err
+}
+
+func (this *x25519Key) Key(header []byte) ([]byte, error) {
+	if this.identity == nil {
+		return nil, errors.New("Recipient cannot decrypt values")
+	}
+	if len(header) != 33 || header[0] != 'x' {
+		return nil, errors.New("Value was not encrypted for an X25519 recipient")
+	}
+	ephemeral, err := ecdh.X25519().NewPublicKey(header[1:])
+	if err != nil {
+		return nil, err
+	}
+	shared, err := this.identity.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	return this.derive(shared, header[1:])
+}
+
+func (this *x25519Key) derive(shared []byte, ephemeral []byte) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeral...), this.recipient.Bytes()...)
+	return hkdf(shared, salt, "config x25519", 32), nil
+}
+
+// hkdf derives a key of the length from the secret by HKDF-SHA256 (RFC 5869),
+// crypto/hkdf is not used so older versions of Go are supported.
+func hkdf(secret []byte, salt []byte, info string, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+	key := make([]byte, 0, length+sha256.Size)
+	block := []byte{}
+	for counter := byte(1); len(key) < length; counter++ {
+		expand := hmac.New(sha256.New, prk)
+		expand.Write(block)
+		expand.Write([]byte(info))
+		expand.Write([]byte{counter})
+		block = expand.Sum(nil)
+		key = append(key, block...)
+	}
+	return key[:length]
+}
@@ -0,0 +1,108 @@
+package config_test
+
+import "bytes"
+import "context"
+import "github.com/twoleds-golang/config"
+import "strings"
+import "testing"
+
+func TestSecrets(t *testing.T) {
+
+	key, _ := config.SymmetricKey(bytes.Repeat([]byte{1}, 32))
+	other, _ := config.SymmetricKey(bytes.Repeat([]byte{2}, 32))
+	identity, recipient, err := config.GenerateX25519()
+	if err != nil {
+		t.Errorf("Cannot generate X25519 keys: %s", err.Error())
+		t.FailNow()
+	}
+	x25519, _ := config.X25519Identity(identity)
+	public, _ := config.X25519Recipient(recipient)
+
+	for name, p := range map[string]config.KeyProvider{"symmetric": key, "x25519": public} {
+		enc, err := config.EncryptValue(p, "p4ssw0rd")
+		if err != nil || !config.IsSecret(enc) {
+			t.Errorf("Cannot encrypt with %s key: %v", name, err)
+			t.Fail()
+			continue
+		}
+		decrypter := p
+		if p == public {
+			decrypter = x25519
+			if _, err := config.DecryptValue(public, enc); err == nil {
+				t.Error("Recipient must not decrypt values")
+				t.Fail()
+			}
+		}
+		if plain, err := config.DecryptValue(decrypter, enc); err != nil || plain != "p4ssw0rd" {
+			t.Errorf("Cannot decrypt with %s key: %v", name, err)
+			t.Fail()
+		}
+		if _, err := config.DecryptValue(other, enc); err == nil {
+			t.Errorf("Expected error for wrong key of %s value", name)
+			t.Fail()
+		}
+	}
+
+	enc, _ := config.EncryptValue(key, "p4ssw0rd")
+	src := "Password \"" + enc + "\"\n" +
+		"Database {\n" +
+		"    Password \"${Password}\"\n" +
+		"}\n"
+
+	cfg, _, err := config.NewLoader(config.BytesSource("app.conf", []byte(src))).Decrypt(key).Load(context.Background())
+	if err != nil {
+		t.Errorf("Cannot load config: %s", err.Error())
+		t.FailNow()
+	}
+
+	if val, _ := cfg.String("Database/Password"); val != "p4ssw0rd" {
+		t.Errorf("Invalid decrypted value: %q", val)
+		t.Fail()
+	}
+
+	text, _ := cfg.(interface{ MarshalText() ([]byte, error) }).MarshalText()
+	buf := new(bytes.Buffer)
+	w := config.NewWriter(buf)
+	config.WriteConfig(w, cfg)
+	w.Flush()
+	json, _ := config.JSONMapping{Raw: true}.Marshal(cfg)
+	if redacted, _ := config.ToJSON(cfg); bytes.Contains(redacted, []byte(enc)) || !bytes.Contains(redacted, []byte("redacted")) {
+		t.Errorf("ToJSON must redact secrets:\n%s", redacted)
+		t.Fail()
+	}
+
+	for name, data := range map[string][]byte{"MarshalText": text, "Writer": buf.Bytes(), "JSON": json} {
+		if bytes.Contains(data, []byte("p4ssw0rd")) || bytes.Count(data, []byte(enc)) != 2 {
+			t.Errorf("%s emits decrypted secrets:\n%s", name, data)
+			t.Fail()
+		}
+	}
+
+	e, _ := config.NewLoader(config.BytesSource("app.conf", []byte(src))).Decrypt(key).Explain(context.Background(), "Database/Password")
+	if e.Steps[len(e.Steps)-1].Kind != config.ExplainDecrypt || strings.Contains(e.String(), "p4ssw0rd") {
+		t.Errorf("Invalid explanation of secret:\n%s", e)
+		t.Fail()
+	}
+
+	embedded := config.NewBuilder().String("Password", enc).String("Url", "db://user:${Password}@host").Config()
+	if decrypted, err := config.DecryptSecrets(embedded, key); err != nil {
+		t.Errorf("Cannot decrypt secrets: %s", err.Error())
+		t.Fail()
+	} else if _, err := config.Interpolate(decrypted); err == nil || !strings.Contains(err.Error(), "cannot be embedded") {
+		t.Error("Expected error for embedded secret")
+		t.Fail()
+	}
+
+	chainSrc := "Password \"" + enc + "\"\nSecret \"${Password}\"\nDatabase {\n  Password \"${Secret}\"\n}\n"
+	if chain, _, err := config.NewLoader(config.BytesSource("app.conf", []byte(chainSrc))).Decrypt(key).Load(context.Background()); err != nil || chain.StringOrDefault("Database/Password", "") != "p4ssw0rd" {
+		t.Errorf("Cannot resolve chain of references to secret: %v", err)
+		t.Fail()
+	}
+
+	embeddedSrc := "Password \"" + enc + "\"\nUrl \"postgres://u:${Password}@h\"\n"
+	if _, _, err := config.NewLoader(config.BytesSource("app.conf", []byte(embeddedSrc))).Decrypt(key).Load(context.Background()); err == nil || !strings.Contains(err.Error(), "cannot be embedded") {
+		t.Errorf("Expected loader error for embedded secret, got %v", err)
+		t.Fail()
+	}
+
+}
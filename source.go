@@ -0,0 +1,361 @@
+package config
+
+import "bytes"
+import "context"
+import "errors"
+import "io/fs"
+import "os"
+import "path"
+import "path/filepath"
+import "sort"
+import "strconv"
+import "strings"
+import "sync"
+import "time"
+
+// Source provides configuration data for a Loader.
+type Source interface {
+	// Name returns a short description of the source for reports and errors.
+	Name() string
+	// Load reads configuration data from the source.
+	Load(ctx context.Context) (Config, error)
+}
+
+// Watcher is implemented by sources which can report changes of their
+// configuration data.
+type Watcher interface {
+	// Watch blocks until the configuration data of the source change after
+	// the last call of Load or Watch, it returns the error of the context
+	// when the context is done.
+	Watch(ctx context.Context) error
+}
+
+// WatchInterval is the interval in which file and directory sources check
+// their files for changes.
+var WatchInterval = time.Second
+
+// ParseByName parses configuration data in the format given by the extension
+// of the name: ".json" for JSON, ".ini" for INI, ".toml" for TOML and the
+// configuration syntax otherwise. The name is the source of positions of
+// nodes and of parse errors.
+func ParseByName(name string, data []byte) (Config, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return withSource(name)(ParseJSON(bytes.NewReader(data)))
+	case ".ini":
+		return withSource(name)(ParseINI(bytes.NewReader(data)))
+	case ".toml":
+		return withSource(name)(ParseTOML(bytes.NewReader(data)))
+	}
+	return withSource(name)(ParseFromBytes(data))
+}
+
+// parseByNameFromFS parses a file of the file system like ParseByName, files
+// in the configuration syntax are parsed by ParseFromFS with includes. It
+// returns names of the read files and of directories searched for include
+// patterns too, also if it fails.
+func parseByNameFromFS(fsys fs.FS, name string) (Config, []string, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".json", ".ini", ".toml":
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, []string{name}, err
+		}
+		cfg, err := ParseByName(name, data)
+		return cfg, []string{name}, err
+	}
+	i := &includer{fsys: fsys}
+	cfg, err := i.root(name)
+	if err != nil {
+		return nil, i.files, err
+	}
+	return cfg, i.files, nil
+}
+
+type bytesSource struct {
+	name string
+	data []byte
+}
+
+// BytesSource returns a source of configuration data in memory, the format
+// is given by the name as in ParseByName.
+func BytesSource(name string, data []byte) Source {
+	return &bytesSource{name: name, data: data}
+}
+
+func (this *bytesSource) Name() string {
+	return this.name
+}
+
+func (this *bytesSource) Load(ctx context.Context) (Config, error) {
+	return ParseByName(this.name, this.data)
+}
+
+type configSource struct {
+	name string
+	cfg  Config
+}
+
+// ConfigSource returns a source of configuration data in memory, e.g.
+// defaults of a schema.
+func ConfigSource(name string, cfg Config) Source {
+	return &configSource{name: name, cfg: cfg}
+}
+
+func (this *configSource) Name() string {
+	return this.name
+}
+
+func (this *configSource) Load(ctx context.Context) (Config, error) {
+	return cloneConfig(this.cfg), nil
+}
+
+type fileSource struct {
+	file  string
+	state fileState
+}
+
+var _ Watcher = new(fileSource)
+
+// FileSource returns a source which reads a file, the format is given by
+// the extension of the file as in ParseByName. Include directives are
+// resolved relative to the directory of the including file and may refer to
+// parent directories by "..". The source watches the file and the included
+// files for changes by checking their sizes and modification times.
+func FileSource(file string) Source {
+	return &fileSource{file: file}
+}
+
+func (this *fileSource) Name() string {
+	return this.file
+}
+
+func (this *fileSource) Load(ctx context.Context) (Config, error) {
+	cfg, files, err := loadFile(this.file)
+	this.state.update(files...)
+	return cfg, err
+}
+
+func (this *fileSource) Watch(ctx context.Context) error {
+	return this.state.watch(ctx, func() []string {
+		if files := this.state.watched(); len(files) > 0 {
+			return files
+		}
+		return []string{this.file}
+	})
+}
+
+// loadFile parses the file with includes, it returns names of the read files
+// and directories, so they can be watched.
+func loadFile(file string) (Config, []string, error) {
+	// Sources are relative to the directory of the file.
+	dir := filepath.Dir(file)
+	cfg, names, err := parseByNameFromFS(dirFS(dir), filepath.Base(file))
+	files := make([]string, 0, len(names))
+	for _, name := range names {
+		files = append(files, filepath.Join(dir, filepath.FromSlash(name)))
+	}
+	if perr, ok := err.(*ParseError); ok && perr.Source != "" {
+		perr.Source = filepath.Join(dir, perr.Source)
+	}
+	if err != nil {
+		return nil, files, err
+	}
+	cfg.(*config).rebase(dir)
+	return cfg, files, nil
+}
+
+type dirSource struct {
+	dir     string
+	pattern string
+	state   fileState
+}
+
+var _ Watcher = new(dirSource)
+
+// DirSource returns a source which reads all files in the directory which
+// match the pattern (e.g. "*.conf") in the order of their names, later
+// files win. The source watches the files for changes.
+func DirSource(dir string, pattern string) Source {
+	return &dirSource{dir: dir, pattern: pattern}
+}
+
+func (this *dirSource) Name() string {
+	return filepath.Join(this.dir, this.pattern)
+}
+
+func (this *dirSource) files() ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(this.dir, this.pattern))
+	sort.Strings(files)
+	return files, err
+}
+
+func (this *dirSource) Load(ctx context.Context) (Config, error) {
+	files, err := this.files()
+	watched := append(files, this.dir)
+	defer func() { this.state.update(watched...) }()
+	if err != nil {
+		return nil, err
+	}
+	cfgs := make([]Config, 0, len(files))
+	for _, file := range files {
+		cfg, included, err := loadFile(file)
+		// Files themselves are watched by the pattern.
+		watched = append(watched, included[1:]...)
+		if err != nil {
+			return nil, err
+		}
+		cfgs = append(cfgs, cfg)
+	}
+	return Merge(newBuilder().Config(), cfgs...), nil
+}
+
+func (this *dirSource) Watch(ctx context.Context) error {
+	return this.state.watch(ctx, func() []string {
+		files, _ := this.files()
+		files = append(files, this.dir)
+		// Files included by the loaded files follow the directory.
+		watched := this.state.watched()
+		for key, file := range watched {
+			if file == this.dir {
+				return append(files, watched[key+1:]...)
+			}
+		}
+		return files
+	})
+}
+
+// fileState remembers names, sizes and modification times of files, it is
+// shared by Load and Watch of file sources.
+type fileState struct {
+	mutex sync.Mutex
+	last  string
+	files []string
+}
+
+// watched returns the files passed to the last update.
+func (this *fileState) watched() []string {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.files
+}
+
+// update stores the current state of the files and reports whether it
+// differs from the previous one.
+func (this *fileState) update(files ...string) bool {
+	buf := new(bytes.Buffer)
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			buf.WriteString(file + " " + info.ModTime().String() + " " + strconv.FormatInt(info.Size(), 10) + "\n")
+		} else {
+			buf.WriteString(file + " missing\n")
+		}
+	}
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	changed := this.last != buf.String()
+	this.last = buf.String()
+	this.files = files
+	return changed
+}
+
+// watch polls the files until their state changes.
+func (this *fileState) watch(ctx context.Context, files func() []string) error {
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if this.update(files()...) {
+				return nil
+			}
+		}
+	}
+}
+
+type fsSource struct {
+	fsys fs.FS
+	name string
+}
+
+// FSSource returns a source which reads a file from the file system, e.g.
+// embed.FS with defaults compiled into the binary. The format is given by
+// the extension of the file as in ParseByName, include directives are
+// resolved as in ParseFromFS.
+func FSSource(fsys fs.FS, name string) Source {
+	return &fsSource{fsys: fsys, name: name}
+}
+
+func (this *fsSource) Name() string {
+	return this.name
+}
+
+func (this *fsSource) Load(ctx context.Context) (Config, error) {
+	cfg, _, err := parseByNameFromFS(this.fsys, this.name)
+	return cfg, err
+}
+
+type envSource struct {
+	prefix string
+	opts   *EnvOptions
+}
+
+// EnvSource returns a source of environment variables with the prefix, see
+// FromEnv.
+func EnvSource(prefix string, opts *EnvOptions) Source {
+	return &envSource{prefix: prefix, opts: opts}
+}
+
+func (this *envSource) Name() string {
+	return "env:" + this.prefix
+}
+
+func (this *envSource) Load(ctx context.Context) (Config, error) {
+	return FromEnv(this.prefix, this.opts), nil
+}
+
+type flagsSource struct {
+	flags Flags
+}
+
+// FlagsSource returns a source of command-line flags bound by BindFlags, the
+// flags have to be parsed before the source is loaded.
+func FlagsSource(flags Flags) Source {
+	return &flagsSource{flags: flags}
+}
+
+func (this *flagsSource) Name() string {
+	return "flags"
+}
+
+func (this *flagsSource) Load(ctx context.Context) (Config, error) {
+	return this.flags.Config(), nil
+}
+
+type optionalSource struct {
+	Source
+}
+
+// OptionalSource returns a source which provides empty configuration data if
+// the file of the wrapped source does not exist.
+func OptionalSource(source Source) Source {
+	return &optionalSource{source}
+}
+
+func (this *optionalSource) Load(ctx context.Context) (Config, error) {
+	cfg, err := this.Source.Load(ctx)
+	if errors.Is(err, fs.ErrNotExist) {
+		return newBuilder().Config(), nil
+	}
+	return cfg, err
+}
+
+func (this *optionalSource) Watch(ctx context.Context) error {
+	if watcher, ok := this.Source.(Watcher); ok {
+		return watcher.Watch(ctx)
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
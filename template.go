@@ -0,0 +1,298 @@
+package config
+
+import "bytes"
+import "context"
+import "errors"
+import "fmt"
+import "os"
+import "reflect"
+import "runtime"
+import "sort"
+import "strings"
+import "text/template"
+import "text/template/parse"
+
+// TemplateData is the data context of configuration templates, see
+// ParseTemplate.
+type TemplateData struct {
+	// Env holds environment variables, e.g. {{ .Env.HOME }}.
+	Env map[string]string
+	// Vars holds variables, e.g. from a file parsed by ParseByName, used as
+	// {{ .Vars.StringOrDefault "Region" "eu" }}.
+	Vars Config
+	// Host holds facts about the host, e.g. {{ .Host.Name }}.
+	Host HostFacts
+}
+
+// HostFacts describes the host which renders a template.
+type HostFacts struct {
+	Name string
+	OS   string
+	Arch string
+	CPUs int
+}
+
+// NewTemplateData returns a data context with the environment of the process,
+// facts about the host and the variables, nil variables are empty.
+func NewTemplateData(vars Config) *TemplateData {
+	data := new(TemplateData)
+	data.Env = make(map[string]string)
+	for _, env := range os.Environ() {
+		if index := strings.IndexByte(env, '='); index > 0 {
+			data.Env[env[:index]] = env[index+1:]
+		}
+	}
+	data.Vars = vars
+	if data.Vars == nil {
+		data.Vars = newBuilder().Config()
+	}
+	data.Host.Name, _ = os.Hostname()
+	data.Host.OS = runtime.GOOS
+	data.Host.Arch = runtime.GOARCH
+	data.Host.CPUs = runtime.NumCPU()
+	return data
+}
+
+// TemplateFuncs returns helper functions of configuration templates:
+//
+//	quote VALUE           the value written as in the configuration syntax,
+//	                      quoted and escaped unless it is safe
+//	indent N TEXT         the text with every line indented by N spaces
+//	default DEFAULT VALUE the value or the default if the value is empty
+//	required MSG VALUE    the value or an error with the message if empty
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"quote":    templateQuote,
+		"indent":   templateIndent,
+		"default":  templateDefault,
+		"required": templateRequired,
+	}
+}
+
+func templateQuote(val interface{}) string {
+	str := fmt.Sprint(val)
+	if str == "" {
+		return `""`
+	}
+	buf := new(bytes.Buffer)
+	w := newWriter(buf, "")
+	w.wValue(str)
+	w.Flush()
+	return buf.String()
+}
+
+func templateIndent(n int, text string) string {
+	pad := strings.Repeat(" ", n)
+	lines := strings.Split(text, "\n")
+	for index, line := range lines {
+		if line != "" {
+			lines[index] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func templateEmpty(val interface{}) bool {
+	if val == nil {
+		return true
+	}
+	v := reflect.ValueOf(val)
+	return !v.IsValid() || v.IsZero() || ((v.Kind() == reflect.Map || v.Kind() == reflect.Slice) && v.Len() == 0)
+}
+
+func templateDefault(def interface{}, val interface{}) interface{} {
+	if templateEmpty(val) {
+		return def
+	}
+	return val
+}
+
+func templateRequired(msg string, val interface{}) (interface{}, error) {
+	if templateEmpty(val) {
+		return nil, errors.New(msg)
+	}
+	return val, nil
+}
+
+// RenderTemplate executes the template in the configuration syntax with the
+// data and the functions of TemplateFuncs. Missing keys of maps are empty.
+func RenderTemplate(name string, src []byte, data interface{}) ([]byte, error) {
+	w, err := renderTemplate(name, src, data)
+	if err != nil {
+		return nil, err
+	}
+	return w.buf.Bytes(), nil
+}
+
+// renderTemplate renders the template to a writer which maps the output back
+// to the template.
+func renderTemplate(name string, src []byte, data interface{}) (*templateWriter, error) {
+	t, err := template.New(name).Funcs(TemplateFuncs()).Option("missingkey=zero").Parse(string(src))
+	if err != nil {
+		return nil, err
+	}
+	w := &templateWriter{src: src, texts: make(map[*byte]int)}
+	for _, tmpl := range t.Templates() {
+		if tmpl.Tree != nil {
+			w.collect(tmpl.Tree.Root)
+		}
+	}
+	if err := t.Execute(w, data); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// templateSegment is a part of the output written at once, by a text of the
+// template at the offset src, or by an action which starts at the offset src.
+type templateSegment struct {
+	out  int
+	src  int
+	text bool
+}
+
+// templateWriter collects the output of a template and remembers where its
+// parts come from. The template writes texts of the template as they are, so
+// they are recognized by their first byte, everything else is written by
+// actions. The output itself is never marked, so data can't fake positions.
+type templateWriter struct {
+	buf      bytes.Buffer
+	src      []byte
+	texts    map[*byte]int
+	segments []templateSegment
+	end      int
+}
+
+// collect remembers offsets of texts of the template in the source.
+func (this *templateWriter) collect(node parse.Node) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			this.collect(child)
+		}
+	case *parse.IfNode:
+		this.collect(node.List)
+		this.collect(node.ElseList)
+	case *parse.RangeNode:
+		this.collect(node.List)
+		this.collect(node.ElseList)
+	case *parse.WithNode:
+		this.collect(node.List)
+		this.collect(node.ElseList)
+	case *parse.TextNode:
+		pos := int(node.Pos)
+		if len(node.Text) > 0 && pos+len(node.Text) <= len(this.src) && bytes.Equal(this.src[pos:pos+len(node.Text)], node.Text) {
+			this.texts[&node.Text[0]] = pos
+		}
+	}
+}
+
+func (this *templateWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if pos, ok := this.texts[&p[0]]; ok && pos+len(p) <= len(this.src) && bytes.Equal(this.src[pos:pos+len(p)], p) {
+		this.segments = append(this.segments, templateSegment{out: this.buf.Len(), src: pos, text: true})
+		this.end = pos + len(p)
+	} else {
+		// The action starts at the first delimiter after the last text.
+		pos := this.end
+		if index := bytes.Index(this.src[pos:], []byte("{{")); index >= 0 {
+			pos = pos + index
+		}
+		this.segments = append(this.segments, templateSegment{out: this.buf.Len(), src: pos})
+	}
+	return this.buf.Write(p)
+}
+
+// mapPosition maps the position in the output to the position in the template
+// of the text or the action which wrote it.
+func (this *templateWriter) mapPosition(pos Position) Position {
+	if pos.Line <= 0 {
+		return pos
+	}
+	out := this.buf.Bytes()
+	offset := 0
+	for line := 1; line < pos.Line; line++ {
+		index := bytes.IndexByte(out[offset:], '\n')
+		if index < 0 {
+			return pos
+		}
+		offset = offset + index + 1
+	}
+	if pos.Column > 1 {
+		offset = offset + pos.Column - 1
+	}
+	if offset >= len(out) {
+		offset = len(out) - 1
+	}
+	index := sort.Search(len(this.segments), func(index int) bool {
+		return this.segments[index].out > offset
+	}) - 1
+	if index < 0 {
+		return pos
+	}
+	src := this.segments[index].src
+	if this.segments[index].text {
+		src = src + offset - this.segments[index].out
+	}
+	pos.Line = bytes.Count(this.src[:src], []byte{'\n'}) + 1
+	pos.Column = src - bytes.LastIndexByte(this.src[:src], '\n')
+	return pos
+}
+
+// ParseTemplate renders the template with the data like RenderTemplate and
+// parses the result. Positions of nodes and parse errors refer to the
+// template, the name of the template is their source. Positions within text
+// of the template are exact, positions within the output of an action are
+// the position of the action.
+func ParseTemplate(name string, src []byte, data interface{}) (Config, error) {
+	w, err := renderTemplate(name, src, data)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := ParseFromBytes(w.buf.Bytes())
+	if perr, ok := err.(*ParseError); ok {
+		pos := w.mapPosition(Position{Line: perr.Line, Column: perr.Column})
+		perr.Line, perr.Column = pos.Line, pos.Column
+	}
+	if err != nil {
+		return withSource(name)(nil, err)
+	}
+	cfg.(*config).mapPositions(w)
+	return withSource(name)(cfg, nil)
+}
+
+// mapPositions maps positions of nodes in the output to the template.
+func (this *config) mapPositions(w *templateWriter) {
+	for _, child := range this.children {
+		child.pos = w.mapPosition(child.pos)
+		child.mapPositions(w)
+	}
+}
+
+type templateSource struct {
+	file string
+	data interface{}
+}
+
+// TemplateSource returns a source which renders and parses the template in
+// the file with the data, see ParseTemplate.
+func TemplateSource(file string, data interface{}) Source {
+	return &templateSource{file: file, data: data}
+}
+
+func (this *templateSource) Name() string {
+	return this.file
+}
+
+func (this *templateSource) Load(ctx context.Context) (Config, error) {
+	src, err := os.ReadFile(this.file)
+	if err != nil {
+		return nil, err
+	}
+	return ParseTemplate(this.file, src, this.data)
+}
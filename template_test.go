@@ -0,0 +1,124 @@
+package config_test
+
+import "github.com/twoleds-golang/config"
+import "strings"
+import "testing"
+
+func TestParseTemplate(t *testing.T) {
+
+	vars, _ := config.ParseFromString("Region eu\nReplicas 3\n")
+	data := &config.TemplateData{
+		Env:  map[string]string{"APP_NAME": "Test \"App\""},
+		Vars: vars,
+		Host: config.HostFacts{Name: "web-1", CPUs: 4},
+	}
+
+	var src = "# {{ .Host.Name }}\n" +
+		"Name {{ quote .Env.APP_NAME }}\n" +
+		"Port {{ .Env.APP_PORT | default 8080 }}\n" +
+		"Empty {{ quote .Env.APP_EMPTY }}\n" +
+		"Section {{ .Vars.StringOrDefault \"Region\" \"us\" | quote }} {\n" +
+		"{{ indent 4 \"Workers 4\\nHost web-1\" }}\n" +
+		"}\n"
+
+	cfg, err := config.ParseTemplate("app.conf.tmpl", []byte(src), data)
+	if err != nil {
+		t.Errorf("Cannot parse template: %s", err.Error())
+		t.FailNow()
+	}
+
+	var tests = map[string]string{
+		"Name":               "Test \"App\"",
+		"Port":               "8080",
+		"Empty":              "",
+		"Section:eu/Workers": "4",
+		"Section:eu/Host":    "web-1",
+	}
+
+	for query, expected := range tests {
+		if val, ok := cfg.String(query); !ok || val != expected {
+			t.Errorf("Invalid value for query '%s': %q", query, val)
+			t.Fail()
+		}
+	}
+
+	if name, _ := cfg.Query("Name"); len(config.Comments(name)) != 1 || config.Comments(name)[0] != "web-1" {
+		t.Error("Invalid comment rendered from host facts")
+		t.Fail()
+	}
+
+	for query, expected := range map[string]string{"Port": "app.conf.tmpl:3:1", "Section:eu/Host": "app.conf.tmpl:6:1"} {
+		if node, _ := cfg.Query(query); config.PositionOf(node).String() != expected {
+			t.Errorf("Invalid position for query '%s': %s", query, config.PositionOf(node))
+			t.Fail()
+		}
+	}
+
+	data.Env["APP_FAKE"] = "\x0042\n"
+	cfg, err = config.ParseTemplate("fake.tmpl", []byte("# {{ .Env.APP_FAKE }}\n  Name ok\n"), data)
+	if err != nil {
+		t.Errorf("Cannot parse template: %s", err.Error())
+		t.FailNow()
+	}
+	if name, _ := cfg.Query("Name"); config.PositionOf(name).String() != "fake.tmpl:2:3" {
+		t.Errorf("Invalid position of key after data with NUL: %s", config.PositionOf(name))
+		t.Fail()
+	}
+
+	src = "{{ if true }}\n" +
+		"Name ok\n" +
+		"{{ end }}\n" +
+		"Broken {{ \"a b\" }}\n"
+	if _, err := config.ParseTemplate("bad.tmpl", []byte(src), data); err == nil || !strings.HasPrefix(err.Error(), "bad.tmpl: ") || err.(*config.ParseError).Line != 4 || err.(*config.ParseError).Column != 8 {
+		t.Errorf("Invalid error position: %v", err)
+		t.Fail()
+	}
+
+	// Trimmed texts keep their positions.
+	src = "{{- /* header */ -}}\n" +
+		"Port 80\n" +
+		"  {{- if true }}\n" +
+		"Host web\n" +
+		"  {{- end }}\n" +
+		"Name ok\n"
+	cfg, err = config.ParseTemplate("trim.tmpl", []byte(src), data)
+	if err != nil {
+		t.Errorf("Cannot parse template: %s", err.Error())
+		t.FailNow()
+	}
+	for query, expected := range map[string]string{"Port": "trim.tmpl:2:1", "Host": "trim.tmpl:4:1", "Name": "trim.tmpl:6:1"} {
+		if node, _ := cfg.Query(query); config.PositionOf(node).String() != expected {
+			t.Errorf("Invalid position for query '%s': %s", query, config.PositionOf(node))
+			t.Fail()
+		}
+	}
+
+	// Texts of defined templates map to the definition.
+	src = "{{ define \"server\" }}\n" +
+		"Server {\n" +
+		"  Workers {{ .Host.CPUs }}\n" +
+		"}\n" +
+		"{{ end }}Name ok\n" +
+		"{{ template \"server\" . }}"
+	cfg, err = config.ParseTemplate("define.tmpl", []byte(src), data)
+	if err != nil {
+		t.Errorf("Cannot parse template: %s", err.Error())
+		t.FailNow()
+	}
+	for query, expected := range map[string]string{"Name": "define.tmpl:5:10", "Server": "define.tmpl:2:1", "Server/Workers": "define.tmpl:3:3"} {
+		if node, _ := cfg.Query(query); config.PositionOf(node).String() != expected {
+			t.Errorf("Invalid position for query '%s': %s", query, config.PositionOf(node))
+			t.Fail()
+		}
+	}
+	if cfg.IntOrDefault("Server/Workers", 0) != 4 {
+		t.Error("Invalid value rendered by defined template")
+		t.Fail()
+	}
+
+	if _, err := config.ParseTemplate("req.tmpl", []byte("Port {{ required \"APP_PORT is required\" .Env.APP_PORT }}\n"), data); err == nil || !strings.Contains(err.Error(), "APP_PORT is required") {
+		t.Error("Expected error for missing required value")
+		t.Fail()
+	}
+
+}
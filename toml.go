@@ -0,0 +1,616 @@
+package config
+
+import "fmt"
+import "io"
+import "io/ioutil"
+import "regexp"
+import "strconv"
+import "strings"
+import "unicode/utf8"
+
+// ParseTOML parses configuration data from a TOML document. Tables and
+// inline tables are mapped to sections, arrays of tables and arrays are
+// mapped to repeated sections and keys. The key DefaultJSONValueKey ("@value"
+// must be quoted in TOML) sets the value of the section of its table.
+//
+// Values are converted to their text: strings are unescaped, integers are
+// converted to decimal numbers and underscores are removed from numbers.
+// Dates and times are kept as written. Comments on their own lines are kept
+// as comments of the following node, arrays of arrays are not supported.
+func ParseTOML(r io.Reader) (Config, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	p := &tomlParser{src: string(data), line: 1, root: newTOMLTable()}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	b := newBuilder()
+	p.root.build(b)
+	for _, raw := range p.pending {
+		tomlDecoration(b, raw)
+	}
+	return b.Config(), nil
+}
+
+type tomlKind uint8
+
+const (
+	tomlScalar tomlKind = iota
+	tomlTable
+	tomlArray
+	tomlTableArray
+)
+
+type tomlValue struct {
+	kind     tomlKind
+	str      string
+	table    *tomlTableNode
+	items    []*tomlValue
+	explicit bool
+	lead     []string
+	pos      Position
+}
+
+type tomlEntry struct {
+	key   string
+	value *tomlValue
+}
+
+type tomlTableNode struct {
+	entries []*tomlEntry
+	index   map[string]*tomlEntry
+}
+
+func newTOMLTable() *tomlTableNode {
+	return &tomlTableNode{index: make(map[string]*tomlEntry)}
+}
+
+func (this *tomlTableNode) add(key string, value *tomlValue) {
+	e := &tomlEntry{key: key, value: value}
+	this.entries = append(this.entries, e)
+	this.index[key] = e
+}
+
+func (this *tomlTableNode) build(b LayoutBuilder) {
+	for _, e := range this.entries {
+		if e.key == DefaultJSONValueKey && e.value.kind == tomlScalar {
+			continue
+		}
+		e.value.build(b, e.key)
+	}
+}
+
+func (this *tomlValue) build(b LayoutBuilder, name string) {
+	for _, raw := range this.lead {
+		tomlDecoration(b, raw)
+	}
+	switch this.kind {
+	case tomlScalar:
+		b.String(name, this.str)
+		b.At(this.pos)
+	case tomlTable:
+		val := ""
+		if e, ok := this.table.index[DefaultJSONValueKey]; ok && e.value.kind == tomlScalar {
+			val = e.value.str
+		}
+		b.Section(name, val)
+		b.At(this.pos)
+		this.table.build(b)
+		b.CloseSection()
+	default:
+		for _, item := range this.items {
+			item.build(b, name)
+		}
+	}
+}
+
+// tomlDecoration adds a raw comment line or a blank line (empty string) to
+// the builder.
+func tomlDecoration(b LayoutBuilder, raw string) {
+	if raw == "" {
+		b.Line()
+	} else {
+		b.Comment(strings.TrimPrefix(strings.TrimPrefix(raw, "#"), " "))
+	}
+}
+
+type tomlParser struct {
+	src     string
+	pos     int
+	line    int
+	root    *tomlTableNode
+	current *tomlTableNode
+	pending []string
+}
+
+var (
+	tomlDate    = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}([Tt ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?([Zz]|[+-]\d{2}:\d{2})?)?$|^\d{2}:\d{2}(:\d{2}(\.\d+)?)?$`)
+	tomlFloat   = regexp.MustCompile(`^[+-]?(0|[1-9](_?\d)*)(\.\d(_?\d)*)?([eE][+-]?\d(_?\d)*)?$|^[+-]?(inf|nan)$`)
+	tomlInteger = regexp.MustCompile(`^0x[0-9A-Fa-f](_?[0-9A-Fa-f])*$|^0o[0-7](_?[0-7])*$|^0b[01](_?[01])*$`)
+)
+
+func (this *tomlParser) error(msg string) error {
+	pos := this.position()
+	return &ParseError{Line: pos.Line, Column: pos.Column, Msg: msg}
+}
+
+// position returns the line and the column of the current byte.
+func (this *tomlParser) position() Position {
+	return Position{Line: this.line, Column: this.pos - strings.LastIndexByte(this.src[:this.pos], '\n')}
+}
+
+func (this *tomlParser) eof() bool {
+	return this.pos >= len(this.src)
+}
+
+func (this *tomlParser) peek() byte {
+	if this.eof() {
+		return 0
+	}
+	return this.src[this.pos]
+}
+
+func (this *tomlParser) skipSpaces() {
+	for !this.eof() && (this.src[this.pos] == ' ' || this.src[this.pos] == '\t') {
+		this.pos++
+	}
+}
+
+// skipLine skips the rest of the line which may contain only white space and
+// a comment, the comment is dropped.
+func (this *tomlParser) skipLine() error {
+	this.skipSpaces()
+	if this.peek() == '#' {
+		for !this.eof() && this.src[this.pos] != '\n' {
+			this.pos++
+		}
+	}
+	if this.peek() == '\r' {
+		this.pos++
+	}
+	if this.eof() {
+		return nil
+	}
+	if this.src[this.pos] != '\n' {
+		return this.error("Wrong character")
+	}
+	this.pos++
+	this.line++
+	return nil
+}
+
+// skipBlank skips white space, new lines and comments inside arrays.
+func (this *tomlParser) skipBlank() {
+	for !this.eof() {
+		switch this.src[this.pos] {
+		case ' ', '\t', '\r':
+			this.pos++
+		case '\n':
+			this.pos++
+			this.line++
+		case '#':
+			for !this.eof() && this.src[this.pos] != '\n' {
+				this.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (this *tomlParser) takePending() []string {
+	lead := this.pending
+	this.pending = nil
+	return lead
+}
+
+func (this *tomlParser) parse() error {
+	this.current = this.root
+	for {
+		this.skipSpaces()
+		if this.eof() {
+			return nil
+		}
+		switch b := this.src[this.pos]; {
+		case b == '\n' || b == '\r':
+			this.pending = append(this.pending, "")
+			if err := this.skipLine(); err != nil {
+				return err
+			}
+			continue
+		case b == '#':
+			end := strings.IndexByte(this.src[this.pos:], '\n')
+			if end < 0 {
+				end = len(this.src) - this.pos
+			}
+			this.pending = append(this.pending, strings.TrimRight(this.src[this.pos:this.pos+end], " \t\r"))
+			this.pos = this.pos + end
+		case b == '[':
+			if err := this.parseHeader(); err != nil {
+				return err
+			}
+		default:
+			if err := this.parseKeyValue(this.current, this.takePending()); err != nil {
+				return err
+			}
+		}
+		if err := this.skipLine(); err != nil {
+			return err
+		}
+	}
+}
+
+func (this *tomlParser) parseHeader() error {
+	at := this.position()
+	array := strings.HasPrefix(this.src[this.pos:], "[[")
+	if array {
+		this.pos = this.pos + 2
+	} else {
+		this.pos = this.pos + 1
+	}
+	this.skipSpaces()
+	keys, err := this.parseKey()
+	if err != nil {
+		return err
+	}
+	closing := "]"
+	if array {
+		closing = "]]"
+	}
+	if !strings.HasPrefix(this.src[this.pos:], closing) {
+		return this.error("Unterminated table header")
+	}
+	this.pos = this.pos + len(closing)
+	table := this.root
+	for _, key := range keys[:len(keys)-1] {
+		if table, err = this.descend(table, key, at); err != nil {
+			return err
+		}
+	}
+	key := keys[len(keys)-1]
+	e, ok := table.index[key]
+	switch {
+	case array && !ok:
+		value := &tomlValue{kind: tomlTableArray, pos: at}
+		table.add(key, value)
+		e = table.index[key]
+		fallthrough
+	case array && e.value.kind == tomlTableArray:
+		item := &tomlValue{kind: tomlTable, table: newTOMLTable(), lead: this.takePending(), pos: at}
+		e.value.items = append(e.value.items, item)
+		this.current = item.table
+	case array:
+		return this.error(fmt.Sprintf("Key '%s' is not an array of tables", key))
+	case !ok:
+		value := &tomlValue{kind: tomlTable, table: newTOMLTable(), explicit: true, lead: this.takePending(), pos: at}
+		table.add(key, value)
+		this.current = value.table
+	case e.value.kind == tomlTable && !e.value.explicit:
+		e.value.explicit = true
+		e.value.pos = at
+		e.value.lead = append(e.value.lead, this.takePending()...)
+		this.current = e.value.table
+	default:
+		return this.error(fmt.Sprintf("Table '%s' is already defined", key))
+	}
+	return nil
+}
+
+// descend returns the table for the key in the specified table, the table is
+// created at the position if it doesn't exist. The last table of an array of
+// tables is used.
+func (this *tomlParser) descend(table *tomlTableNode, key string, pos Position) (*tomlTableNode, error) {
+	e, ok := table.index[key]
+	if !ok {
+		value := &tomlValue{kind: tomlTable, table: newTOMLTable(), pos: pos}
+		table.add(key, value)
+		return value.table, nil
+	}
+	switch {
+	case e.value.kind == tomlTable:
+		return e.value.table, nil
+	case e.value.kind == tomlTableArray && len(e.value.items) > 0:
+		return e.value.items[len(e.value.items)-1].table, nil
+	}
+	return nil, this.error(fmt.Sprintf("Key '%s' is not a table", key))
+}
+
+func (this *tomlParser) parseKeyValue(table *tomlTableNode, lead []string) error {
+	this.skipSpaces()
+	at := this.position()
+	keys, err := this.parseKey()
+	if err != nil {
+		return err
+	}
+	if this.peek() != '=' {
+		return this.error("Expected '='")
+	}
+	this.pos++
+	this.skipSpaces()
+	value, err := this.parseValue()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys[:len(keys)-1] {
+		if table, err = this.descend(table, key, at); err != nil {
+			return err
+		}
+	}
+	key := keys[len(keys)-1]
+	if _, ok := table.index[key]; ok {
+		return this.error(fmt.Sprintf("Key '%s' is already defined", key))
+	}
+	value.lead = lead
+	value.pos = at
+	table.add(key, value)
+	return nil
+}
+
+// parseKey parses a bare, quoted or dotted key followed by optional white
+// space. Every part of the key must be a valid name, see IsValidName.
+func (this *tomlParser) parseKey() ([]string, error) {
+	keys := make([]string, 0, 1)
+	for {
+		this.skipSpaces()
+		at := this.position()
+		var key string
+		switch this.peek() {
+		case '"':
+			str, err := this.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key = str
+		case '\'':
+			str, err := this.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key = str
+		default:
+			start := this.pos
+			for !this.eof() && this.isBareKeyByte(this.src[this.pos]) {
+				this.pos++
+			}
+			if start == this.pos {
+				return nil, this.error("Wrong character")
+			}
+			key = this.src[start:this.pos]
+		}
+		if !IsValidName(key) {
+			return nil, &ParseError{Line: at.Line, Column: at.Column, Msg: fmt.Sprintf("Invalid name '%s'", key)}
+		}
+		keys = append(keys, key)
+		this.skipSpaces()
+		if this.peek() != '.' {
+			return keys, nil
+		}
+		this.pos++
+	}
+}
+
+func (this *tomlParser) isBareKeyByte(b byte) bool {
+	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9') || b == '_' || b == '-'
+}
+
+func (this *tomlParser) parseValue() (*tomlValue, error) {
+	switch this.peek() {
+	case '"':
+		str, err := this.parseBasicString()
+		return &tomlValue{kind: tomlScalar, str: str}, err
+	case '\'':
+		str, err := this.parseLiteralString()
+		return &tomlValue{kind: tomlScalar, str: str}, err
+	case '[':
+		return this.parseArray()
+	case '{':
+		return this.parseInlineTable()
+	}
+	start := this.pos
+	for !this.eof() && strings.IndexByte(" \t\r\n,]}#", this.src[this.pos]) < 0 {
+		this.pos++
+	}
+	// Date and time may be separated by a space.
+	if this.pos-start == 10 && strings.HasPrefix(this.src[this.pos:], " ") && this.pos+1 < len(this.src) {
+		if next := this.src[this.pos+1]; next >= '0' && next <= '9' {
+			this.pos++
+			for !this.eof() && strings.IndexByte(" \t\r\n,]}#", this.src[this.pos]) < 0 {
+				this.pos++
+			}
+		}
+	}
+	text := this.src[start:this.pos]
+	switch {
+	case text == "true" || text == "false" || tomlDate.MatchString(text):
+		return &tomlValue{kind: tomlScalar, str: text}, nil
+	case tomlInteger.MatchString(text):
+		num, err := strconv.ParseInt(strings.Replace(text, "_", "", -1), 0, 64)
+		if err != nil {
+			return nil, this.error(fmt.Sprintf("Invalid integer '%s'", text))
+		}
+		return &tomlValue{kind: tomlScalar, str: strconv.FormatInt(num, 10)}, nil
+	case tomlFloat.MatchString(text):
+		return &tomlValue{kind: tomlScalar, str: strings.Replace(text, "_", "", -1)}, nil
+	}
+	this.pos = start
+	return nil, this.error("Invalid value")
+}
+
+func (this *tomlParser) parseArray() (*tomlValue, error) {
+	this.pos++
+	value := &tomlValue{kind: tomlArray}
+	for {
+		this.skipBlank()
+		if this.peek() == ']' {
+			this.pos++
+			return value, nil
+		}
+		at := this.position()
+		item, err := this.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		item.pos = at
+		if item.kind == tomlArray {
+			return nil, this.error("Nested arrays are not supported")
+		}
+		value.items = append(value.items, item)
+		this.skipBlank()
+		if this.peek() == ',' {
+			this.pos++
+		} else if this.peek() != ']' {
+			return nil, this.error("Expected ',' or ']'")
+		}
+	}
+}
+
+func (this *tomlParser) parseInlineTable() (*tomlValue, error) {
+	this.pos++
+	value := &tomlValue{kind: tomlTable, table: newTOMLTable(), explicit: true}
+	this.skipSpaces()
+	if this.peek() == '}' {
+		this.pos++
+		return value, nil
+	}
+	for {
+		if err := this.parseKeyValue(value.table, nil); err != nil {
+			return nil, err
+		}
+		this.skipSpaces()
+		switch this.peek() {
+		case ',':
+			this.pos++
+		case '}':
+			this.pos++
+			return value, nil
+		default:
+			return nil, this.error("Expected ',' or '}'")
+		}
+	}
+}
+
+func (this *tomlParser) parseLiteralString() (string, error) {
+	if strings.HasPrefix(this.src[this.pos:], "'''") {
+		this.pos = this.pos + 3
+		this.skipNewLine()
+		end := strings.Index(this.src[this.pos:], "'''")
+		if end < 0 {
+			this.pos = len(this.src)
+			return "", this.error("Unterminated string")
+		}
+		// Up to two quotes may directly precede the closing delimiter.
+		for extra := 0; extra < 2 && this.pos+end+3 < len(this.src) && this.src[this.pos+end+3] == '\''; extra++ {
+			end++
+		}
+		str := this.src[this.pos : this.pos+end]
+		this.line = this.line + strings.Count(str, "\n")
+		this.pos = this.pos + end + 3
+		return str, nil
+	}
+	this.pos++
+	end := strings.IndexAny(this.src[this.pos:], "'\n")
+	if end < 0 || this.src[this.pos+end] != '\'' {
+		return "", this.error("Unterminated string")
+	}
+	str := this.src[this.pos : this.pos+end]
+	this.pos = this.pos + end + 1
+	return str, nil
+}
+
+func (this *tomlParser) parseBasicString() (string, error) {
+	multiline := strings.HasPrefix(this.src[this.pos:], `"""`)
+	if multiline {
+		this.pos = this.pos + 3
+		this.skipNewLine()
+	} else {
+		this.pos++
+	}
+	buf := make([]byte, 0, 32)
+	for {
+		if this.eof() || (!multiline && this.src[this.pos] == '\n') {
+			return "", this.error("Unterminated string")
+		}
+		b := this.src[this.pos]
+		switch {
+		case b == '"' && !multiline:
+			this.pos++
+			return string(buf), nil
+		case b == '"' && strings.HasPrefix(this.src[this.pos:], `"""`) && !strings.HasPrefix(this.src[this.pos+1:], `"""`):
+			this.pos = this.pos + 3
+			return string(buf), nil
+		case b == '\\':
+			this.pos++
+			if multiline && this.isLineEndingBackslash() {
+				this.skipBlank()
+				continue
+			}
+			var err error
+			if buf, err = this.parseEscape(buf); err != nil {
+				return "", err
+			}
+			continue
+		case b == '\n':
+			this.line++
+		}
+		buf = append(buf, b)
+		this.pos++
+	}
+}
+
+// isLineEndingBackslash reports whether only white space follows the
+// backslash on the line.
+func (this *tomlParser) isLineEndingBackslash() bool {
+	rest := strings.TrimLeft(this.src[this.pos:], " \t\r")
+	return strings.HasPrefix(rest, "\n")
+}
+
+func (this *tomlParser) parseEscape(buf []byte) ([]byte, error) {
+	if this.eof() {
+		return nil, this.error("Unterminated string")
+	}
+	b := this.src[this.pos]
+	this.pos++
+	switch b {
+	case 'b':
+		return append(buf, '\b'), nil
+	case 't':
+		return append(buf, '\t'), nil
+	case 'n':
+		return append(buf, '\n'), nil
+	case 'f':
+		return append(buf, '\f'), nil
+	case 'r':
+		return append(buf, '\r'), nil
+	case 'e':
+		return append(buf, 0x1b), nil
+	case '"', '\\':
+		return append(buf, b), nil
+	case 'x', 'u', 'U':
+		size := map[byte]int{'x': 2, 'u': 4, 'U': 8}[b]
+		if this.pos+size > len(this.src) {
+			return nil, this.error("Invalid escape sequence")
+		}
+		code, err := strconv.ParseUint(this.src[this.pos:this.pos+size], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(code)) {
+			return nil, this.error("Invalid escape sequence")
+		}
+		this.pos = this.pos + size
+		return append(buf, string(rune(code))...), nil
+	}
+	this.pos--
+	return nil, this.error("Invalid escape sequence")
+}
+
+// skipNewLine skips a new line which directly follows an opening delimiter of
+// a multi-line string.
+func (this *tomlParser) skipNewLine() {
+	if strings.HasPrefix(this.src[this.pos:], "\r\n") {
+		this.pos = this.pos + 2
+		this.line++
+	} else if strings.HasPrefix(this.src[this.pos:], "\n") {
+		this.pos++
+		this.line++
+	}
+}
@@ -0,0 +1,126 @@
+package config_test
+
+import "github.com/twoleds-golang/config"
+import "strings"
+import "testing"
+
+func TestParseTOML(t *testing.T) {
+
+	var src = "# Application\n" +
+		"Name = \"Test\\tString\"\n" +
+		"Path = 'C:\\temp'\n" +
+		"Count = 1_000\n" +
+		"Mask = 0xff\n" +
+		"Ratio = 1.5e3\n" +
+		"Enabled = true\n" +
+		"Date = 1979-05-27 07:32:00Z\n" +
+		"Hosts = [\n" +
+		"    \"a\", # first\n" +
+		"    \"b\",\n" +
+		"]\n" +
+		"Point = { X = 1, Y = 2 }\n" +
+		"Text = \"\"\"\n" +
+		"line one\n" +
+		"line two\"\"\"\n" +
+		"\n" +
+		"[Server.Limits]\n" +
+		"Max = 10\n" +
+		"\n" +
+		"# First section\n" +
+		"[[Section]]\n" +
+		"\"@value\" = \"One\"\n" +
+		"IntValue = 123\n" +
+		"\n" +
+		"[[Section]]\n" +
+		"\"@value\" = \"Two\"\n" +
+		"Nested.IntValue = 456\n"
+
+	cfg, err := config.ParseTOML(strings.NewReader(src))
+	if err != nil {
+		t.Errorf("Cannot parse TOML: %s", err.Error())
+		t.FailNow()
+	}
+
+	var tests = map[string]string{
+		"Name":                        "Test\tString",
+		"Path":                        "C:\\temp",
+		"Count":                       "1000",
+		"Mask":                        "255",
+		"Ratio":                       "1.5e3",
+		"Enabled":                     "true",
+		"Date":                        "1979-05-27 07:32:00Z",
+		"Point/Y":                     "2",
+		"Text":                        "line one\nline two",
+		"Server/Limits/Max":           "10",
+		"Section:One/IntValue":        "123",
+		"Section:Two/Nested/IntValue": "456",
+	}
+
+	for query, expected := range tests {
+		if val, ok := cfg.String(query); !ok || val != expected {
+			t.Errorf("Invalid value for query '%s': %q", query, val)
+			t.Fail()
+		}
+	}
+
+	if hosts := cfg.QueryAll("Hosts"); len(hosts) != 2 || hosts[1].Value() != "b" {
+		t.Error("Invalid values for query 'Hosts'")
+		t.Fail()
+	}
+
+	if section, _ := cfg.Query("Section:One"); len(config.Comments(section)) != 1 || config.Comments(section)[0] != "First section" {
+		t.Error("Invalid comments for query 'Section:One'")
+		t.Fail()
+	}
+
+	var positions = map[string]string{
+		"Name":                 "2:1",
+		"Point/Y":              "13:18",
+		"Server/Limits":        "18:1",
+		"Server/Limits/Max":    "19:1",
+		"Section:Two":          "26:1",
+		"Section:Two/Nested":   "28:1",
+		"Section:One/IntValue": "24:1",
+	}
+
+	for query, expected := range positions {
+		if node, ok := cfg.Query(query); !ok || config.PositionOf(node).String() != expected {
+			t.Errorf("Invalid position for query '%s': %v", query, config.PositionOf(node))
+			t.Fail()
+		}
+	}
+	if hosts := cfg.QueryAll("Hosts"); config.PositionOf(hosts[1]).String() != "11:5" {
+		t.Errorf("Invalid position of array item: %v", config.PositionOf(hosts[1]))
+		t.Fail()
+	}
+
+	var invalid = []string{
+		"Name = \"unterminated\n",
+		"Name = 1\nName = 2\n",
+		"[Table]\n[Table]\n",
+		"Name = value\n",
+		"Name = [[1]]\n",
+	}
+
+	for _, src := range invalid {
+		if _, err := config.ParseTOML(strings.NewReader(src)); err == nil {
+			t.Errorf("Expected error for %q", src)
+			t.Fail()
+		}
+	}
+
+	var names = map[string]string{
+		"[server-a]\n":             "Invalid name 'server-a' on line 1 at column 2",
+		"[Server]\nmax-conn = 1\n": "Invalid name 'max-conn' on line 2 at column 1",
+		"\"a b\" = 1\n":            "Invalid name 'a b' on line 1 at column 1",
+		"Server.\"8080\" = 1\n":    "Invalid name '8080' on line 1 at column 8",
+	}
+
+	for src, msg := range names {
+		if _, err := config.ParseTOML(strings.NewReader(src)); err == nil || err.Error() != msg {
+			t.Errorf("Expected error %q for %q, got %v", msg, src, err)
+			t.Fail()
+		}
+	}
+
+}
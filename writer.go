@@ -20,12 +20,24 @@ type Writer interface {
 
 type writer struct {
 	writer *bufio.Writer
+	indent string
 	level  int
 }
 
 func NewWriter(wr io.Writer) Writer {
+	return newWriter(wr, "    ")
+}
+
+// NewWriterIndent returns a writer which indents nested sections with the
+// specified string instead of four spaces, e.g. with a tab.
+func NewWriterIndent(wr io.Writer, indent string) Writer {
+	return newWriter(wr, indent)
+}
+
+func newWriter(wr io.Writer, indent string) *writer {
 	o := new(writer)
 	o.writer = bufio.NewWriter(wr)
+	o.indent = indent
 	o.level = 0
 	return o
 }
@@ -39,7 +51,7 @@ func (this *writer) wComment() *writer {
 
 func (this *writer) wIndent() *writer {
 	for i := 0; i < this.level; i++ {
-		this.writer.WriteString("    ")
+		this.writer.WriteString(this.indent)
 	}
 	return this
 }
@@ -64,6 +76,21 @@ func (this *writer) wName(name string) *writer {
 	return this
 }
 
+func (this *writer) wNameValue(name string, value string) *writer {
+	this.wName(name)
+	if value != "" {
+		this.wSpace().wValue(value)
+	}
+	return this
+}
+
+func (this *writer) wPad(n int) *writer {
+	for i := 0; i < n; i++ {
+		this.writer.WriteByte(' ')
+	}
+	return this
+}
+
 func (this *writer) wSectionEnd() *writer {
 	this.writer.WriteByte('}')
 	return this
@@ -93,13 +120,25 @@ func (this *writer) wValue(value string) *writer {
 	return this
 }
 
+// wQuery writes a query, it is quoted only if necessary.
+func (this *writer) wQuery(query string) *writer {
+	for _, r := range query {
+		if !this.isValueSafe(string(r)) && r != ':' && r != '/' && r != '*' && r != '@' {
+			return this.wValueEscaped(query)
+		}
+	}
+	return this.wText(query)
+}
+
 func (this *writer) wValueEscaped(value string) *writer {
 	this.writer.WriteByte('"')
-	for _, r := range value {
-		if r == '"' {
+	// Bytes are copied as they are, so values which are not valid UTF-8
+	// are kept too.
+	for i := 0; i < len(value); i++ {
+		if value[i] == '"' || value[i] == '\\' {
 			this.writer.WriteByte('\\')
 		}
-		this.writer.WriteRune(r)
+		this.writer.WriteByte(value[i])
 	}
 	this.writer.WriteByte('"')
 	return this
@@ -117,9 +156,7 @@ func (this *writer) isValueSafe(value string) bool {
 func (this *writer) Bool(name string, val bool) Writer {
 	return this.
 		wIndent().
-		wName(name).
-		wSpace().
-		wValue(strconv.FormatBool(val)).
+		wNameValue(name, strconv.FormatBool(val)).
 		wLine()
 }
 
@@ -150,9 +187,7 @@ func (this *writer) Comment(comment string) Writer {
 func (this *writer) Float(name string, val float64) Writer {
 	return this.
 		wIndent().
-		wName(name).
-		wSpace().
-		wValue(strconv.FormatFloat(val, 'g', -1, 64)).
+		wNameValue(name, strconv.FormatFloat(val, 'g', -1, 64)).
 		wLine()
 }
 
@@ -163,9 +198,7 @@ func (this *writer) Flush() {
 func (this *writer) Int(name string, val int64) Writer {
 	return this.
 		wIndent().
-		wName(name).
-		wSpace().
-		wValue(strconv.FormatInt(val, 10)).
+		wNameValue(name, strconv.FormatInt(val, 10)).
 		wLine()
 }
 
@@ -177,9 +210,7 @@ func (this *writer) Section(name string, val string) Writer {
 	return this.
 		wIndent().
 		wLevelUp().
-		wName(name).
-		wSpace().
-		wValue(val).
+		wNameValue(name, val).
 		wSpace().
 		wSectionStart().
 		wLine()
@@ -188,8 +219,33 @@ func (this *writer) Section(name string, val string) Writer {
 func (this *writer) String(name string, val string) Writer {
 	return this.
 		wIndent().
-		wName(name).
-		wSpace().
-		wValue(val).
+		wNameValue(name, val).
 		wLine()
 }
+
+// WriteConfig writes all children of the configuration data with their
+// comments to the writer, decrypted secrets are written encrypted. Writers of
+// NewWriter write them in the canonical format, see Format, other writers get
+// the keys, sections and comments by their methods.
+func WriteConfig(w Writer, cfg Config) {
+	if wr, ok := w.(*writer); ok {
+		wr.wChildren(cloneConfig(cfg))
+		return
+	}
+	writeChildren(w, cloneConfig(cfg))
+}
+
+func writeChildren(w Writer, cfg *config) {
+	for _, child := range cfg.children {
+		if comments := child.Comments(); len(comments) > 0 {
+			w.Comment(strings.Join(comments, "\n"))
+		}
+		if child.children != nil {
+			w.Section(child.name, child.value)
+			writeChildren(w, child)
+			w.CloseSection()
+		} else {
+			w.String(child.name, child.stored())
+		}
+	}
+}
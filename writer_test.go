@@ -19,49 +19,7 @@ func TestWriter(t *testing.T) {
 	defer os.Remove(f.Name())
 
 	b := config.NewWriter(f)
-
-	b.Bool("BoolValue", true)
-	b.Float("FloatValue", 3.14)
-	b.Int("IntValue", 123)
-	b.String("StringValue", "Test String 123")
-	b.Line()
-
-	b.Section("Section", "One")
-	b.Bool("BoolValue", true)
-	b.Float("FloatValue", 2.745)
-	b.Int("IntValue", 124)
-	b.String("StringValue", "Test String - First Section")
-	b.CloseSection()
-	b.Line()
-
-	b.Section("Nested", "One")
-	b.Section("Nested", "Two")
-	b.Section("Nested", "Three")
-	b.Bool("BoolValue", false)
-	b.Float("FloatValue", -3.14)
-	b.Int("IntValue", -123456)
-	b.String("StringValue", "Test String - Nested Section")
-	b.CloseSection()
-	b.CloseSection()
-	b.CloseSection()
-	b.Line()
-
-	b.Section("Section", "Two")
-	b.Bool("BoolValue", false)
-	b.Float("FloatValue", -2.745)
-	b.Int("IntValue", 123456)
-	b.String("StringValue", "Test String 123")
-	b.CloseSection()
-	b.Line()
-
-	b.Section("Section", "Three")
-	b.Bool("BoolValue", true)
-	b.Float("FloatValue", 3.14)
-	b.Int("IntValue", -150)
-	b.String("StringValue", "Test String - Last Section")
-	b.CloseSection()
-	b.Line()
-
+	writeTestConfig(b)
 	b.Flush()
 	f.Seek(0, 0)
 
@@ -187,3 +145,48 @@ func TestWriter(t *testing.T) {
 	}
 
 }
+
+// writeTestConfig writes the configuration data checked by TestWriter.
+func writeTestConfig(b config.Writer) {
+	b.Bool("BoolValue", true)
+	b.Float("FloatValue", 3.14)
+	b.Int("IntValue", 123)
+	b.String("StringValue", "Test String 123")
+	b.Line()
+
+	b.Section("Section", "One")
+	b.Bool("BoolValue", true)
+	b.Float("FloatValue", 2.745)
+	b.Int("IntValue", 124)
+	b.String("StringValue", "Test String - First Section")
+	b.CloseSection()
+	b.Line()
+
+	b.Section("Nested", "One")
+	b.Section("Nested", "Two")
+	b.Section("Nested", "Three")
+	b.Bool("BoolValue", false)
+	b.Float("FloatValue", -3.14)
+	b.Int("IntValue", -123456)
+	b.String("StringValue", "Test String - Nested Section")
+	b.CloseSection()
+	b.CloseSection()
+	b.CloseSection()
+	b.Line()
+
+	b.Section("Section", "Two")
+	b.Bool("BoolValue", false)
+	b.Float("FloatValue", -2.745)
+	b.Int("IntValue", 123456)
+	b.String("StringValue", "Test String 123")
+	b.CloseSection()
+	b.Line()
+
+	b.Section("Section", "Three")
+	b.Bool("BoolValue", true)
+	b.Float("FloatValue", 3.14)
+	b.Int("IntValue", -150)
+	b.String("StringValue", "Test String - Last Section")
+	b.CloseSection()
+	b.Line()
+}
@@ -0,0 +1,758 @@
+package yaml
+
+import "bufio"
+import "fmt"
+import "io"
+import "strconv"
+import "strings"
+import "unicode/utf8"
+
+type nodeKind uint8
+
+const (
+	scalarNode nodeKind = iota
+	mappingNode
+	sequenceNode
+)
+
+// node is a node of a parsed YAML document. Aliases are expanded, so a node
+// may be shared by several parents.
+type node struct {
+	kind    nodeKind
+	value   string
+	null    bool
+	entries []*entry
+	line    int
+}
+
+// entry is an entry of a mapping or an item of a sequence, comments hold
+// text of comment lines which precede the entry.
+type entry struct {
+	key      string
+	value    *node
+	comments []string
+	line     int
+}
+
+type line struct {
+	num    int
+	indent int
+	text   string
+}
+
+type parser struct {
+	lines    []line
+	pos      int
+	anchors  map[string]*node
+	comments []string
+	depth    int
+}
+
+// Error describes a problem found in a YAML document.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (this *Error) Error() string {
+	return fmt.Sprintf("yaml: line %d: %s", this.Line, this.Msg)
+}
+
+func newParser(r io.Reader) (*parser, error) {
+	p := new(parser)
+	p.anchors = make(map[string]*node)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	num := 0
+	for scanner.Scan() {
+		num++
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		if num == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		indent := len(text) - len(strings.TrimLeft(text, " "))
+		if strings.HasPrefix(text[indent:], "\t") {
+			return nil, &Error{num, "tabs are not allowed in indentation"}
+		}
+		if strings.HasPrefix(text, "%") {
+			continue
+		}
+		if text == "---" || strings.HasPrefix(text, "--- ") {
+			if len(p.lines) > 0 && p.hasContent() {
+				break
+			}
+			if rest := strings.TrimSpace(text[3:]); rest != "" && !strings.HasPrefix(rest, "#") {
+				return nil, &Error{num, "content after the document marker is not supported"}
+			}
+			continue
+		}
+		if text == "..." {
+			break
+		}
+		p.lines = append(p.lines, line{num: num, indent: indent, text: text[indent:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (this *parser) hasContent() bool {
+	for _, l := range this.lines {
+		if l.text != "" && !strings.HasPrefix(l.text, "#") {
+			return true
+		}
+	}
+	return false
+}
+
+func (this *parser) error(msg string) error {
+	num := 0
+	if this.pos < len(this.lines) {
+		num = this.lines[this.pos].num
+	} else if len(this.lines) > 0 {
+		num = this.lines[len(this.lines)-1].num
+	}
+	return &Error{num, msg}
+}
+
+// enter increases the nesting of parsed collections, which is limited by
+// maxDepth, so hostile documents can't exhaust the stack. Callers decrease
+// the depth when the collection is parsed.
+func (this *parser) enter(num int) error {
+	this.depth++
+	if this.depth > maxDepth {
+		return &Error{num, "document is nested too deep"}
+	}
+	return nil
+}
+
+// next skips blank and comment lines, comments are collected, and returns
+// the next line with content.
+func (this *parser) next() (line, bool) {
+	for this.pos < len(this.lines) {
+		l := this.lines[this.pos]
+		if l.text == "" {
+			this.pos++
+			continue
+		}
+		if strings.HasPrefix(l.text, "#") {
+			this.comments = append(this.comments, strings.TrimPrefix(strings.TrimPrefix(l.text, "#"), " "))
+			this.pos++
+			continue
+		}
+		return l, true
+	}
+	return line{}, false
+}
+
+func (this *parser) takeComments() []string {
+	comments := this.comments
+	this.comments = nil
+	return comments
+}
+
+// parseDocument parses the whole document, an empty document is a null.
+func (this *parser) parseDocument() (*node, error) {
+	n, err := this.parseBlock(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := this.next(); ok {
+		return nil, this.error("unexpected content")
+	}
+	if n == nil {
+		n = &node{kind: scalarNode, null: true}
+	}
+	return n, nil
+}
+
+// parseBlock parses a block node indented at least by the specified number
+// of spaces. It returns nil if there is no such node.
+func (this *parser) parseBlock(indent int) (*node, error) {
+	l, ok := this.next()
+	if !ok || l.indent < indent {
+		return nil, nil
+	}
+	if l.text == "-" || strings.HasPrefix(l.text, "- ") {
+		return this.parseSequence(l.indent)
+	}
+	if _, _, ok := this.splitKey(l.text); ok {
+		return this.parseMapping(l.indent)
+	}
+	this.pos++
+	text := l.text
+	for {
+		next, ok := this.next()
+		if !ok || next.indent < indent {
+			break
+		}
+		if _, _, isKey := this.splitKey(next.text); isKey || strings.HasPrefix(next.text, "- ") {
+			break
+		}
+		text = text + "\n" + next.text
+		this.pos++
+	}
+	return this.parseInline(strings.Replace(text, "\n", " ", -1), l.num)
+}
+
+func (this *parser) parseMapping(indent int) (*node, error) {
+	n := &node{kind: mappingNode, line: this.lines[this.pos].num}
+	defer func() { this.depth-- }()
+	if err := this.enter(n.line); err != nil {
+		return nil, err
+	}
+	merged := make([]*node, 0)
+	for {
+		l, ok := this.next()
+		if !ok || l.indent < indent {
+			// Merged keys are added last, explicit keys take precedence.
+			for _, value := range merged {
+				if err := this.merge(n, value); err != nil {
+					return nil, err
+				}
+			}
+			return n, nil
+		}
+		if l.indent > indent {
+			return nil, this.error("unexpected indentation")
+		}
+		key, rest, ok := this.splitKey(l.text)
+		if !ok {
+			return nil, this.error("expected a mapping key")
+		}
+		e := &entry{key: key, comments: this.takeComments(), line: l.num}
+		this.pos++
+		value, err := this.parseValue(rest, indent, l.num, true)
+		if err != nil {
+			return nil, err
+		}
+		if key == "<<" {
+			merged = append(merged, value)
+			continue
+		}
+		e.value = value
+		n.entries = append(n.entries, e)
+	}
+}
+
+func (this *parser) parseSequence(indent int) (*node, error) {
+	n := &node{kind: sequenceNode, line: this.lines[this.pos].num}
+	defer func() { this.depth-- }()
+	if err := this.enter(n.line); err != nil {
+		return nil, err
+	}
+	for {
+		l, ok := this.next()
+		if !ok || l.indent < indent {
+			return n, nil
+		}
+		if l.indent > indent {
+			return nil, this.error("unexpected indentation")
+		}
+		if l.text != "-" && !strings.HasPrefix(l.text, "- ") {
+			if _, _, isKey := this.splitKey(l.text); isKey {
+				return n, nil
+			}
+			return nil, this.error("expected a sequence item")
+		}
+		e := &entry{comments: this.takeComments(), line: l.num}
+		rest := strings.TrimLeft(strings.TrimPrefix(l.text, "-"), " ")
+		if rest == "" || strings.HasPrefix(rest, "#") {
+			this.pos++
+			value, err := this.parseValue("", indent, l.num, false)
+			if err != nil {
+				return nil, err
+			}
+			e.value = value
+		} else if _, _, isKey := this.splitKey(rest); isKey || rest == "-" || strings.HasPrefix(rest, "- ") {
+			// A compact nested collection, parse the rest of the line as
+			// if it was a line on its own.
+			this.lines[this.pos] = line{num: l.num, indent: indent + len(l.text) - len(rest), text: rest}
+			value, err := this.parseBlock(indent + 1)
+			if err != nil {
+				return nil, err
+			}
+			e.value = value
+		} else {
+			this.pos++
+			value, err := this.parseValue(rest, indent, l.num, false)
+			if err != nil {
+				return nil, err
+			}
+			e.value = value
+		}
+		n.entries = append(n.entries, e)
+	}
+}
+
+// parseValue parses a value which follows a mapping key or a sequence
+// indicator. An empty rest means that the value is a nested block.
+func (this *parser) parseValue(rest string, indent int, num int, inMapping bool) (*node, error) {
+	rest = this.stripComment(rest)
+	anchor := ""
+	for {
+		if strings.HasPrefix(rest, "&") {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			anchor = rest[1:end]
+			rest = strings.TrimLeft(rest[end:], " \t")
+		} else if strings.HasPrefix(rest, "!") {
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			rest = strings.TrimLeft(rest[end:], " \t")
+		} else {
+			break
+		}
+	}
+
+	var n *node
+	var err error
+	switch {
+	case strings.HasPrefix(rest, "*"):
+		alias := rest[1:]
+		if n = this.anchors[alias]; n == nil {
+			return nil, &Error{num, fmt.Sprintf("unknown alias %s", alias)}
+		}
+	case strings.HasPrefix(rest, "|") || strings.HasPrefix(rest, ">"):
+		n, err = this.parseBlockScalar(rest, indent, num)
+	case rest == "":
+		n, err = this.parseBlock(indent + 1)
+		if err == nil && n == nil && inMapping {
+			// A sequence may be indented at the same level as its key.
+			if l, ok := this.next(); ok && l.indent == indent && (l.text == "-" || strings.HasPrefix(l.text, "- ")) {
+				n, err = this.parseSequence(indent)
+			}
+		}
+		if err == nil && n == nil {
+			n = &node{kind: scalarNode, null: true, line: num}
+		}
+	case strings.HasPrefix(rest, "[") || strings.HasPrefix(rest, "{"):
+		for !this.isBalanced(rest) && this.pos < len(this.lines) {
+			rest = rest + " " + this.stripComment(this.lines[this.pos].text)
+			this.pos++
+		}
+		n, err = this.parseInline(rest, num)
+	default:
+		n, err = this.parseInline(rest, num)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if anchor != "" {
+		this.anchors[anchor] = n
+	}
+	return n, nil
+}
+
+// parseBlockScalar parses a literal or folded block scalar.
+func (this *parser) parseBlockScalar(header string, indent int, num int) (*node, error) {
+	folded := header[0] == '>'
+	chomp := byte(0)
+	contentIndent := 0
+	for _, c := range header[1:] {
+		switch {
+		case c == '-' || c == '+':
+			chomp = byte(c)
+		case c >= '1' && c <= '9':
+			contentIndent = indent + int(c-'0')
+		default:
+			return nil, &Error{num, "invalid block scalar header"}
+		}
+	}
+
+	lines := make([]string, 0, 8)
+	for this.pos < len(this.lines) {
+		l := this.lines[this.pos]
+		if l.text != "" {
+			if contentIndent == 0 {
+				if l.indent <= indent {
+					break
+				}
+				contentIndent = l.indent
+			}
+			if l.indent < contentIndent {
+				break
+			}
+			lines = append(lines, strings.Repeat(" ", l.indent-contentIndent)+l.text)
+		} else {
+			lines = append(lines, "")
+		}
+		this.pos++
+	}
+
+	trailing := 0
+	for trailing < len(lines) && lines[len(lines)-1-trailing] == "" {
+		trailing++
+	}
+	lines = lines[:len(lines)-trailing]
+
+	text := ""
+	if folded {
+		for key, l := range lines {
+			if key > 0 {
+				if l == "" || lines[key-1] == "" || strings.HasPrefix(l, " ") {
+					text = text + "\n"
+				} else {
+					text = text + " "
+				}
+			}
+			text = text + l
+		}
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+
+	switch {
+	case chomp == '+':
+		text = text + strings.Repeat("\n", trailing+1)
+	case chomp == 0 && len(lines) > 0:
+		text = text + "\n"
+	}
+	return &node{kind: scalarNode, value: text, line: num}, nil
+}
+
+// merge adds entries of the merged mapping (or mappings) to the mapping
+// unless the mapping already contains the key.
+func (this *parser) merge(n *node, value *node) error {
+	sources := []*node{value}
+	if value.kind == sequenceNode {
+		sources = sources[:0]
+		for _, e := range value.entries {
+			sources = append(sources, e.value)
+		}
+	}
+	for _, source := range sources {
+		if source.kind != mappingNode {
+			return this.error("merged value is not a mapping")
+		}
+		for _, e := range source.entries {
+			found := false
+			for _, existing := range n.entries {
+				found = found || existing.key == e.key
+			}
+			if !found {
+				n.entries = append(n.entries, e)
+			}
+		}
+	}
+	return nil
+}
+
+// splitKey splits a line with a mapping entry to the key and the rest.
+func (this *parser) splitKey(text string) (key string, rest string, ok bool) {
+	if strings.HasPrefix(text, "\"") || strings.HasPrefix(text, "'") {
+		end := this.quotedEnd(text)
+		if end < 0 || end >= len(text) || text[end] != ':' || (end+1 < len(text) && text[end+1] != ' ') {
+			return "", "", false
+		}
+		n, err := this.parseScalar(text[:end], 0)
+		if err != nil {
+			return "", "", false
+		}
+		return n.value, strings.TrimLeft(text[end+1:], " "), true
+	}
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") || strings.HasPrefix(text, "#") {
+		return "", "", false
+	}
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimRight(text[:i], " "), strings.TrimLeft(text[i+1:], " "), true
+		}
+		if text[i] == '#' && i > 0 && text[i-1] == ' ' {
+			break
+		}
+	}
+	return "", "", false
+}
+
+// quotedEnd returns the index just after the closing quote of a quoted
+// scalar at the beginning of the text.
+func (this *parser) quotedEnd(text string) int {
+	quote := text[0]
+	for i := 1; i < len(text); i++ {
+		if quote == '"' && text[i] == '\\' {
+			i++
+		} else if text[i] == quote {
+			if quote == '\'' && i+1 < len(text) && text[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// stripComment removes a comment from the end of the text.
+func (this *parser) stripComment(text string) string {
+	quote := byte(0)
+	for i := 0; i < len(text); i++ {
+		switch {
+		case quote == 0 && (text[i] == '"' || text[i] == '\''):
+			quote = text[i]
+		case quote == '"' && text[i] == '\\':
+			i++
+		case quote != 0 && text[i] == quote:
+			quote = 0
+		case quote == 0 && text[i] == '#' && (i == 0 || text[i-1] == ' ' || text[i-1] == '\t'):
+			return strings.TrimRight(text[:i], " \t")
+		}
+	}
+	return text
+}
+
+func (this *parser) isBalanced(text string) bool {
+	depth := 0
+	quote := byte(0)
+	for i := 0; i < len(text); i++ {
+		switch {
+		case quote == 0 && (text[i] == '"' || text[i] == '\''):
+			quote = text[i]
+		case quote == '"' && text[i] == '\\':
+			i++
+		case quote != 0 && text[i] == quote:
+			quote = 0
+		case quote == 0 && (text[i] == '[' || text[i] == '{'):
+			depth++
+		case quote == 0 && (text[i] == ']' || text[i] == '}'):
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// parseInline parses a scalar or a flow collection written on a line.
+func (this *parser) parseInline(text string, num int) (*node, error) {
+	text = this.stripComment(text)
+	if strings.HasPrefix(text, "[") || strings.HasPrefix(text, "{") {
+		f := &flow{text: text, num: num, parser: this}
+		n, err := f.parse()
+		if err != nil {
+			return nil, err
+		}
+		if f.skipSpace(); f.pos < len(f.text) {
+			return nil, &Error{num, "unexpected content after a flow collection"}
+		}
+		return n, nil
+	}
+	return this.parseScalar(text, num)
+}
+
+// parseScalar parses a plain or quoted scalar.
+func (this *parser) parseScalar(text string, num int) (*node, error) {
+	n := &node{kind: scalarNode, line: num}
+	switch {
+	case strings.HasPrefix(text, "\""):
+		if this.quotedEnd(text) != len(text) {
+			return nil, &Error{num, "invalid double-quoted scalar"}
+		}
+		val, err := this.unescape(text[1 : len(text)-1])
+		if err != nil {
+			return nil, &Error{num, err.Error()}
+		}
+		n.value = val
+	case strings.HasPrefix(text, "'"):
+		if this.quotedEnd(text) != len(text) {
+			return nil, &Error{num, "invalid single-quoted scalar"}
+		}
+		n.value = strings.Replace(text[1:len(text)-1], "''", "'", -1)
+	default:
+		switch text {
+		case "", "~", "null", "Null", "NULL":
+			n.null = true
+		default:
+			n.value = text
+		}
+	}
+	return n, nil
+}
+
+func (this *parser) unescape(text string) (string, error) {
+	buf := make([]byte, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' {
+			buf = append(buf, text[i])
+			continue
+		}
+		i++
+		if i >= len(text) {
+			return "", fmt.Errorf("invalid escape sequence")
+		}
+		size := 0
+		switch text[i] {
+		case '0':
+			buf = append(buf, 0)
+		case 'a':
+			buf = append(buf, '\a')
+		case 'b':
+			buf = append(buf, '\b')
+		case 't', '\t':
+			buf = append(buf, '\t')
+		case 'n':
+			buf = append(buf, '\n')
+		case 'v':
+			buf = append(buf, '\v')
+		case 'f':
+			buf = append(buf, '\f')
+		case 'r':
+			buf = append(buf, '\r')
+		case 'e':
+			buf = append(buf, 0x1b)
+		case ' ', '"', '/', '\\':
+			buf = append(buf, text[i])
+		case 'N':
+			buf = append(buf, "\u0085"...)
+		case '_':
+			buf = append(buf, " "...)
+		case 'L':
+			buf = append(buf, " "...)
+		case 'P':
+			buf = append(buf, " "...)
+		case 'x':
+			size = 2
+		case 'u':
+			size = 4
+		case 'U':
+			size = 8
+		default:
+			return "", fmt.Errorf("invalid escape sequence \\%c", text[i])
+		}
+		if size > 0 {
+			if i+1+size > len(text) {
+				return "", fmt.Errorf("invalid escape sequence")
+			}
+			code, err := strconv.ParseUint(text[i+1:i+1+size], 16, 32)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape sequence")
+			}
+			buf = append(buf, string(rune(code))...)
+			i = i + size
+		}
+	}
+	if !utf8.Valid(buf) {
+		return "", fmt.Errorf("invalid UTF-8 in double-quoted scalar")
+	}
+	return string(buf), nil
+}
+
+// flow parses flow collections, e.g. "[a, b]" or "{a: 1, b: [2, 3]}".
+type flow struct {
+	text   string
+	pos    int
+	num    int
+	parser *parser
+}
+
+func (this *flow) skipSpace() {
+	for this.pos < len(this.text) && (this.text[this.pos] == ' ' || this.text[this.pos] == '\t') {
+		this.pos++
+	}
+}
+
+func (this *flow) error(msg string) error {
+	return &Error{this.num, msg}
+}
+
+func (this *flow) parse() (*node, error) {
+	this.skipSpace()
+	if this.pos >= len(this.text) {
+		return nil, this.error("unexpected end of flow collection")
+	}
+	switch this.text[this.pos] {
+	case '[':
+		return this.parseCollection(sequenceNode, ']')
+	case '{':
+		return this.parseCollection(mappingNode, '}')
+	case '*':
+		end := this.scalarEnd(false)
+		alias := this.text[this.pos+1 : end]
+		this.pos = end
+		n := this.parser.anchors[alias]
+		if n == nil {
+			return nil, this.error(fmt.Sprintf("unknown alias %s", alias))
+		}
+		return n, nil
+	}
+	return this.parseScalar(false)
+}
+
+func (this *flow) parseCollection(kind nodeKind, end byte) (*node, error) {
+	n := &node{kind: kind, line: this.num}
+	defer func() { this.parser.depth-- }()
+	if err := this.parser.enter(this.num); err != nil {
+		return nil, err
+	}
+	this.pos++
+	for {
+		this.skipSpace()
+		if this.pos >= len(this.text) {
+			return nil, this.error("unexpected end of flow collection")
+		}
+		if this.text[this.pos] == end {
+			this.pos++
+			return n, nil
+		}
+		e := &entry{line: this.num}
+		if kind == mappingNode {
+			key, err := this.parseScalar(true)
+			if err != nil {
+				return nil, err
+			}
+			this.skipSpace()
+			if this.pos >= len(this.text) || this.text[this.pos] != ':' {
+				return nil, this.error("expected ':' in flow mapping")
+			}
+			this.pos++
+			e.key = key.value
+		}
+		value, err := this.parse()
+		if err != nil {
+			return nil, err
+		}
+		e.value = value
+		n.entries = append(n.entries, e)
+		this.skipSpace()
+		if this.pos < len(this.text) && this.text[this.pos] == ',' {
+			this.pos++
+		} else if this.pos >= len(this.text) || this.text[this.pos] != end {
+			return nil, this.error("expected ',' in flow collection")
+		}
+	}
+}
+
+func (this *flow) parseScalar(isKey bool) (*node, error) {
+	this.skipSpace()
+	if this.pos < len(this.text) && (this.text[this.pos] == '"' || this.text[this.pos] == '\'') {
+		end := this.parser.quotedEnd(this.text[this.pos:])
+		if end < 0 {
+			return nil, this.error("unterminated quoted scalar")
+		}
+		n, err := this.parser.parseScalar(this.text[this.pos:this.pos+end], this.num)
+		this.pos = this.pos + end
+		return n, err
+	}
+	end := this.scalarEnd(isKey)
+	text := strings.TrimRight(this.text[this.pos:end], " \t")
+	this.pos = end
+	return this.parser.parseScalar(text, this.num)
+}
+
+// scalarEnd returns the end of a plain scalar in a flow collection.
+func (this *flow) scalarEnd(isKey bool) int {
+	for i := this.pos; i < len(this.text); i++ {
+		switch this.text[i] {
+		case ',', ']', '}':
+			return i
+		case ':':
+			if isKey && (i+1 == len(this.text) || this.text[i+1] == ' ') {
+				return i
+			}
+		}
+	}
+	return len(this.text)
+}
@@ -0,0 +1,326 @@
+// Package yaml converts configuration data from and to YAML documents.
+//
+// The mapping follows the same conventions as config.JSONMapping. Mappings
+// are sections, the value of a section is stored under the "@value" key and
+// sequences are names used more than once in the same section. If nodes with
+// the same name are not adjacent, children of the section are stored in order
+// under the "@children" key, as a sequence of mappings with one key each.
+// Scalars are values, null is an empty value. Values of sensitive keys are
+// redacted by default, like by config.ToJSON.
+//
+// The package implements a subset of YAML 1.2 which is sufficient for
+// configuration files: block and flow collections, plain, quoted and block
+// scalars, comments, anchors and aliases (which are expanded) and merge keys.
+// Only the first document of a stream is used. Comments which precede keys
+// are kept as comments of configuration nodes and written back. Keys must be
+// valid names, see config.IsValidName, and nesting and the number of nodes
+// expanded from aliases are limited.
+package yaml
+
+import "bytes"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "io"
+import "regexp"
+import "strconv"
+import "strings"
+
+// Mapping converts configuration data from and to YAML documents.
+type Mapping struct {
+	// ValueKey is the name of the key with values of sections, by default
+	// config.DefaultJSONValueKey.
+	ValueKey string
+	// ChildrenKey is the name of the key with ordered children of sections,
+	// by default config.DefaultJSONChildrenKey.
+	ChildrenKey string
+	// Redactor redacts values of sensitive keys in exported documents,
+	// config.DefaultRedactor is used if nil.
+	Redactor *config.Redactor
+	// Raw disables redaction, values are exported as they are stored.
+	Raw bool
+}
+
+// Parse parses configuration data from a YAML document with the default
+// mapping.
+func Parse(r io.Reader) (config.Config, error) {
+	return Mapping{}.Parse(r)
+}
+
+// Marshal converts configuration data to a YAML document with the default
+// mapping, values of sensitive keys are redacted by config.DefaultRedactor.
+func Marshal(cfg config.Config) ([]byte, error) {
+	return Mapping{}.Marshal(cfg)
+}
+
+func (this Mapping) valueKey() string {
+	if this.ValueKey == "" {
+		return config.DefaultJSONValueKey
+	}
+	return this.ValueKey
+}
+
+func (this Mapping) childrenKey() string {
+	if this.ChildrenKey == "" {
+		return config.DefaultJSONChildrenKey
+	}
+	return this.ChildrenKey
+}
+
+// Parse parses configuration data from a YAML document. The document has to
+// be a mapping or empty.
+func (this Mapping) Parse(r io.Reader) (config.Config, error) {
+	p, err := newParser(r)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := p.parseDocument()
+	if err != nil {
+		return nil, err
+	}
+	b := config.NewLayoutBuilder()
+	if doc.kind == scalarNode && doc.null {
+		return b.Config(), nil
+	}
+	if doc.kind != mappingNode {
+		return nil, &Error{doc.line, "document is not a mapping"}
+	}
+	c := &converter{mapping: this, b: b}
+	if err := c.build(doc, 0); err != nil {
+		return nil, err
+	}
+	return b.Config(), nil
+}
+
+// maxDepth limits nesting of parsed collections and of converted nodes,
+// aliases may otherwise produce very deep trees.
+const maxDepth = 256
+
+// maxNodes limits the number of converted nodes, aliases expanded again and
+// again may otherwise produce huge trees from a small document.
+const maxNodes = 1 << 20
+
+// converter converts parsed nodes to configuration data.
+type converter struct {
+	mapping Mapping
+	b       config.LayoutBuilder
+	nodes   int
+}
+
+func (this *converter) build(n *node, depth int) error {
+	if depth > maxDepth {
+		return &Error{n.line, "document is nested too deep"}
+	}
+	for _, e := range n.entries {
+		if e.key == this.mapping.valueKey() {
+			continue
+		}
+		if e.key == this.mapping.childrenKey() {
+			if err := this.buildChildren(e, depth); err != nil {
+				return err
+			}
+			continue
+		}
+		if !config.IsValidName(e.key) {
+			return &Error{e.line, fmt.Sprintf("key %q is not a valid name", e.key)}
+		}
+		if e.value.kind == sequenceNode {
+			if len(e.comments) > 0 {
+				this.b.Comment(strings.Join(e.comments, "\n"))
+			}
+			for _, item := range e.value.entries {
+				if item.value.kind == sequenceNode {
+					return &Error{item.line, fmt.Sprintf("nested sequence in %s", e.key)}
+				}
+				if err := this.buildNode(e.key, item, depth); err != nil {
+					return err
+				}
+			}
+		} else if err := this.buildNode(e.key, e, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildChildren adds the ordered children, every mapping of the sequence adds
+// its keys to the current section.
+func (this *converter) buildChildren(e *entry, depth int) error {
+	if e.value.kind != sequenceNode {
+		return &Error{e.line, fmt.Sprintf("%s must be a sequence", e.key)}
+	}
+	for _, item := range e.value.entries {
+		if item.value.kind != mappingNode {
+			return &Error{item.line, fmt.Sprintf("items of %s must be mappings", e.key)}
+		}
+		if len(item.comments) > 0 {
+			this.b.Comment(strings.Join(item.comments, "\n"))
+		}
+		if err := this.build(item.value, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *converter) buildNode(name string, e *entry, depth int) error {
+	this.nodes++
+	if this.nodes > maxNodes {
+		return &Error{e.line, "document expands to too many nodes"}
+	}
+	if len(e.comments) > 0 {
+		this.b.Comment(strings.Join(e.comments, "\n"))
+	}
+	n := e.value
+	if n.kind != mappingNode {
+		this.b.String(name, n.value)
+		this.b.At(config.Position{Line: e.line})
+		return nil
+	}
+	val := ""
+	for _, child := range n.entries {
+		if child.key == this.mapping.valueKey() {
+			if child.value.kind != scalarNode {
+				return &Error{child.line, fmt.Sprintf("%s must be a scalar", this.mapping.valueKey())}
+			}
+			val = child.value.value
+		}
+	}
+	this.b.Section(name, val)
+	this.b.At(config.Position{Line: e.line})
+	if err := this.build(n, depth+1); err != nil {
+		return err
+	}
+	this.b.CloseSection()
+	return nil
+}
+
+// Marshal converts configuration data to a YAML document.
+func (this Mapping) Marshal(cfg config.Config) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if this.Redactor != nil && !this.Raw {
+		cfg = this.Redactor.Redact(cfg)
+	} else if !this.Raw {
+		cfg = config.DefaultRedactor.Redact(cfg)
+	}
+	if cfg.Value() == "" && len(config.Children(cfg)) == 0 {
+		buf.WriteString("{}\n")
+		return buf.Bytes(), nil
+	}
+	this.writeMapping(buf, cfg, "")
+	return buf.Bytes(), nil
+}
+
+func (this Mapping) writeMapping(buf *bytes.Buffer, cfg config.Config, indent string) {
+	if cfg.Value() != "" {
+		buf.WriteString(indent + strconv.Quote(this.valueKey()) + ": " + this.scalar(cfg.Value()) + "\n")
+	}
+	children := config.Children(cfg)
+	if !this.grouped(children) {
+		buf.WriteString(indent + strconv.Quote(this.childrenKey()) + ":\n")
+		for _, child := range children {
+			this.writeComments(buf, child, indent+"  ")
+			buf.WriteString(indent + "  - " + child.Name() + ":")
+			this.writeValue(buf, child, indent+"      ")
+		}
+		return
+	}
+	done := make(map[string]bool)
+	for _, child := range children {
+		if done[child.Name()] {
+			continue
+		}
+		done[child.Name()] = true
+		group := make([]config.Config, 0, 1)
+		for _, other := range children {
+			if other.Name() == child.Name() {
+				group = append(group, other)
+			}
+		}
+		if len(group) == 1 {
+			this.writeComments(buf, child, indent)
+			buf.WriteString(indent + child.Name() + ":")
+			this.writeValue(buf, child, indent+"  ")
+			continue
+		}
+		buf.WriteString(indent + child.Name() + ":\n")
+		for _, node := range group {
+			this.writeComments(buf, node, indent+"  ")
+			buf.WriteString(indent + "  -")
+			if config.IsSection(node) && (node.Value() != "" || len(config.Children(node)) > 0) {
+				item := new(bytes.Buffer)
+				this.writeMapping(item, node, indent+"    ")
+				buf.WriteString(" ")
+				buf.Write(item.Bytes()[len(indent)+4:])
+			} else {
+				this.writeValue(buf, node, indent+"    ")
+			}
+		}
+	}
+}
+
+// grouped reports whether nodes with the same name are adjacent, so grouping
+// them to sequences keeps their order.
+func (this Mapping) grouped(children []config.Config) bool {
+	seen := make(map[string]bool)
+	for key, child := range children {
+		if key > 0 && children[key-1].Name() == child.Name() {
+			continue
+		}
+		if seen[child.Name()] {
+			return false
+		}
+		seen[child.Name()] = true
+	}
+	return true
+}
+
+// writeValue writes the value of a node which follows a key or a sequence
+// indicator.
+func (this Mapping) writeValue(buf *bytes.Buffer, cfg config.Config, indent string) {
+	if !config.IsSection(cfg) {
+		buf.WriteString(" " + this.scalar(config.StoredValue(cfg)) + "\n")
+	} else if cfg.Value() == "" && len(config.Children(cfg)) == 0 {
+		buf.WriteString(" {}\n")
+	} else {
+		buf.WriteString("\n")
+		this.writeMapping(buf, cfg, indent)
+	}
+}
+
+func (this Mapping) writeComments(buf *bytes.Buffer, cfg config.Config, indent string) {
+	for _, comment := range config.Comments(cfg) {
+		if comment == "" {
+			buf.WriteString(indent + "#\n")
+		} else {
+			buf.WriteString(indent + "# " + comment + "\n")
+		}
+	}
+}
+
+var (
+	plainScalar  = regexp.MustCompile(`^[A-Za-z0-9_./]([A-Za-z0-9_./+ -]*[A-Za-z0-9_./+-])?$`)
+	numberScalar = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+	dateScalar   = regexp.MustCompile(`^[0-9]{4}-[0-9]{1,2}-[0-9]{1,2}`)
+)
+
+// scalar returns the value as a YAML scalar. Plain scalars are used only if
+// other YAML tools read them as the same string, number or boolean.
+func (this Mapping) scalar(val string) string {
+	if val == "true" || val == "false" || numberScalar.MatchString(val) {
+		return val
+	}
+	if !plainScalar.MatchString(val) || dateScalar.MatchString(val) {
+		return strconv.Quote(val)
+	}
+	switch strings.ToLower(val) {
+	case "null", "true", "false", "yes", "no", "on", "off", "y", "n", ".inf", "-.inf", ".nan":
+		return strconv.Quote(val)
+	}
+	if _, err := strconv.ParseFloat(strings.Replace(val, "_", "", -1), 64); err == nil {
+		return strconv.Quote(val)
+	}
+	if _, err := strconv.ParseInt(val, 0, 64); err == nil {
+		return strconv.Quote(val)
+	}
+	return val
+}
@@ -0,0 +1,213 @@
+package yaml_test
+
+import "bytes"
+import "fmt"
+import "github.com/twoleds-golang/config"
+import "github.com/twoleds-golang/config/yaml"
+import "strings"
+import "testing"
+
+func TestParse(t *testing.T) {
+
+	var src = "# Application\n" +
+		"Name: Test String\n" +
+		"Quoted: \"a\\tb\"\n" +
+		"Single: 'it''s'\n" +
+		"Empty:\n" +
+		"Hosts: [a, \"b\", c]\n" +
+		"defaults: &defaults\n" +
+		"  Port: 8080\n" +
+		"  Debug: false\n" +
+		"Section:\n" +
+		"  - \"@value\": One\n" +
+		"    <<: *defaults\n" +
+		"    Port: 8081\n" +
+		"  - \"@value\": Two\n" +
+		"    Text: |\n" +
+		"      line one\n" +
+		"      line two\n" +
+		"    Folded: >-\n" +
+		"      folded\n" +
+		"      text\n" +
+		"Nested: {A: {B: 1}}\n"
+
+	cfg, err := yaml.Parse(strings.NewReader(src))
+	if err != nil {
+		t.Errorf("Cannot parse YAML: %s", err.Error())
+		t.FailNow()
+	}
+
+	var tests = map[string]string{
+		"Name":               "Test String",
+		"Quoted":             "a\tb",
+		"Single":             "it's",
+		"Empty":              "",
+		"defaults/Port":      "8080",
+		"Section:One/Port":   "8081",
+		"Section:One/Debug":  "false",
+		"Section:Two/Text":   "line one\nline two\n",
+		"Section:Two/Folded": "folded text",
+		"Nested/A/B":         "1",
+	}
+
+	for query, expected := range tests {
+		if val, ok := cfg.String(query); !ok || val != expected {
+			t.Errorf("Invalid value for query '%s': %q", query, val)
+			t.Fail()
+		}
+	}
+
+	if hosts := cfg.QueryAll("Hosts"); len(hosts) != 3 || hosts[1].Value() != "b" {
+		t.Error("Invalid values for query 'Hosts'")
+		t.Fail()
+	}
+
+	if name, _ := cfg.Query("Name"); len(config.Comments(name)) != 1 || config.Comments(name)[0] != "Application" {
+		t.Error("Invalid comments for query 'Name'")
+		t.Fail()
+	}
+
+	if _, err := yaml.Parse(strings.NewReader("A: *missing\n")); err == nil {
+		t.Error("Expected error for unknown alias")
+		t.Fail()
+	}
+
+}
+
+func TestParseHostile(t *testing.T) {
+
+	laughs := "A0: &A0 {X0: lol, X1: lol, X2: lol, X3: lol, X4: lol, X5: lol, X6: lol, X7: lol, X8: lol, X9: lol}\n"
+	for i := 1; i < 10; i++ {
+		laughs += fmt.Sprintf("A%d: &A%d {", i, i)
+		for j := 0; j < 10; j++ {
+			laughs += fmt.Sprintf("X%d: *A%d, ", j, i-1)
+		}
+		laughs += "}\n"
+	}
+
+	var tests = map[string]string{
+		laughs:                                "yaml: line 1: document expands to too many nodes",
+		"A: " + strings.Repeat("[", 100000):   "yaml: line 1: document is nested too deep",
+		"A:\n" + strings.Repeat("- ", 100000): "yaml: line 2: document is nested too deep",
+		"Bad key: 1\n":                        "yaml: line 1: key \"Bad key\" is not a valid name",
+		"A:\n  8080: http\n":                  "yaml: line 2: key \"8080\" is not a valid name",
+	}
+
+	for src, expected := range tests {
+		if _, err := yaml.Parse(strings.NewReader(src)); err == nil || err.Error() != expected {
+			t.Errorf("Invalid error for %.40q: %v", src, err)
+			t.Fail()
+		}
+	}
+
+}
+
+func TestMarshal(t *testing.T) {
+
+	var src = "# Application\n" +
+		"Name \"Test String\"\n" +
+		"Flag yes\n" +
+		"Count 010\n" +
+		"Ratio 1.5\n" +
+		"Host a\n" +
+		"Host b\n" +
+		"Section One {\n" +
+		"    IntValue -1\n" +
+		"}\n" +
+		"Section Two {\n" +
+		"}\n" +
+		"Empty {\n" +
+		"}\n"
+
+	var expected = "# Application\n" +
+		"Name: Test String\n" +
+		"Flag: \"yes\"\n" +
+		"Count: \"010\"\n" +
+		"Ratio: 1.5\n" +
+		"Host:\n" +
+		"  - a\n" +
+		"  - b\n" +
+		"Section:\n" +
+		"  - \"@value\": One\n" +
+		"    IntValue: -1\n" +
+		"  - \"@value\": Two\n" +
+		"Empty: {}\n"
+
+	cfg, err := config.ParseFromString(src)
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil || string(data) != expected {
+		t.Errorf("Invalid YAML:\n%s", data)
+		t.FailNow()
+	}
+
+	parsed, err := yaml.Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Errorf("Cannot parse YAML: %s", err.Error())
+		t.FailNow()
+	}
+
+	if json1, _ := config.ToJSON(cfg); true {
+		if json2, _ := config.ToJSON(parsed); string(json1) != string(json2) {
+			t.Errorf("Round trip is not lossless: %s", json2)
+			t.Fail()
+		}
+	}
+
+}
+
+func TestMarshalOrder(t *testing.T) {
+
+	var src = "A 1\n" +
+		"# second\n" +
+		"B x\n" +
+		"A 2\n" +
+		"Server Main {\n" +
+		"    Port 8080\n" +
+		"    Password secret\n" +
+		"}\n"
+
+	var expected = "\"@children\":\n" +
+		"  - A: 1\n" +
+		"  # second\n" +
+		"  - B: x\n" +
+		"  - A: 2\n" +
+		"  - Server:\n" +
+		"      \"@value\": Main\n" +
+		"      Port: 8080\n" +
+		"      Password: \"<redacted>\"\n"
+
+	cfg, err := config.ParseFromString(src)
+	if err != nil {
+		t.Errorf("Cannot parse config: %s", err.Error())
+		t.FailNow()
+	}
+
+	data, err := yaml.Marshal(cfg)
+	if err != nil || string(data) != expected {
+		t.Errorf("Invalid YAML:\n%s", data)
+		t.FailNow()
+	}
+
+	data, _ = yaml.Mapping{Raw: true}.Marshal(cfg)
+	parsed, err := yaml.Parse(bytes.NewReader(data))
+	if err != nil {
+		t.Errorf("Cannot parse YAML: %s\n%s", err.Error(), data)
+		t.FailNow()
+	}
+
+	if config.Dump(parsed) != config.Dump(cfg) {
+		t.Errorf("Order is not kept:\n%s", config.Dump(parsed))
+		t.Fail()
+	}
+
+	if b, _ := parsed.Query("B"); len(config.Comments(b)) != 1 || config.Comments(b)[0] != "second" {
+		t.Error("Invalid comments of ordered children")
+		t.Fail()
+	}
+
+}